@@ -0,0 +1,80 @@
+package compiler
+
+import (
+	"errors"
+	"fmt"
+	"gqlc/config"
+	"gqlc/parser"
+	"gqlc/schema"
+	"io"
+	"os"
+	"sort"
+)
+
+// Build keeps the loaded schema and the parsed operation files between compilations,
+// so that a rebuild only needs to parse the files that changed.
+type Build struct {
+	cfg       config.Config
+	schema    *schema.Schema
+	schemaErr error
+	files     map[string][]parser.AST
+}
+
+// NewBuild creates an empty build for the given config
+func NewBuild(cfg config.Config) *Build {
+	return &Build{cfg: cfg, files: make(map[string][]parser.AST)}
+}
+
+// LoadSchema loads the schema from the configured source.
+// Errors are reported by the next call to Generate.
+func (b *Build) LoadSchema() {
+	b.schema, b.schemaErr = schema.Load(b.cfg)
+}
+
+// UpdateFiles parses the given operation files again.
+// Files that no longer exist are removed from the build.
+func (b *Build) UpdateFiles(paths []string) error {
+	var files []*os.File
+	defer func() {
+		closeFiles(files)
+	}()
+	for _, path := range paths {
+		f, err := os.Open(path)
+		if errors.Is(err, os.ErrNotExist) {
+			delete(b.files, path)
+			continue
+		}
+		if err != nil {
+			return fmt.Errorf("failed to open file %s: %w", path, err)
+		}
+		files = append(files, f)
+	}
+
+	for i, nodes := range parseFiles(files) {
+		b.files[files[i].Name()] = nodes
+	}
+	return nil
+}
+
+// Generate compiles the current state of the build, the manifest is only written if persisted queries are enabled
+func (b *Build) Generate(genSchemaCode io.Writer, genSchemaName string, genOperationCode io.Writer, genManifest io.Writer, warnings io.Writer) error {
+	paths := make([]string, 0, len(b.files))
+	for path := range b.files {
+		paths = append(paths, path)
+	}
+	sort.Strings(paths)
+
+	var nodes []parser.AST
+	for _, path := range paths {
+		nodes = append(nodes, b.files[path]...)
+	}
+
+	return generate(b.cfg, b.schema, b.schemaErr, nodes, genSchemaCode, genSchemaName, genOperationCode, genManifest, warnings)
+}
+
+// closeFiles closes all files in the slice
+func closeFiles(files []*os.File) {
+	for _, f := range files {
+		_ = f.Close()
+	}
+}
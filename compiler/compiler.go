@@ -2,13 +2,18 @@ package compiler
 
 import (
 	_ "embed"
+	"encoding/json"
+	"errors"
 	"fmt"
 	"gqlc/config"
 	"gqlc/parser"
 	"gqlc/schema"
+	"gqlc/validate"
 	"io"
 	"os"
 	"path"
+	"path/filepath"
+	"strconv"
 	"strings"
 	"sync"
 )
@@ -20,50 +25,112 @@ var (
 
 const placeholder = "\n  // GQLC_OPERATIONS_PLACEHOLDER"
 
-func Compile(cfg config.Config, operationsSrc []*os.File, genSchemaCode io.Writer, genSchemaName string, genOperationCode io.Writer) error {
-	if _, err := fmt.Fprint(genSchemaCode, "// Generated by gqlc\n\n"); err != nil {
-		return fmt.Errorf("failed to write generated header to output: %w", err)
+// defaultEndpoint is the declaration of the default url in the runtime, replaced by the endpoint of the config
+const defaultEndpoint = "const defaultEndpoint: string | undefined = undefined;"
+
+// persistedQueries is the declaration of the persisted query mode in the runtime, replaced by output.persisted_queries
+const persistedQueries = `const persistedQueries: "apq" | "trusted" | undefined = undefined;`
+
+// Compile generates the code of the operations. The persisted query manifest is only written if output.persisted_queries is set.
+func Compile(cfg config.Config, operationsSrc []*os.File, genSchemaCode io.Writer, genSchemaName string, genOperationCode io.Writer, genManifest io.Writer, warnings io.Writer) error {
+	parsed := make(chan [][]parser.AST, 1)
+	go func() {
+		parsed <- parseFiles(operationsSrc)
+	}()
+
+	sch, schemaErr := schema.Load(cfg)
+
+	// Collect all operations and fragments first, fragments may be defined in any file
+	var nodes []parser.AST
+	for _, fileNodes := range <-parsed {
+		nodes = append(nodes, fileNodes...)
 	}
-	if _, err := fmt.Fprint(genOperationCode, "// Generated by gqlc\n\n"); err != nil {
-		return fmt.Errorf("failed to write generated header to output: %w", err)
+
+	return generate(cfg, sch, schemaErr, nodes, genSchemaCode, genSchemaName, genOperationCode, genManifest, warnings)
+}
+
+// parseFiles parses the files concurrently and returns the nodes of each file in the order of the files
+func parseFiles(files []*os.File) [][]parser.AST {
+	parsed := make([][]parser.AST, len(files))
+	var wg sync.WaitGroup
+	for i, src := range files {
+		wg.Add(1)
+		go func(i int, src *os.File) {
+			defer wg.Done()
+			for ast := range parser.ParseFile(src.Name(), src) {
+				parsed[i] = append(parsed[i], ast)
+			}
+		}(i, src)
 	}
+	wg.Wait()
+	return parsed
+}
 
-	operations := make(chan parser.AST)
-	if len(operationsSrc) == 0 {
-		close(operations)
-	} else {
-		var wg sync.WaitGroup
-		for _, src := range operationsSrc {
-			wg.Add(1)
-			go func(src *os.File) {
-				defer wg.Done()
-				for ast := range parser.Parse(src) {
-					operations <- ast
-				}
-			}(src)
+// generate reports syntax errors of the schema and the operations, validates the operations,
+// writes warnings for deprecated fields and enum values and writes the generated code
+func generate(cfg config.Config, sch *schema.Schema, schemaErr error, nodes []parser.AST, genSchemaCode io.Writer, genSchemaName string, genOperationCode io.Writer, genManifest io.Writer, warnings io.Writer) error {
+	var collectedOperations []parser.AST
+	var syntaxErrors parser.Errors
+	for _, node := range nodes {
+		if err, ok := node.(parser.Error); ok {
+			syntaxErrors = append(syntaxErrors, err)
+			continue
 		}
+		collectedOperations = append(collectedOperations, node)
+	}
 
-		go func() {
-			wg.Wait()
-			close(operations)
-		}()
+	// Report syntax errors of the schema and the operations together
+	var schemaSyntaxErrors parser.Errors
+	if errors.As(schemaErr, &schemaSyntaxErrors) {
+		syntaxErrors = append(schemaSyntaxErrors, syntaxErrors...)
+	} else if schemaErr != nil {
+		return fmt.Errorf("failed to load schema: %w", schemaErr)
+	}
+	if len(syntaxErrors) > 0 {
+		syntaxErrors.Sort()
+		return syntaxErrors
 	}
 
-	sch, err := schema.Load(cfg)
-	if err != nil {
-		return fmt.Errorf("failed to load schema: %w", err)
+	doc := parser.NewDocument(collectedOperations)
+	if errs := validate.Validate(sch, doc); len(errs) > 0 {
+		return errs
+	}
+
+	if deprecations := validate.Deprecations(sch, doc); len(deprecations) > 0 {
+		if cfg.Input.DeprecationsAsErrors {
+			return deprecations
+		}
+		for _, warning := range deprecations {
+			if _, err := fmt.Fprintf(warnings, "%s: warning: %s\n", warning.Loc, warning.Message); err != nil {
+				return fmt.Errorf("failed to write warning: %w", err)
+			}
+		}
 	}
 
 	switch strings.ToLower(cfg.Output.Language) {
 	case "typescript", "ts", "typescriptreact", "tsx":
+		if _, err := fmt.Fprint(genSchemaCode, "// Generated by gqlc\n\n"); err != nil {
+			return fmt.Errorf("failed to write generated header to output: %w", err)
+		}
+		if _, err := fmt.Fprint(genOperationCode, "// Generated by gqlc\n\n"); err != nil {
+			return fmt.Errorf("failed to write generated header to output: %w", err)
+		}
+
 		// Remove .ts extension for TypeScript imports
 		schemaPath := "./" + genSchemaName
 		if cfg.Output.ImportIncludeExtension == nil || !*cfg.Output.ImportIncludeExtension {
 			schemaPath = strings.TrimSuffix(schemaPath, path.Ext(schemaPath))
 		}
 
-		// Write import and runtime with placeholder
-		runtimeWithPlaceholder := TypeScriptRuntime
+		// Write import and runtime with placeholder, the client class is renamed per project
+		className := cfg.Output.TypeScriptClassName()
+		runtimeWithPlaceholder := strings.Replace(TypeScriptRuntime, "export class GraphQL {", "export class "+className+" {", 1)
+		if endpoint := cfg.ClientEndpoint(); endpoint != "" {
+			runtimeWithPlaceholder = strings.Replace(runtimeWithPlaceholder, defaultEndpoint, fmt.Sprintf("const defaultEndpoint: string | undefined = %s;", strconv.Quote(endpoint)), 1)
+		}
+		if cfg.Output.PersistedQueries != "" {
+			runtimeWithPlaceholder = strings.Replace(runtimeWithPlaceholder, persistedQueries, fmt.Sprintf(`const persistedQueries: "apq" | "trusted" | undefined = %q;`, cfg.Output.PersistedQueries), 1)
+		}
 		placeholderIndex := strings.Index(runtimeWithPlaceholder, placeholder)
 		if placeholderIndex == -1 {
 			return fmt.Errorf("runtime template missing %s", strings.TrimSpace(placeholder))
@@ -77,14 +144,14 @@ func Compile(cfg config.Config, operationsSrc []*os.File, genSchemaCode io.Write
 			return fmt.Errorf("failed to write runtime before placeholder: %w", err)
 		}
 
-		// Collect operations and generate methods directly to the writer
-		var collectedOperations []parser.AST
-
-		for opAst := range operations {
-			collectedOperations = append(collectedOperations, opAst)
-			if _, err := opAst.GenerateTypeScriptMethod(genOperationCode); err != nil {
-				return fmt.Errorf("failed to generate TypeScript operation method: %w", err)
-			}
+		// Generate methods with the fragments each operation uses
+		operations := sch.DescribeOperations(sch.StripClientFields(sch.AddTypenames(doc)))
+		if _, err := operations.GenerateTypeScriptClassMethods(genOperationCode, parser.TypeScriptClassOptions{
+			ClassName:        className,
+			URLArgument:      cfg.Output.URLArgument,
+			PersistedQueries: cfg.Output.PersistedQueries != "",
+		}); err != nil {
+			return fmt.Errorf("failed to generate TypeScript operation method: %w", err)
 		}
 
 		// Write everything after the placeholder
@@ -92,12 +159,68 @@ func Compile(cfg config.Config, operationsSrc []*os.File, genSchemaCode io.Write
 			return fmt.Errorf("failed to write runtime after placeholder: %w", err)
 		}
 
-		if err := sch.GenerateTypeScriptWithOperations(nil, collectedOperations, genSchemaCode); err != nil {
+		if cfg.Output.PersistedQueries != "" {
+			if err := writePersistedQueryManifest(genManifest, operations); err != nil {
+				return err
+			}
+		}
+
+		gen := &schema.TypeScriptGenerator{Scalars: scalarsRelativeToOutput(cfg.Output)}
+		if err := gen.GenerateWithOperations(sch, nil, collectedOperations, genSchemaCode); err != nil {
 			return fmt.Errorf("failed to write TypeScript schema to output: %w", err)
 		}
+	case "go", "golang":
+		if err := generateGo(cfg, sch, doc, collectedOperations, genSchemaCode, genOperationCode); err != nil {
+			return err
+		}
 	default:
 		return fmt.Errorf("unsupported language: %s", cfg.Output.Language)
 	}
 
 	return nil
 }
+
+// writePersistedQueryManifest writes the documents of the operations keyed by their id as JSON
+func writePersistedQueryManifest(w io.Writer, doc parser.Document) error {
+	manifest, err := json.MarshalIndent(doc.PersistedQueries(), "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to marshal persisted query manifest: %w", err)
+	}
+	if _, err := fmt.Fprintf(w, "%s\n", manifest); err != nil {
+		return fmt.Errorf("failed to write persisted query manifest: %w", err)
+	}
+	return nil
+}
+
+// IsPersistedQueryManifest reports whether data is a manifest written by gqlc, a JSON object of documents keyed by their id
+func IsPersistedQueryManifest(data []byte) bool {
+	var manifest map[string]string
+	if err := json.Unmarshal(data, &manifest); err != nil || manifest == nil {
+		return false
+	}
+	for id, document := range manifest {
+		if parser.DocumentID(document) != id {
+			return false
+		}
+	}
+	return true
+}
+
+// scalarsRelativeToOutput returns the scalar mappings with relative import paths,
+// which are relative to the working directory, changed to be relative to the output location
+func scalarsRelativeToOutput(output config.Output) config.Scalars {
+	scalars := make(config.Scalars, len(output.Scalars))
+	for name, scalar := range output.Scalars {
+		if strings.HasPrefix(scalar.Import, "./") || strings.HasPrefix(scalar.Import, "../") {
+			if rel, err := filepath.Rel(output.Location, scalar.Import); err == nil {
+				rel = filepath.ToSlash(rel)
+				if !strings.HasPrefix(rel, "../") {
+					rel = "./" + rel
+				}
+				scalar.Import = rel
+			}
+		}
+		scalars[name] = scalar
+	}
+	return scalars
+}
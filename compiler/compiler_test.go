@@ -0,0 +1,163 @@
+package compiler
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"gqlc/config"
+	"gqlc/parser"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+const testSchema = `type Query {
+  user(id: ID!): User
+  search(text: String!): [User!]!
+}
+
+type Mutation {
+  rename(id: ID!, name: String!): User
+}
+
+type User {
+  id: ID!
+  name: String!
+}`
+
+const testOperations = `query GetUser($id: ID!) {
+  user(id: $id) {
+    ...UserFields
+  }
+}
+
+mutation Rename($id: ID!, $name: String!) {
+  rename(id: $id, name: $name) {
+    id
+  }
+}
+
+fragment UserFields on User {
+  id
+  name
+}`
+
+// compileTestOperations compiles the operations to TypeScript and returns the operations code and the manifest
+func compileTestOperations(t *testing.T, operationsSrc string, persistedQueries string) (string, string) {
+	t.Helper()
+	dir := t.TempDir()
+	for name, content := range map[string]string{"schema.graphql": testSchema, "operations.graphql": operationsSrc} {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	operations, err := os.Open(filepath.Join(dir, "operations.graphql"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer operations.Close()
+
+	cfg := config.Config{
+		Input:  config.Input{Schemas: config.SchemaSources{{Path: filepath.Join(dir, "schema.graphql")}}, Operations: dir},
+		Output: config.Output{Location: dir, Language: "typescript", Suffix: "_gqlc", PersistedQueries: persistedQueries},
+	}
+	var schemaCode, operationCode, manifest bytes.Buffer
+	if err := Compile(cfg, []*os.File{operations}, &schemaCode, "schema_gqlc.ts", &operationCode, &manifest, io.Discard); err != nil {
+		t.Fatalf("Compile returned error: %v", err)
+	}
+	return operationCode.String(), manifest.String()
+}
+
+func TestCompile_PersistedQueries(t *testing.T) {
+	code, manifestJSON := compileTestOperations(t, testOperations, config.PersistedQueriesAPQ)
+	if !strings.Contains(code, `const persistedQueries: "apq" | "trusted" | undefined = "apq";`) || strings.Contains(code, persistedQueries) {
+		t.Errorf("expected the persisted query mode in the runtime:\n%s", code)
+	}
+
+	var manifest map[string]string
+	if err := json.Unmarshal([]byte(manifestJSON), &manifest); err != nil {
+		t.Fatalf("invalid manifest %q: %v", manifestJSON, err)
+	}
+	if len(manifest) != 2 {
+		t.Errorf("expected a document per operation, got %v", manifest)
+	}
+	for id, document := range manifest {
+		if parser.DocumentID(document) != id {
+			t.Errorf("expected the id %s to be the hash of its document:\n%s", id, document)
+		}
+		name := "GetUser"
+		if strings.HasPrefix(document, "mutation") {
+			name = "Rename"
+		} else if !strings.Contains(document, "fragment UserFields on User") {
+			t.Errorf("expected the fragment in the document:\n%s", document)
+		}
+		if call := fmt.Sprintf("GraphQL.%s_query, schema.%s_Schema, variables, schema.%s_Variables_Schema, options, %q);", name, name, name, id); !strings.Contains(code, call) {
+			t.Errorf("expected %s to pass the id of the manifest, %q not in:\n%s", name, call, code)
+		}
+	}
+}
+
+func TestCompile_PersistedQueriesSendHashedDocument(t *testing.T) {
+	code, manifestJSON := compileTestOperations(t, "query Search {\n  search(text: \"say \\\"hi\\\" \\\\ `${name}`\") {\n    id\n  }\n}", config.PersistedQueriesAPQ)
+	var manifest map[string]string
+	if err := json.Unmarshal([]byte(manifestJSON), &manifest); err != nil {
+		t.Fatalf("invalid manifest %q: %v", manifestJSON, err)
+	}
+
+	prefix := "private static readonly Search_query = "
+	start := strings.Index(code, prefix)
+	if start == -1 {
+		t.Fatalf("expected %q in:\n%s", prefix, code)
+	}
+	literal, _, _ := strings.Cut(code[start+len(prefix):], ";\n")
+	// The literal is JSON encoded, decoding it gives the document the runtime sends
+	var sent string
+	if err := json.Unmarshal([]byte(literal), &sent); err != nil {
+		t.Fatalf("expected a string literal, got %s: %v", literal, err)
+	}
+	if !strings.Contains(sent, `"say \"hi\" \\ `+"`${name}`"+`"`) {
+		t.Errorf("expected the escaped string argument in the document:\n%s", sent)
+	}
+	id := parser.DocumentID(sent)
+	if document, ok := manifest[id]; !ok || document != sent {
+		t.Errorf("expected the sent document with id %s in the manifest %v, sent:\n%s", id, manifest, sent)
+	}
+	if !strings.Contains(code, fmt.Sprintf("options, %q);", id)) {
+		t.Errorf("expected the id %s of the sent document in:\n%s", id, code)
+	}
+}
+
+func TestCompile_WithoutPersistedQueries(t *testing.T) {
+	code, manifest := compileTestOperations(t, testOperations, "")
+	if manifest != "" {
+		t.Errorf("expected no manifest, got %q", manifest)
+	}
+	if !strings.Contains(code, persistedQueries) {
+		t.Errorf("expected persisted queries to be disabled in the runtime:\n%s", code)
+	}
+	if call := "GraphQL.GetUser_query, schema.GetUser_Schema, variables, schema.GetUser_Variables_Schema, options);"; !strings.Contains(code, call) {
+		t.Errorf("expected %q without document id in:\n%s", call, code)
+	}
+}
+
+func TestIsPersistedQueryManifest(t *testing.T) {
+	_, manifest := compileTestOperations(t, testOperations, config.PersistedQueriesTrusted)
+	for _, test := range []struct {
+		name     string
+		data     string
+		expected bool
+	}{
+		{"generated", manifest, true},
+		{"without operations", "{}\n", true},
+		{"changed document", strings.Replace(manifest, "GetUser", "GetMe", 1), false},
+		{"other ids", `{"GetUser": "query GetUser { me { id } }"}`, false},
+		{"not an object", `["query GetUser { me { id } }"]`, false},
+		{"not json", "query GetUser { me { id } }", false},
+	} {
+		if got := IsPersistedQueryManifest([]byte(test.data)); got != test.expected {
+			t.Errorf("%s: expected %v, got %v", test.name, test.expected, got)
+		}
+	}
+}
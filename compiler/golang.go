@@ -0,0 +1,85 @@
+package compiler
+
+import (
+	"bytes"
+	_ "embed"
+	"fmt"
+	"go/format"
+	"gqlc/config"
+	"gqlc/parser"
+	"gqlc/schema"
+	"io"
+	"path/filepath"
+	"strings"
+	"unicode"
+)
+
+var (
+	//go:embed runtime.go.tmpl
+	GoRuntime string
+)
+
+const goPlaceholder = "\n// GQLC_OPERATIONS_PLACEHOLDER"
+
+const goHeader = "// Code generated by gqlc. DO NOT EDIT.\n\n"
+
+// generateGo writes the Go types and the Go client for the operations.
+// Both files are formatted with gofmt.
+func generateGo(cfg config.Config, sch *schema.Schema, doc parser.Document, operations []parser.AST, genSchemaCode io.Writer, genOperationCode io.Writer) error {
+	pkg := goPackageName(cfg.Output)
+
+	var schemaCode bytes.Buffer
+	fmt.Fprintf(&schemaCode, "%spackage %s\n\n", goHeader, pkg)
+	if err := sch.GenerateGo(operations, &schemaCode); err != nil {
+		return fmt.Errorf("failed to generate Go types: %w", err)
+	}
+
+	placeholderIndex := strings.Index(GoRuntime, goPlaceholder)
+	if placeholderIndex == -1 {
+		return fmt.Errorf("runtime template missing %s", strings.TrimSpace(goPlaceholder))
+	}
+
+	var operationCode bytes.Buffer
+	fmt.Fprintf(&operationCode, "%spackage %s\n\n", goHeader, pkg)
+	operationCode.WriteString(GoRuntime[:placeholderIndex])
+	if err := sch.StripClientFields(sch.AddTypenames(doc)).GenerateGoMethod(&operationCode); err != nil {
+		return fmt.Errorf("failed to generate Go operation method: %w", err)
+	}
+	operationCode.WriteString(GoRuntime[placeholderIndex+len(goPlaceholder):])
+
+	for _, out := range []struct {
+		code *bytes.Buffer
+		w    io.Writer
+	}{
+		{&schemaCode, genSchemaCode},
+		{&operationCode, genOperationCode},
+	} {
+		formatted, err := format.Source(out.code.Bytes())
+		if err != nil {
+			return fmt.Errorf("failed to format generated Go code: %w", err)
+		}
+		if _, err := out.w.Write(formatted); err != nil {
+			return fmt.Errorf("failed to write generated Go code to output: %w", err)
+		}
+	}
+
+	return nil
+}
+
+// goPackageName returns the configured package name,
+// or the name of the output directory if no package is configured
+func goPackageName(output config.Output) string {
+	if output.Package != "" {
+		return output.Package
+	}
+	name := strings.Map(func(r rune) rune {
+		if unicode.IsLetter(r) || unicode.IsDigit(r) || r == '_' {
+			return unicode.ToLower(r)
+		}
+		return -1
+	}, filepath.Base(output.Location))
+	if name == "" || unicode.IsDigit(rune(name[0])) {
+		return "gqlc" + name
+	}
+	return name
+}
@@ -1,36 +1,703 @@
+// The endpoint of input.schemas or output.endpoint, used if the client is created without a url
+const defaultEndpoint: string | undefined = undefined;
+// output.persisted_queries, "apq" sends the query only if the server doesn't know its hash, "trusted" never sends it
+const persistedQueries: "apq" | "trusted" | undefined = undefined;
+
+export type GraphQLClientOptions = {
+  /** The GraphQL endpoint, defaults to the endpoint the client was generated for */
+  url?: string;
+  /** Headers sent with every request */
+  headers?: Record<string, string>;
+  /** The fetch implementation, defaults to the global fetch */
+  fetch?: typeof fetch;
+  /** Middleware every request passes through, in order */
+  middleware?: GraphQLMiddleware[];
+  /** The default timeoutMs of calls */
+  timeoutMs?: number;
+  /** The default retry policy of calls */
+  retry?: GraphQLRetryPolicy | number | false;
+  subscriptions?: GraphQLSubscriptionClientOptions;
+};
+
+export type GraphQLSubscriptionClientOptions = {
+  /** "ws" (default) uses the graphql-transport-ws WebSocket protocol, "sse" GraphQL over Server-Sent Events */
+  transport?: "ws" | "sse";
+  /** The subscription endpoint, defaults to the url of the client (with ws:// or wss:// for WebSockets) */
+  url?: string;
+  /** The payload of the connection_init message of WebSockets, e.g. an auth token */
+  connectionParams?: Record<string, unknown> | (() => Record<string, unknown> | Promise<Record<string, unknown>>);
+  /** The WebSocket implementation, defaults to the global WebSocket */
+  WebSocket?: typeof WebSocket;
+};
+
+export type GraphQLSubscriptionOptions = {
+  /** Overrides the subscription endpoint of the client for this subscription */
+  url?: string;
+  /** Headers sent with the request of SSE subscriptions in addition to the headers of the client */
+  headers?: Record<string, string>;
+  /**
+   * "none" (default) ends the subscription with a GraphQLResponseError if an event has errors,
+   * "all" passes every event as { data, errors } and only ends the subscription on errors of the subscription itself
+   */
+  errorPolicy?: "none" | "all";
+  /** Ends the subscription with the reason of the signal as error */
+  signal?: AbortSignal;
+};
+
+export type GraphQLObserver<T> = {
+  next?: (value: T) => void;
+  error?: (error: unknown) => void;
+  complete?: () => void;
+};
+
+/**
+ * The events of a subscription, as AsyncIterable or observable. Every iteration and every
+ * call of subscribe starts its own subscription, which ends when the loop is left or unsubscribe is called.
+ */
+export class GraphQLSubscription<T> implements AsyncIterable<T> {
+  private readonly start: (sink: Required<GraphQLObserver<T>>) => () => void;
+
+  /** start subscribes and returns the function that unsubscribes */
+  constructor(start: (sink: Required<GraphQLObserver<T>>) => () => void) {
+    this.start = start;
+  }
+
+  public subscribe(observer: GraphQLObserver<T>): { unsubscribe: () => void } {
+    let closed = false;
+    let stop = () => {};
+    const close = () => {
+      const wasClosed = closed;
+      closed = true;
+      if (!wasClosed) {
+        stop();
+      }
+      return !wasClosed;
+    };
+    stop = this.start({
+      next: (value) => {
+        if (!closed) {
+          observer.next?.(value);
+        }
+      },
+      error: (error) => {
+        if (close()) {
+          observer.error?.(error);
+        }
+      },
+      complete: () => {
+        if (close()) {
+          observer.complete?.();
+        }
+      },
+    });
+    return { unsubscribe: () => void close() };
+  }
+
+  public [Symbol.asyncIterator](): AsyncIterator<T> {
+    const values: T[] = [];
+    let done = false;
+    let failure: { error: unknown } | undefined;
+    let wake: (() => void) | undefined;
+    const notify = () => {
+      wake?.();
+      wake = undefined;
+    };
+    const subscription = this.subscribe({
+      next: (value) => {
+        values.push(value);
+        notify();
+      },
+      error: (error) => {
+        failure = { error };
+        notify();
+      },
+      complete: () => {
+        done = true;
+        notify();
+      },
+    });
+
+    return {
+      next: async (): Promise<IteratorResult<T>> => {
+        for (;;) {
+          if (values.length > 0) {
+            return { value: values.shift()!, done: false };
+          }
+          if (failure) {
+            const { error } = failure;
+            failure = undefined;
+            done = true;
+            throw error;
+          }
+          if (done) {
+            return { value: undefined, done: true };
+          }
+          await new Promise<void>((resolve) => (wake = resolve));
+        }
+      },
+      return: async (): Promise<IteratorResult<T>> => {
+        subscription.unsubscribe();
+        done = true;
+        values.length = 0;
+        return { value: undefined, done: true };
+      },
+    };
+  }
+}
+
+/**
+ * Failed requests (network errors, timeouts and 5xx responses) are retried with exponential backoff.
+ * A number is the number of retries with the default delays.
+ */
+export type GraphQLRetryPolicy = {
+  /** The number of retries */
+  retries: number;
+  /** The delay before the first retry, doubled for each further retry (default 300) */
+  delayMs?: number;
+  /** The maximum delay between retries (default 10000) */
+  maxDelayMs?: number;
+  /** Mutations are not retried unless this is set, they may have been executed before the request failed */
+  mutations?: boolean;
+};
+
+/** A request as seen by middleware */
+export type GraphQLRequest = {
+  url: string;
+  operationType: "query" | "mutation" | "subscription";
+  /** The name of the operation, undefined for anonymous operations */
+  operationName: string | undefined;
+  query: string;
+  /** The SHA-256 hash of the query, sent as persisted query if persisted queries are enabled */
+  documentId: string | undefined;
+  /** False if only the persisted query hash is sent */
+  includeQuery: boolean;
+  variables: Record<string, any> | undefined;
+  headers: Record<string, string>;
+  /** Aborts the request, combines the signal and timeout of the call */
+  signal: AbortSignal | undefined;
+};
+
+/**
+ * Middleware may change the request before passing it on with next, inspect or replace the response,
+ * or call next again (e.g. after refreshing a token). The last next sends the request with fetch.
+ */
+export type GraphQLMiddleware = (
+  request: GraphQLRequest,
+  next: (request: GraphQLRequest) => Promise<Response>,
+) => Promise<Response>;
+
+export type GraphQLCallOptions = {
+  /** Overrides the endpoint of the client for this call */
+  url?: string;
+  /** Headers sent with this request in addition to the headers of the client */
+  headers?: Record<string, string>;
+  /**
+   * "none" (default) throws a GraphQLResponseError if the response has errors,
+   * "all" returns the (partial) data together with the errors
+   */
+  errorPolicy?: "none" | "all";
+  /** Aborts the call */
+  signal?: AbortSignal;
+  /** Aborts each attempt after this many milliseconds */
+  timeoutMs?: number;
+  /** Overrides the retry policy of the client, false disables retries */
+  retry?: GraphQLRetryPolicy | number | false;
+};
+
+/** The result of a call or subscription event with errorPolicy "all" */
+export type GraphQLResult<T> = {
+  /** The data, null if the server could not execute the operation */
+  data: T | null;
+  errors: GraphQLError[];
+};
+
+/** The return type of a call or subscription event, depending on its errorPolicy. Both are possible if the errorPolicy isn't known statically. */
+export type GraphQLReturn<T, O extends { errorPolicy?: "none" | "all" } | undefined> = O extends { errorPolicy: "all" }
+  ? GraphQLResult<T>
+  : O extends { errorPolicy?: "none" } | undefined
+    ? T
+    : T | GraphQLResult<T>;
+
+/** Codes common GraphQL servers set in extensions.code, other codes are accepted as well */
+export type GraphQLErrorCode =
+  | "GRAPHQL_PARSE_FAILED"
+  | "GRAPHQL_VALIDATION_FAILED"
+  | "BAD_USER_INPUT"
+  | "UNAUTHENTICATED"
+  | "FORBIDDEN"
+  | "PERSISTED_QUERY_NOT_FOUND"
+  | "PERSISTED_QUERY_NOT_SUPPORTED"
+  | "OPERATION_RESOLUTION_FAILURE"
+  | "INTERNAL_SERVER_ERROR"
+  | (string & {});
+
+export type GraphQLErrorLocation = { line: number; column: number };
+
+export type GraphQLErrorExtensions = { code?: GraphQLErrorCode; [key: string]: unknown };
+
+/** An entry of the errors of a GraphQL response */
+export class GraphQLError extends Error {
+  override readonly name = "GraphQLError";
+  /** The path of the response field that failed */
+  readonly path: ReadonlyArray<string | number> | undefined;
+  /** The locations in the operation document the error belongs to */
+  readonly locations: ReadonlyArray<GraphQLErrorLocation> | undefined;
+  readonly extensions: GraphQLErrorExtensions;
+
+  constructor(error: {
+    message: string;
+    path?: ReadonlyArray<string | number>;
+    locations?: ReadonlyArray<GraphQLErrorLocation>;
+    extensions?: GraphQLErrorExtensions;
+  }) {
+    super(error.message);
+    this.path = error.path;
+    this.locations = error.locations;
+    this.extensions = { ...error.extensions };
+  }
+
+  get code(): GraphQLErrorCode | undefined {
+    return typeof this.extensions.code === "string" ? this.extensions.code : undefined;
+  }
+
+  public hasCode(...codes: GraphQLErrorCode[]): boolean {
+    return this.code !== undefined && codes.includes(this.code);
+  }
+}
+
+/** Thrown if the server responds with a non-2xx status or with errors */
+export class GraphQLResponseError extends Error {
+  override readonly name = "GraphQLResponseError";
+  /** The errors of the response, empty if the server sent none */
+  readonly errors: GraphQLError[];
+  /** The (partial) data of the response, as the server sent it */
+  readonly data: unknown;
+  /** The HTTP response, undefined for errors of WebSocket subscriptions */
+  readonly response: Response | undefined;
+  readonly status: number | undefined;
+
+  constructor(response: Response | undefined, errors: GraphQLError[], data: unknown) {
+    super(
+      errors.length > 0
+        ? errors.map((error) => error.message).join("\n")
+        : response?.statusText || `HTTP ${response?.status}`,
+    );
+    this.response = response;
+    this.status = response?.status;
+    this.errors = errors;
+    this.data = data;
+  }
+
+  /** Returns whether any error has one of the codes */
+  public hasCode(...codes: GraphQLErrorCode[]): boolean {
+    return this.errors.some((error) => error.hasCode(...codes));
+  }
+}
+
+/** Returns whether the error is a GraphQLResponseError, optionally with an error of one of the codes */
+export function isGraphQLResponseError(error: unknown, ...codes: GraphQLErrorCode[]): error is GraphQLResponseError {
+  return error instanceof GraphQLResponseError && (codes.length === 0 || error.hasCode(...codes));
+}
+
 export class GraphQL {
+  private readonly url: string | undefined;
+  private readonly headers: Record<string, string>;
+  private readonly fetch: typeof fetch;
+  private readonly middleware: GraphQLMiddleware[];
+  private readonly timeoutMs: number | undefined;
+  private readonly retry: GraphQLRetryPolicy | number | false;
+  private readonly subscriptions: GraphQLSubscriptionClientOptions;
   private authHeaders: Record<string, string> = {};
 
+  constructor(options: GraphQLClientOptions = {}) {
+    this.url = options.url ?? defaultEndpoint;
+    this.headers = { ...options.headers };
+    this.fetch = options.fetch ?? ((input, init) => fetch(input, init));
+    this.middleware = [...(options.middleware ?? [])];
+    this.timeoutMs = options.timeoutMs;
+    this.retry = options.retry ?? false;
+    this.subscriptions = { ...options.subscriptions };
+  }
+
   public authenticate(headers: Record<string, string>) {
     this.authHeaders = { ...headers };
   }
 
+  /** Appends middleware to the chain */
+  public use(...middleware: GraphQLMiddleware[]): this {
+    this.middleware.push(...middleware);
+    return this;
+  }
+
+  /** Passes the request through the middleware and sends it */
+  private send(request: GraphQLRequest, index = 0): Promise<Response> {
+    const middleware = this.middleware[index];
+    if (middleware) {
+      return middleware(request, (next) => this.send(next, index + 1));
+    }
+    // Queries sent as hash only are GET requests, which CDNs can cache
+    if (!request.includeQuery && request.operationType === "query") {
+      const params = new URLSearchParams();
+      for (const [key, value] of Object.entries({ ...requestBody(request), operationName: request.operationName })) {
+        if (value !== undefined) {
+          params.set(key, typeof value === "string" ? value : JSON.stringify(value));
+        }
+      }
+      return this.fetch(`${request.url}${request.url.includes("?") ? "&" : "?"}${params}`, {
+        method: "GET",
+        headers: request.headers,
+        signal: request.signal,
+      });
+    }
+    return this.fetch(request.url, {
+      method: "POST",
+      headers: request.headers,
+      signal: request.signal,
+      body: JSON.stringify(requestBody(request)),
+    });
+  }
+
+  /** Sends the request, retrying network errors, timeouts and 5xx responses according to the retry policy */
+  private async sendWithRetries(request: GraphQLRequest, options: GraphQLCallOptions): Promise<Response> {
+    let retry = options.retry ?? this.retry;
+    if (typeof retry === "number") {
+      retry = { retries: retry };
+    }
+    if (retry && request.operationType === "mutation" && !retry.mutations) {
+      retry = false;
+    }
+    const timeoutMs = options.timeoutMs ?? this.timeoutMs;
+
+    for (let attempt = 0; ; attempt++) {
+      const signals = [options.signal, timeoutMs === undefined ? undefined : AbortSignal.timeout(timeoutMs)].filter(
+        (signal) => signal !== undefined,
+      );
+      const canRetry = retry !== false && attempt < retry.retries;
+      try {
+        const response = await this.send({
+          ...request,
+          signal: signals.length > 1 ? AbortSignal.any(signals) : signals[0],
+        });
+        if (response.status < 500 || !canRetry) {
+          return response;
+        }
+        // Releases the connection of the discarded response
+        await response.body?.cancel();
+      } catch (err) {
+        // Aborted by the caller
+        if (!canRetry || options.signal?.aborted) {
+          throw err;
+        }
+      }
+
+      const policy = retry as GraphQLRetryPolicy;
+      const delayMs = Math.min((policy.delayMs ?? 300) * 2 ** attempt, policy.maxDelayMs ?? 10000);
+      await sleep(delayMs / 2 + (Math.random() * delayMs) / 2, options.signal);
+    }
+  }
+
   private async execute<T>(
-    url: string,
     query: string,
     outputSchema: { parse: (data: any) => T },
-    variables?: Record<string, any>,
-  ): Promise<T> {
-    const response = await fetch(url, {
-      method: "POST",
+    variables: Record<string, any> | undefined,
+    variablesSchema: { parse: (data: any) => Record<string, any> } | undefined,
+    options: GraphQLCallOptions = {},
+    documentId?: string,
+  ): Promise<any> {
+    const url = options.url ?? this.url;
+    if (!url) {
+      throw new Error("No GraphQL endpoint, pass url in the client options");
+    }
+
+    if (variablesSchema) {
+      variables = variablesSchema.parse(variables ?? {});
+    }
+
+    const operation = /^\s*(query|mutation|subscription)\b\s*(\w+)?/.exec(query);
+    const request: GraphQLRequest = {
+      url,
+      operationType: (operation?.[1] ?? "query") as GraphQLRequest["operationType"],
+      operationName: operation?.[2],
+      query,
+      documentId,
+      includeQuery: !documentId,
+      variables,
       headers: {
         "Content-Type": "application/json",
+        ...this.headers,
         ...this.authHeaders,
+        ...options.headers,
       },
-      body: JSON.stringify({
-        query,
-        variables,
-      }),
-    });
+      signal: options.signal,
+    };
+    let response = await this.sendWithRetries(request, options);
+    let { data, errors } = await readResult(response);
+
+    // Automatic persisted queries send the query once the server asks for it
+    if (persistedQueries === "apq" && !request.includeQuery && errors.some(isPersistedQueryNotFound)) {
+      response = await this.sendWithRetries({ ...request, includeQuery: true }, options);
+      ({ data, errors } = await readResult(response));
+    }
+    if (!response.ok || (errors.length > 0 && options.errorPolicy !== "all")) {
+      throw new GraphQLResponseError(response, errors, data);
+    }
+    if (options.errorPolicy === "all") {
+      return { data: data === null ? null : outputSchema.parse(data), errors } satisfies GraphQLResult<T>;
+    }
+    return outputSchema.parse(data);
+  }
 
-    if (!response.ok) {
-      throw new Error(response.statusText);
+  private subscribe<T>(
+    query: string,
+    outputSchema: { parse: (data: any) => T },
+    variables: Record<string, any> | undefined,
+    variablesSchema: { parse: (data: any) => Record<string, any> } | undefined,
+    options: GraphQLSubscriptionOptions = {},
+    documentId?: string,
+  ): GraphQLSubscription<any> {
+    const url = options.url ?? this.subscriptions.url ?? this.url;
+    if (!url) {
+      throw new Error("No GraphQL endpoint, pass url in the client options");
     }
 
-    const data = await response.json();
+    if (variablesSchema) {
+      variables = variablesSchema.parse(variables ?? {});
+    }
 
-    return outputSchema.parse(data.data);
+    const request: GraphQLRequest = {
+      url,
+      operationType: "subscription",
+      operationName: /^\s*subscription\s*(\w+)?/.exec(query)?.[1],
+      query,
+      documentId,
+      // Subscriptions can't fall back to the query, it's sent along unless only trusted documents are accepted
+      includeQuery: !documentId || persistedQueries !== "trusted",
+      variables,
+      headers: {
+        "Content-Type": "application/json",
+        Accept: "text/event-stream",
+        ...this.headers,
+        ...this.authHeaders,
+        ...options.headers,
+      },
+      signal: undefined,
+    };
+
+    return new GraphQLSubscription<any>((sink) => {
+      // Errors of an event don't end the subscription on the server, only the errorPolicy "none" ends it
+      const emit = (result: ExecutionResult) => {
+        const errors = (result.errors ?? []).map((error) => new GraphQLError(error));
+        if (errors.length > 0 && options.errorPolicy !== "all") {
+          sink.error(new GraphQLResponseError(undefined, errors, result.data ?? null));
+          return;
+        }
+        try {
+          if (options.errorPolicy === "all") {
+            const data = result.data ?? null;
+            sink.next({ data: data === null ? null : outputSchema.parse(data), errors } satisfies GraphQLResult<T>);
+          } else {
+            sink.next(outputSchema.parse(result.data));
+          }
+        } catch (err) {
+          sink.error(err);
+        }
+      };
+
+      const signal = options.signal;
+      if (signal?.aborted) {
+        queueMicrotask(() => sink.error(signal.reason));
+        return () => {};
+      }
+      const abort = () => sink.error(signal?.reason);
+      signal?.addEventListener("abort", abort, { once: true });
+
+      const stop =
+        this.subscriptions.transport === "sse"
+          ? this.subscribeSSE(request, emit, sink)
+          : this.subscribeWebSocket(request, emit, sink);
+      return () => {
+        signal?.removeEventListener("abort", abort);
+        stop();
+      };
+    });
+  }
+
+  /** Subscribes over the graphql-transport-ws protocol, every subscription has its own connection */
+  private subscribeWebSocket(
+    request: GraphQLRequest,
+    emit: (result: ExecutionResult) => void,
+    sink: { error: (error: unknown) => void; complete: () => void },
+  ): () => void {
+    const WebSocketImpl = this.subscriptions.WebSocket ?? WebSocket;
+    const socket = new WebSocketImpl(request.url.replace(/^http/, "ws"), "graphql-transport-ws");
+    const id = "1";
+    let closed = false;
+
+    socket.onopen = async () => {
+      const params = this.subscriptions.connectionParams;
+      try {
+        const payload = typeof params === "function" ? await params() : params;
+        socket.send(JSON.stringify({ type: "connection_init", payload }));
+      } catch (err) {
+        sink.error(err);
+      }
+    };
+    socket.onmessage = (event) => {
+      let message: any;
+      try {
+        message = JSON.parse(String(event.data));
+      } catch (err) {
+        sink.error(new Error(`Invalid WebSocket message: ${String(event.data)}`, { cause: err }));
+        return;
+      }
+      switch (message.type) {
+        case "connection_ack":
+          socket.send(
+            JSON.stringify({
+              id,
+              type: "subscribe",
+              payload: { ...requestBody(request), operationName: request.operationName },
+            }),
+          );
+          break;
+        case "ping":
+          socket.send(JSON.stringify({ type: "pong" }));
+          break;
+        case "next":
+          emit(message.payload);
+          break;
+        case "error":
+          closed = true;
+          sink.error(new GraphQLResponseError(undefined, message.payload.map((error: any) => new GraphQLError(error)), null));
+          break;
+        case "complete":
+          closed = true;
+          sink.complete();
+          break;
+      }
+    };
+    socket.onclose = (event) => {
+      if (!closed) {
+        closed = true;
+        sink.error(new Error(`WebSocket closed with ${event.code}${event.reason ? `: ${event.reason}` : ""}`));
+      }
+    };
+
+    return () => {
+      // 1 is OPEN
+      if (!closed && socket.readyState === 1) {
+        socket.send(JSON.stringify({ id, type: "complete" }));
+      }
+      closed = true;
+      socket.close(1000);
+    };
+  }
+
+  /** Subscribes over GraphQL over Server-Sent Events (distinct connections mode), the request passes through the middleware */
+  private subscribeSSE(
+    request: GraphQLRequest,
+    emit: (result: ExecutionResult) => void,
+    sink: { error: (error: unknown) => void; complete: () => void },
+  ): () => void {
+    const controller = new AbortController();
+
+    const read = async () => {
+      const response = await this.send({ ...request, signal: controller.signal });
+      if (!response.ok || !response.body) {
+        const { data, errors } = await readResult(response);
+        throw new GraphQLResponseError(response, errors, data);
+      }
+
+      const reader = response.body.pipeThrough(new TextDecoderStream()).getReader();
+      let buffer = "";
+      for (;;) {
+        const { value, done } = await reader.read();
+        if (done) {
+          break;
+        }
+        buffer += value.replace(/\r\n?/g, "\n");
+        for (let end = buffer.indexOf("\n\n"); end >= 0; end = buffer.indexOf("\n\n")) {
+          const block = buffer.slice(0, end);
+          buffer = buffer.slice(end + 2);
+
+          let event = "message";
+          const data: string[] = [];
+          for (const line of block.split("\n")) {
+            const colon = line.indexOf(":");
+            const field = colon < 0 ? line : line.slice(0, colon);
+            const fieldValue = colon < 0 ? "" : line.slice(colon + 1).replace(/^ /, "");
+            if (field === "event") {
+              event = fieldValue;
+            } else if (field === "data") {
+              data.push(fieldValue);
+            }
+          }
+          if (event === "next") {
+            emit(JSON.parse(data.join("\n")));
+          } else if (event === "complete") {
+            return;
+          }
+        }
+      }
+    };
+
+    read().then(
+      () => sink.complete(),
+      (err) => {
+        if (!controller.signal.aborted) {
+          sink.error(err);
+        }
+      },
+    );
+    return () => controller.abort();
   }
 
   // GQLC_OPERATIONS_PLACEHOLDER
 }
+
+/** Returns the body of a request, with the persisted query extension if the request has a document id */
+function requestBody(request: GraphQLRequest) {
+  return {
+    query: request.includeQuery ? request.query : undefined,
+    variables: request.variables,
+    extensions: request.documentId ? { persistedQuery: { version: 1, sha256Hash: request.documentId } } : undefined,
+  };
+}
+
+function isPersistedQueryNotFound(error: GraphQLError): boolean {
+  return error.message === "PersistedQueryNotFound" || error.hasCode("PERSISTED_QUERY_NOT_FOUND");
+}
+
+type ExecutionResult = { data?: unknown; errors?: ConstructorParameters<typeof GraphQLError>[0][] };
+
+/** Reads the data and errors of a response. Servers following the GraphQL over HTTP specification send errors with non-2xx statuses too. */
+async function readResult(response: Response): Promise<{ data: unknown; errors: GraphQLError[] }> {
+  let body: ExecutionResult | undefined;
+  try {
+    body = await response.json();
+  } catch (err) {
+    if (response.ok) {
+      throw err;
+    }
+  }
+  return {
+    data: body?.data ?? null,
+    errors: (body?.errors ?? []).map((error) => new GraphQLError(error)),
+  };
+}
+
+/** Resolves after the delay, rejects if the signal aborts first */
+function sleep(ms: number, signal: AbortSignal | undefined): Promise<void> {
+  return new Promise((resolve, reject) => {
+    signal?.throwIfAborted();
+    const timeout = setTimeout(() => {
+      signal?.removeEventListener("abort", abort);
+      resolve();
+    }, ms);
+    const abort = () => {
+      clearTimeout(timeout);
+      reject(signal?.reason);
+    };
+    signal?.addEventListener("abort", abort, { once: true });
+  });
+}
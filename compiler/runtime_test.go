@@ -0,0 +1,22 @@
+package compiler
+
+import (
+	"os/exec"
+	"testing"
+)
+
+// TestRuntime runs the tests of the TypeScript runtime in runtime_test.ts with Node.js
+func TestRuntime(t *testing.T) {
+	node, err := exec.LookPath("node")
+	if err != nil {
+		t.Skip("node is not installed")
+	}
+	if err := exec.Command(node, "--experimental-strip-types", "--eval", "").Run(); err != nil {
+		t.Skip("node can't run TypeScript, it needs version 22.6 or later")
+	}
+
+	out, err := exec.Command(node, "--experimental-strip-types", "--no-warnings", "--test", "runtime_test.ts").CombinedOutput()
+	if err != nil {
+		t.Fatalf("runtime tests failed: %v\n%s", err, out)
+	}
+}
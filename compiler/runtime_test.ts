@@ -0,0 +1,546 @@
+// Tests of the TypeScript runtime, run by TestRuntime with node --test
+import assert from "node:assert/strict";
+import crypto from "node:crypto";
+import http from "node:http";
+import type { Socket } from "node:net";
+import { describe, test } from "node:test";
+import { GraphQL, GraphQLError, GraphQLResponseError, isGraphQLResponseError, type GraphQLClientOptions } from "./runtime.ts";
+
+const url = "http://localhost/graphql";
+const passthrough = { parse: (data: any) => data };
+
+type FetchCall = { url: string; init: RequestInit };
+
+/** Returns a fetch that answers the calls with the responses in order and records them */
+function mockFetch(...responses: (Response | Error | ((init: RequestInit) => Promise<Response>))[]) {
+  const calls: FetchCall[] = [];
+  const fetch = async (input: RequestInfo | URL, init: RequestInit = {}) => {
+    calls.push({ url: String(input), init });
+    const response = responses.shift();
+    if (response === undefined) {
+      throw new Error("unexpected fetch");
+    }
+    if (response instanceof Error) {
+      throw response;
+    }
+    return typeof response === "function" ? response(init) : response;
+  };
+  return { fetch: fetch as typeof globalThis.fetch, calls };
+}
+
+function json(body: unknown, status = 200) {
+  return new Response(JSON.stringify(body), { status, headers: { "Content-Type": "application/json" } });
+}
+
+/** Calls the private execute method like a generated method without variables */
+function execute(client: GraphQL, query: string, options?: object): Promise<any> {
+  return (client as any).execute(query, passthrough, undefined, undefined, options);
+}
+
+function newClient(options: GraphQLClientOptions) {
+  return new GraphQL({ url, ...options });
+}
+
+describe("errors", () => {
+  test("a 200 response with errors throws a GraphQLResponseError", async () => {
+    const { fetch } = mockFetch(
+      json({ data: null, errors: [{ message: "Not logged in", path: ["me"], extensions: { code: "UNAUTHENTICATED" } }] }),
+    );
+    const err = await execute(newClient({ fetch }), "query Me { me { id } }").catch((err) => err);
+    assert.ok(err instanceof GraphQLResponseError);
+    assert.equal(err.message, "Not logged in");
+    assert.equal(err.status, 200);
+    assert.equal(err.data, null);
+    assert.ok(err.errors[0] instanceof GraphQLError);
+    assert.deepEqual(err.errors[0].path, ["me"]);
+    assert.equal(err.errors[0].code, "UNAUTHENTICATED");
+  });
+
+  test("errorPolicy all returns the partial data with the errors", async () => {
+    const { fetch } = mockFetch(json({ data: { me: { id: "1", avatar: null } }, errors: [{ message: "No avatar", path: ["me", "avatar"] }] }));
+    const result = await execute(newClient({ fetch }), "query Me { me { id avatar } }", { errorPolicy: "all" });
+    assert.deepEqual(result.data, { me: { id: "1", avatar: null } });
+    assert.equal(result.errors.length, 1);
+    assert.equal(result.errors[0].message, "No avatar");
+  });
+
+  test("errorPolicy all returns the data without errors", async () => {
+    const { fetch } = mockFetch(json({ data: { me: { id: "1" } } }));
+    const result = await execute(newClient({ fetch }), "query Me { me { id } }", { errorPolicy: "all" });
+    assert.deepEqual(result, { data: { me: { id: "1" } }, errors: [] });
+  });
+
+  test("a non-2xx response with a GraphQL body throws its errors", async () => {
+    for (const errorPolicy of ["none", "all"]) {
+      const { fetch } = mockFetch(json({ errors: [{ message: "Syntax Error", extensions: { code: "GRAPHQL_PARSE_FAILED" } }] }, 400));
+      const err = await execute(newClient({ fetch }), "query Me { me { id }", { errorPolicy }).catch((err) => err);
+      assert.ok(err instanceof GraphQLResponseError, errorPolicy);
+      assert.equal(err.status, 400);
+      assert.equal(err.message, "Syntax Error");
+      assert.ok(err.response instanceof Response);
+      assert.ok(err.hasCode("GRAPHQL_PARSE_FAILED"));
+    }
+  });
+
+  test("a non-2xx response without a GraphQL body throws its status", async () => {
+    const { fetch } = mockFetch(new Response("<html>Bad Gateway</html>", { status: 502, statusText: "Bad Gateway" }));
+    const err = await execute(newClient({ fetch }), "query Me { me { id } }").catch((err) => err);
+    assert.ok(err instanceof GraphQLResponseError);
+    assert.equal(err.message, "Bad Gateway");
+    assert.deepEqual(err.errors, []);
+  });
+
+  test("hasCode matches any of the codes of any error", async () => {
+    const { fetch } = mockFetch(
+      json({ data: null, errors: [{ message: "a", extensions: { code: "FORBIDDEN" } }, { message: "b" }, { message: "c", extensions: { code: 42 } }] }),
+    );
+    const err = await execute(newClient({ fetch }), "query Me { me { id } }").catch((err) => err);
+    assert.ok(err.hasCode("UNAUTHENTICATED", "FORBIDDEN"));
+    assert.ok(!err.hasCode("UNAUTHENTICATED"));
+    assert.equal(err.errors[1].code, undefined);
+    assert.equal(err.errors[2].code, undefined);
+    assert.ok(isGraphQLResponseError(err));
+    assert.ok(isGraphQLResponseError(err, "FORBIDDEN"));
+    assert.ok(!isGraphQLResponseError(err, "BAD_USER_INPUT"));
+    assert.ok(!isGraphQLResponseError(new Error("FORBIDDEN"), "FORBIDDEN"));
+  });
+});
+
+describe("middleware", () => {
+  test("without middleware the request is sent with fetch", async () => {
+    const { fetch, calls } = mockFetch(json({ data: { user: { id: "1" } } }));
+    const client = newClient({ fetch, headers: { "X-Client": "web" } });
+    client.authenticate({ Authorization: "Bearer token" });
+    const variables = { id: "1" };
+    const result = await (client as any).execute("query User($id: ID!) { user(id: $id) { id } }", passthrough, variables, passthrough, {
+      headers: { "X-Request-Id": "42" },
+    });
+    assert.deepEqual(result, { user: { id: "1" } });
+    assert.equal(calls.length, 1);
+    assert.equal(calls[0].url, url);
+    assert.equal(calls[0].init.method, "POST");
+    assert.equal(calls[0].init.signal, undefined);
+    assert.deepEqual(calls[0].init.headers, {
+      "Content-Type": "application/json",
+      "X-Client": "web",
+      Authorization: "Bearer token",
+      "X-Request-Id": "42",
+    });
+    assert.deepEqual(JSON.parse(String(calls[0].init.body)), { query: "query User($id: ID!) { user(id: $id) { id } }", variables });
+  });
+
+  test("middleware runs in order, constructor middleware before use", async () => {
+    const log: string[] = [];
+    const trace = (name: string) => async (request: any, next: (request: any) => Promise<Response>) => {
+      log.push(`${name} ${request.operationType} ${request.operationName}`);
+      const response = await next({ ...request, headers: { ...request.headers, "X-Trace": `${request.headers["X-Trace"] ?? ""}${name}` } });
+      log.push(`${name} ${response.status}`);
+      return response;
+    };
+    const { fetch, calls } = mockFetch(json({ data: {} }));
+    const client = newClient({ fetch, middleware: [trace("a"), trace("b")] }).use(trace("c"));
+    await execute(client, "mutation Save { save }");
+    assert.deepEqual(log, ["a mutation Save", "b mutation Save", "c mutation Save", "c 200", "b 200", "a 200"]);
+    assert.equal((calls[0].init.headers as Record<string, string>)["X-Trace"], "abc");
+  });
+
+  test("middleware can call next again after refreshing a token", async () => {
+    const { fetch, calls } = mockFetch(new Response("Unauthorized", { status: 401 }), json({ data: { me: { id: "1" } } }));
+    let attempts = 0;
+    const client = newClient({
+      fetch,
+      middleware: [
+        async (request, next) => {
+          const response = await next(request);
+          if (response.status !== 401) {
+            return response;
+          }
+          return next({ ...request, headers: { ...request.headers, Authorization: "Bearer refreshed" } });
+        },
+        async (request, next) => {
+          attempts++;
+          return next(request);
+        },
+      ],
+    });
+    assert.deepEqual(await execute(client, "query Me { me { id } }"), { me: { id: "1" } });
+    assert.equal(attempts, 2);
+    assert.equal(calls.length, 2);
+    assert.equal((calls[0].init.headers as Record<string, string>).Authorization, undefined);
+    assert.equal((calls[1].init.headers as Record<string, string>).Authorization, "Bearer refreshed");
+  });
+
+  test("middleware can answer without calling next", async () => {
+    const { fetch, calls } = mockFetch();
+    const client = newClient({ fetch, middleware: [async () => json({ data: { cached: true } })] });
+    assert.deepEqual(await execute(client, "query Cached { cached }"), { cached: true });
+    assert.equal(calls.length, 0);
+  });
+});
+
+/** Returns a 5xx response that records whether its body was cancelled */
+function serverError(status = 503) {
+  const response = { cancelled: false, response: undefined as unknown as Response };
+  response.response = new Response(
+    new ReadableStream(
+      {
+        pull(controller) {
+          controller.close();
+        },
+        cancel() {
+          response.cancelled = true;
+        },
+      },
+      // Nothing is read before the body is consumed or cancelled
+      { highWaterMark: 0 },
+    ),
+    { status },
+  );
+  return response;
+}
+
+/** Answers like a fetch that never responds, until the signal of the request aborts */
+function hang(init: RequestInit): Promise<Response> {
+  return new Promise((_, reject) => {
+    // The timer of AbortSignal.timeout doesn't keep the process alive
+    const keepAlive = setInterval(() => {}, 1000);
+    init.signal?.addEventListener(
+      "abort",
+      () => {
+        clearInterval(keepAlive);
+        reject(init.signal?.reason);
+      },
+      { once: true },
+    );
+  });
+}
+
+describe("retries", () => {
+  test("5xx responses and network errors are retried with exponential backoff", async () => {
+    const first = serverError();
+    const second = serverError(500);
+    const times: number[] = [];
+    const { fetch, calls } = mockFetch(first.response, second.response, new TypeError("fetch failed"), json({ data: { me: null } }));
+    const timed: typeof globalThis.fetch = (input, init) => {
+      times.push(performance.now());
+      return fetch(input, init);
+    };
+    const client = newClient({ fetch: timed, retry: { retries: 3, delayMs: 20, maxDelayMs: 50 } });
+    assert.deepEqual(await execute(client, "query Me { me { id } }"), { me: null });
+    assert.equal(calls.length, 4);
+    assert.ok(first.cancelled && second.cancelled, "the bodies of retried responses are cancelled");
+    // The delays are 20, 40 and 50 (capped) with jitter down to half of them
+    for (const [i, delayMs] of [20, 40, 50].entries()) {
+      const waited = times[i + 1] - times[i];
+      assert.ok(waited >= delayMs / 2 - 1, `retry ${i + 1} waited ${waited}ms`);
+    }
+  });
+
+  test("the last 5xx response is returned once the retries are used up", async () => {
+    const { fetch, calls } = mockFetch(serverError().response, serverError().response);
+    const err = await execute(newClient({ fetch, retry: { retries: 1, delayMs: 1 } }), "query Me { me { id } }").catch((err) => err);
+    assert.ok(err instanceof GraphQLResponseError);
+    assert.equal(err.status, 503);
+    assert.equal(calls.length, 2);
+  });
+
+  test("4xx responses and calls without retry policy are not retried", async () => {
+    const { fetch, calls } = mockFetch(json({ errors: [{ message: "bad" }] }, 400), serverError().response);
+    const client = newClient({ fetch, retry: { retries: 3, delayMs: 1 } });
+    await assert.rejects(execute(client, "query Me { me { id } }"), GraphQLResponseError);
+    await assert.rejects(execute(client, "query Me { me { id } }", { retry: false }), GraphQLResponseError);
+    assert.equal(calls.length, 2);
+  });
+
+  test("mutations are only retried if the policy allows it", async () => {
+    const { fetch, calls } = mockFetch(serverError().response, serverError().response, json({ data: { save: true } }));
+    const client = newClient({ fetch, retry: 2 });
+    await assert.rejects(execute(client, "mutation Save { save }"), GraphQLResponseError);
+    assert.equal(calls.length, 1);
+    assert.deepEqual(await execute(client, "mutation Save { save }", { retry: { retries: 2, delayMs: 1, mutations: true } }), { save: true });
+    assert.equal(calls.length, 3);
+  });
+
+  test("timeoutMs aborts each attempt", async () => {
+    const { fetch, calls } = mockFetch(hang, hang, json({ data: { me: null } }));
+    const client = newClient({ fetch, timeoutMs: 20 });
+    const err = await execute(client, "query Me { me { id } }").catch((err) => err);
+    assert.equal(err.name, "TimeoutError");
+    assert.equal(calls.length, 1);
+    assert.deepEqual(await execute(client, "query Me { me { id } }", { retry: { retries: 1, delayMs: 1 } }), { me: null });
+    assert.equal(calls.length, 3);
+  });
+
+  test("aborts of the caller are not retried", async () => {
+    const { fetch, calls } = mockFetch(hang);
+    const client = newClient({ fetch, retry: { retries: 3, delayMs: 1 } });
+    const controller = new AbortController();
+    const call = execute(client, "query Me { me { id } }", { signal: controller.signal });
+    controller.abort(new Error("navigated away"));
+    await assert.rejects(call, { message: "navigated away" });
+    assert.equal(calls.length, 1);
+  });
+
+  test("aborts of the caller end the backoff", async () => {
+    const { fetch, calls } = mockFetch(serverError().response);
+    const client = newClient({ fetch, retry: { retries: 3, delayMs: 60_000 } });
+    const controller = new AbortController();
+    const call = execute(client, "query Me { me { id } }", { signal: controller.signal });
+    setTimeout(() => controller.abort(new Error("navigated away")), 10);
+    await assert.rejects(call, { message: "navigated away" });
+    assert.equal(calls.length, 1);
+  });
+});
+
+type WebSocketServer = {
+  url: string;
+  /** The messages the server received, a close frame as { type: "close" } */
+  received: any[];
+  close: () => void;
+};
+
+/**
+ * Starts a graphql-transport-ws server for unfragmented text frames, which answers the messages of the client with
+ * onMessage. send sends a message, a string as it is.
+ */
+async function webSocketServer(onMessage: (message: any, send: (message: unknown) => void) => void): Promise<WebSocketServer> {
+  const received: any[] = [];
+  const sockets: Socket[] = [];
+  const frame = (opcode: number, payload: Buffer) => {
+    const length = payload.length < 126 ? [payload.length] : [126, payload.length >> 8, payload.length & 255];
+    return Buffer.concat([Buffer.from([0x80 | opcode, ...length]), payload]);
+  };
+
+  const server = http.createServer((_, res) => res.writeHead(404).end());
+  server.on("upgrade", (req, socket: Socket) => {
+    sockets.push(socket);
+    const accept = crypto
+      .createHash("sha1")
+      .update(req.headers["sec-websocket-key"] + "258EAFA5-E914-47DA-95CA-C5AB0DC85B11")
+      .digest("base64");
+    socket.write(
+      "HTTP/1.1 101 Switching Protocols\r\nUpgrade: websocket\r\nConnection: Upgrade\r\n" +
+        `Sec-WebSocket-Accept: ${accept}\r\nSec-WebSocket-Protocol: ${req.headers["sec-websocket-protocol"]}\r\n\r\n`,
+    );
+    const send = (message: unknown) => {
+      if (socket.writable) {
+        socket.write(frame(1, Buffer.from(typeof message === "string" ? message : JSON.stringify(message))));
+      }
+    };
+
+    let buffer = Buffer.alloc(0);
+    socket.on("data", (data: Buffer) => {
+      buffer = Buffer.concat([buffer, data]);
+      while (buffer.length >= 2) {
+        const opcode = buffer[0] & 15;
+        let length = buffer[1] & 127;
+        let offset = 2;
+        if (length === 126) {
+          length = buffer.readUInt16BE(2);
+          offset = 4;
+        }
+        if (buffer.length < offset + 4 + length) {
+          return;
+        }
+        // Frames of clients are masked
+        const mask = buffer.subarray(offset, offset + 4);
+        const payload = Buffer.from(buffer.subarray(offset + 4, offset + 4 + length).map((byte, i) => byte ^ mask[i % 4]));
+        buffer = buffer.subarray(offset + 4 + length);
+        if (opcode === 8) {
+          received.push({ type: "close", code: payload.length >= 2 ? payload.readUInt16BE(0) : undefined });
+          if (socket.writable) {
+            socket.end(frame(8, payload));
+          }
+          return;
+        }
+        const message = JSON.parse(payload.toString());
+        received.push(message);
+        onMessage(message, send);
+      }
+    });
+  });
+
+  await new Promise<void>((resolve) => server.listen(0, "127.0.0.1", resolve));
+  const { port } = server.address() as { port: number };
+  return {
+    url: `http://127.0.0.1:${port}/graphql`,
+    received,
+    close: () => {
+      sockets.forEach((socket) => socket.destroy());
+      server.close();
+    },
+  };
+}
+
+/** Answers connection_init with connection_ack and subscribe with the events, then completes if complete is set */
+function serve(events: unknown[], complete = true) {
+  return (message: any, send: (message: unknown) => void) => {
+    if (message.type === "connection_init") {
+      send({ type: "connection_ack" });
+    } else if (message.type === "subscribe") {
+      for (const payload of events) {
+        send({ id: message.id, type: "next", payload });
+      }
+      if (complete) {
+        send({ id: message.id, type: "complete" });
+      }
+    }
+  };
+}
+
+/** Subscribes with the private subscribe method like a generated method */
+function subscribe(client: GraphQL, query: string, variables?: Record<string, any>, options?: object) {
+  return (client as any).subscribe(query, passthrough, variables, undefined, options);
+}
+
+/** Resolves once the condition holds */
+async function until(condition: () => boolean) {
+  for (let i = 0; !condition(); i++) {
+    assert.ok(i < 200, "timed out waiting for the condition");
+    await new Promise((resolve) => setTimeout(resolve, 5));
+  }
+}
+
+async function collect(subscription: AsyncIterable<unknown>) {
+  const events: unknown[] = [];
+  for await (const event of subscription) {
+    events.push(event);
+  }
+  return events;
+}
+
+describe("WebSocket subscriptions", () => {
+  test("the handshake is followed by the subscription, its events and its completion", async () => {
+    const server = await webSocketServer((message, send) => {
+      if (message.type === "connection_init") {
+        send({ type: "ping" });
+      } else if (message.type === "pong") {
+        send({ type: "connection_ack" });
+      } else {
+        serve([{ data: { n: 1 } }, { data: { n: 2 } }])(message, send);
+      }
+    });
+    try {
+      const client = newClient({ url: server.url, subscriptions: { connectionParams: async () => ({ token: "secret" }) } });
+      const query = "subscription OnUser($id: ID!) { user(id: $id) { id } }";
+      assert.deepEqual(await collect(subscribe(client, query, { id: "1" })), [{ n: 1 }, { n: 2 }]);
+      await until(() => server.received.at(-1)?.type === "close");
+      assert.deepEqual(server.received, [
+        { type: "connection_init", payload: { token: "secret" } },
+        { type: "pong" },
+        { id: "1", type: "subscribe", payload: { query, variables: { id: "1" }, operationName: "OnUser" } },
+        { type: "close", code: 1000 },
+      ]);
+    } finally {
+      server.close();
+    }
+  });
+
+  test("unsubscribing sends complete and closes the connection", async () => {
+    const server = await webSocketServer(serve([{ data: { n: 1 } }], false));
+    try {
+      const client = newClient({ url: server.url });
+      for await (const event of subscribe(client, "subscription OnUser { user { id } }")) {
+        assert.deepEqual(event, { n: 1 });
+        break;
+      }
+      await until(() => server.received.at(-1)?.type === "close");
+      assert.deepEqual(server.received.slice(-2), [{ id: "1", type: "complete" }, { type: "close", code: 1000 }]);
+    } finally {
+      server.close();
+    }
+  });
+
+  test("events with errors end the subscription with errorPolicy none", async () => {
+    const server = await webSocketServer(serve([{ data: { n: 1 } }, { data: null, errors: [{ message: "oops" }] }, { data: { n: 3 } }], false));
+    try {
+      const client = newClient({ url: server.url });
+      const events: unknown[] = [];
+      const err = await new Promise<unknown>((resolve) =>
+        subscribe(client, "subscription OnUser { user { id } }").subscribe({ next: (event: unknown) => events.push(event), error: resolve }),
+      );
+      assert.deepEqual(events, [{ n: 1 }]);
+      assert.ok(err instanceof GraphQLResponseError);
+      assert.equal(err.message, "oops");
+      await until(() => server.received.at(-1)?.type === "close");
+      assert.deepEqual(server.received.at(-2), { id: "1", type: "complete" });
+    } finally {
+      server.close();
+    }
+  });
+
+  test("events with errors are passed on with errorPolicy all", async () => {
+    const server = await webSocketServer(serve([{ data: { n: 1 } }, { data: { n: null }, errors: [{ message: "oops", path: ["n"] }] }, { data: { n: 3 } }]));
+    try {
+      const client = newClient({ url: server.url });
+      const events: any[] = await collect(subscribe(client, "subscription OnUser { user { id } }", undefined, { errorPolicy: "all" }));
+      assert.deepEqual(
+        events.map(({ data, errors }) => ({ data, errors: errors.map((error: GraphQLError) => error.message) })),
+        [
+          { data: { n: 1 }, errors: [] },
+          { data: { n: null }, errors: ["oops"] },
+          { data: { n: 3 }, errors: [] },
+        ],
+      );
+    } finally {
+      server.close();
+    }
+  });
+
+  test("the error message ends the subscription", async () => {
+    const server = await webSocketServer((message, send) => {
+      if (message.type === "connection_init") {
+        send({ type: "connection_ack" });
+      } else if (message.type === "subscribe") {
+        send({ id: message.id, type: "error", payload: [{ message: "Forbidden", extensions: { code: "FORBIDDEN" } }] });
+      }
+    });
+    try {
+      const client = newClient({ url: server.url });
+      await assert.rejects(collect(subscribe(client, "subscription OnUser { user { id } }", undefined, { errorPolicy: "all" })), (err) =>
+        isGraphQLResponseError(err, "FORBIDDEN"),
+      );
+    } finally {
+      server.close();
+    }
+  });
+
+  test("malformed messages end the subscription", async () => {
+    const server = await webSocketServer((message, send) => {
+      if (message.type === "connection_init") {
+        send("{not json");
+      }
+    });
+    try {
+      const client = newClient({ url: server.url });
+      await assert.rejects(collect(subscribe(client, "subscription OnUser { user { id } }")), { message: "Invalid WebSocket message: {not json" });
+      await until(() => server.received.at(-1)?.type === "close");
+    } finally {
+      server.close();
+    }
+  });
+});
+
+describe("persisted queries", () => {
+  test("queries sent as hash only are GET requests", async () => {
+    const { fetch, calls } = mockFetch(json({ data: { user: { id: "1" } } }));
+    const client = newClient({ fetch, url: `${url}?tenant=a` });
+    const query = "query User($id: ID!) { user(id: $id) { id } }";
+    await (client as any).execute(query, passthrough, { id: "1" }, passthrough, {}, "abc123");
+    assert.equal(calls[0].init.method, "GET");
+    assert.equal(calls[0].init.body, undefined);
+    const params = new URL(calls[0].url).searchParams;
+    assert.equal(params.get("tenant"), "a");
+    assert.equal(params.get("query"), null);
+    assert.equal(params.get("operationName"), "User");
+    assert.deepEqual(JSON.parse(params.get("variables")!), { id: "1" });
+    assert.deepEqual(JSON.parse(params.get("extensions")!), { persistedQuery: { version: 1, sha256Hash: "abc123" } });
+  });
+
+  test("mutations sent as hash only are POST requests", async () => {
+    const { fetch, calls } = mockFetch(json({ data: { save: true } }));
+    await (newClient({ fetch }) as any).execute("mutation Save { save }", passthrough, undefined, undefined, {}, "abc123");
+    assert.equal(calls[0].init.method, "POST");
+    assert.deepEqual(JSON.parse(String(calls[0].init.body)), { extensions: { persistedQuery: { version: 1, sha256Hash: "abc123" } } });
+  });
+});
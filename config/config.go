@@ -7,7 +7,11 @@ import (
 	"fmt"
 	"os"
 	"path/filepath"
+	"reflect"
+	"sort"
 	"strings"
+	"time"
+	"unicode"
 
 	"github.com/BurntSushi/toml"
 	"gopkg.in/yaml.v3"
@@ -17,14 +21,28 @@ const configFileName = "gqlc."
 
 type (
 	Config struct {
+		Input  Input  `yaml:"input,omitempty" json:"input,omitzero" toml:"input,omitempty" xml:"input,omitempty"`
+		Output Output `yaml:"output,omitempty" json:"output,omitzero" toml:"output,omitempty" xml:"output,omitempty"`
+		// Projects are compiled like separate configs, the input and output of the config itself are unused then
+		Projects Projects `yaml:"projects,omitempty" json:"projects,omitempty" toml:"projects,omitempty" xml:"projects,omitempty"`
+	}
+
+	// Projects maps project names to their input and output
+	Projects map[string]Project
+
+	Project struct {
 		Input  Input  `yaml:"input" json:"input" toml:"input" xml:"input"`
 		Output Output `yaml:"output" json:"output" toml:"output" xml:"output"`
 	}
 
 	Input struct {
-		Schemas          string `yaml:"schemas" json:"schemas" toml:"schemas" xml:"schemas"`
-		Operations       string `yaml:"operations" json:"operations" toml:"operations" xml:"operations"`
-		WebAuthorization string `yaml:"authorization,omitempty" json:"authorization,omitempty" toml:"authorization,omitempty" xml:"authorization,omitempty"`
+		Schemas          SchemaSources `yaml:"schemas" json:"schemas" toml:"schemas" xml:"schemas"`
+		Operations       string        `yaml:"operations" json:"operations" toml:"operations" xml:"operations"`
+		WebAuthorization string        `yaml:"authorization,omitempty" json:"authorization,omitempty" toml:"authorization,omitempty" xml:"authorization,omitempty"`
+		// CacheTTL is how long the introspection result of a remote schema is used before it is revalidated, e.g. 1h
+		CacheTTL string `yaml:"cache_ttl,omitempty" json:"cache_ttl,omitempty" toml:"cache_ttl,omitempty" xml:"cache_ttl,omitempty"`
+		// DeprecationsAsErrors fails the build when an operation uses a deprecated field or enum value
+		DeprecationsAsErrors bool `yaml:"deprecations_as_errors,omitempty" json:"deprecations_as_errors,omitempty" toml:"deprecations_as_errors,omitempty" xml:"deprecations_as_errors,omitempty"`
 	}
 
 	Output struct {
@@ -34,13 +52,118 @@ type (
 		Suffix   string `yaml:"suffix" json:"suffix" toml:"suffix" xml:"suffix"`
 		// Only for TypeScript
 		ImportIncludeExtension *bool `yaml:"import_include_extension,omitempty" json:"import_include_extension,omitempty" toml:"import_include_extension,omitempty" xml:"import_include_extension,omitempty"`
+		// Endpoint is the default url of the generated client, defaults to the endpoint of input.schemas. Only for TypeScript
+		Endpoint string `yaml:"endpoint,omitempty" json:"endpoint,omitempty" toml:"endpoint,omitempty" xml:"endpoint,omitempty"`
+		// URLArgument generates methods taking the url as first argument, like older versions did. Only for TypeScript
+		URLArgument bool `yaml:"url_argument,omitempty" json:"url_argument,omitempty" toml:"url_argument,omitempty" xml:"url_argument,omitempty"`
+		// ClassName is the name of the generated client class, defaults to GraphQL. Only for TypeScript
+		ClassName string `yaml:"class_name,omitempty" json:"class_name,omitempty" toml:"class_name,omitempty" xml:"class_name,omitempty"`
+		// PersistedQueries is "apq" to send automatic persisted queries or "trusted" to send only the ids of
+		// the documents in the persisted query manifest. Only for TypeScript
+		PersistedQueries string `yaml:"persisted_queries,omitempty" json:"persisted_queries,omitempty" toml:"persisted_queries,omitempty" xml:"persisted_queries,omitempty"`
+		// Only for TypeScript
+		Scalars Scalars `yaml:"scalars,omitempty" json:"scalars,omitempty" toml:"scalars,omitempty" xml:"scalars,omitempty"`
+	}
+
+	// SchemaSources are merged into one schema. A single source without options is written as a string.
+	SchemaSources []SchemaSource
+
+	// SchemaSource is the URL of a GraphQL endpoint, a file or directory of SDL files or an introspection JSON file
+	SchemaSource struct {
+		Path string `yaml:"path" json:"path" toml:"path" xml:",chardata"`
+		// Client marks a schema of client-side only types and fields, which are stripped from the documents sent to the server
+		Client bool `yaml:"client,omitempty" json:"client,omitempty" toml:"client,omitempty" xml:"client,attr,omitempty"`
+	}
+
+	// Scalars maps custom scalar names to their TypeScript representation
+	Scalars map[string]Scalar
+
+	Scalar struct {
+		// Type is the TypeScript type of the scalar, e.g. Date
+		Type string `yaml:"type,omitempty" json:"type,omitempty" toml:"type,omitempty" xml:"type,attr,omitempty"`
+		// Zod is the Zod schema expression that parses the scalar, e.g. z.coerce.date()
+		Zod string `yaml:"zod,omitempty" json:"zod,omitempty" toml:"zod,omitempty" xml:"zod,attr,omitempty"`
+		// Import is the module that exports the identifiers used by Type and Zod
+		Import string `yaml:"import,omitempty" json:"import,omitempty" toml:"import,omitempty" xml:"import,attr,omitempty"`
 	}
 )
 
+// xmlScalar is a scalar in the XML config, which has no maps: <scalar name="DateTime" type="Date" zod="z.coerce.date()"/>
+type xmlScalar struct {
+	Name string `xml:"name,attr"`
+	Scalar
+}
+
+func (s Scalars) MarshalXML(e *xml.Encoder, start xml.StartElement) error {
+	names := make([]string, 0, len(s))
+	for name := range s {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	list := struct {
+		Scalars []xmlScalar `xml:"scalar"`
+	}{}
+	for _, name := range names {
+		list.Scalars = append(list.Scalars, xmlScalar{Name: name, Scalar: s[name]})
+	}
+	return e.EncodeElement(list, start)
+}
+
+func (s *Scalars) UnmarshalXML(d *xml.Decoder, start xml.StartElement) error {
+	var list struct {
+		Scalars []xmlScalar `xml:"scalar"`
+	}
+	if err := d.DecodeElement(&list, &start); err != nil {
+		return err
+	}
+	*s = make(Scalars, len(list.Scalars))
+	for _, scalar := range list.Scalars {
+		(*s)[scalar.Name] = scalar.Scalar
+	}
+	return nil
+}
+
+// xmlProject is a project in the XML config: <project name="billing"><input>...</input><output>...</output></project>
+type xmlProject struct {
+	Name string `xml:"name,attr"`
+	Project
+}
+
+func (p Projects) MarshalXML(e *xml.Encoder, start xml.StartElement) error {
+	names := make([]string, 0, len(p))
+	for name := range p {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	list := struct {
+		Projects []xmlProject `xml:"project"`
+	}{}
+	for _, name := range names {
+		list.Projects = append(list.Projects, xmlProject{Name: name, Project: p[name]})
+	}
+	return e.EncodeElement(list, start)
+}
+
+func (p *Projects) UnmarshalXML(d *xml.Decoder, start xml.StartElement) error {
+	var list struct {
+		Projects []xmlProject `xml:"project"`
+	}
+	if err := d.DecodeElement(&list, &start); err != nil {
+		return err
+	}
+	*p = make(Projects, len(list.Projects))
+	for _, project := range list.Projects {
+		(*p)[project.Name] = project.Project
+	}
+	return nil
+}
+
 func New() *Config {
 	return &Config{
 		Input: Input{
-			Schemas:    "graphql/schemas",
+			Schemas:    SchemaSources{{Path: "graphql/schemas"}},
 			Operations: "graphql/operations",
 		},
 		Output: Output{
@@ -141,9 +264,133 @@ func loadXml(file *os.File) (Config, error) {
 }
 
 func (c Config) Validate() error {
-	if c.Input.Schemas == "" {
+	if len(c.Projects) == 0 {
+		return c.validate()
+	}
+	if !reflect.ValueOf(c.Input).IsZero() || !reflect.ValueOf(c.Output).IsZero() {
+		return errors.New("input and output must be set per project if projects are defined")
+	}
+
+	outputs := make(map[string]string)
+	for _, name := range c.ProjectNames() {
+		project, err := c.Project(name)
+		if err != nil {
+			return err
+		}
+		if err := project.validate(); err != nil {
+			return fmt.Errorf("project %s: %w", name, err)
+		}
+		output := filepath.Join(project.Output.Location, project.Output.Suffix)
+		if other, ok := outputs[output]; ok {
+			return fmt.Errorf("projects %s and %s write the same output files, change output.location or output.suffix", other, name)
+		}
+		outputs[output] = name
+	}
+
+	// The persisted query manifest has no suffix, projects using persisted queries need their own output.location
+	locations := make(map[string]string)
+	for _, name := range c.ProjectNames() {
+		project := c.Projects[name]
+		location := filepath.Clean(project.Output.Location)
+		if other, ok := locations[location]; ok && (project.Output.PersistedQueries != "" || c.Projects[other].Output.PersistedQueries != "") {
+			return fmt.Errorf("projects %s and %s share output.location %s, which is not supported with output.persisted_queries", other, name, project.Output.Location)
+		}
+		locations[location] = name
+	}
+	return nil
+}
+
+// ProjectNames returns the sorted names of the projects, or a single empty name if the config has no projects
+func (c Config) ProjectNames() []string {
+	if len(c.Projects) == 0 {
+		return []string{""}
+	}
+	names := make([]string, 0, len(c.Projects))
+	for name := range c.Projects {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// SelectProjects returns the names of the projects to use, only the named project if name isn't empty or else all
+func (c Config) SelectProjects(name string) ([]string, error) {
+	if name == "" {
+		return c.ProjectNames(), nil
+	}
+	if _, err := c.Project(name); err != nil {
+		return nil, err
+	}
+	return []string{name}, nil
+}
+
+// Project returns the config of the named project, or the config itself for an empty name if it has no projects.
+// The client class of a project is named after the project unless output.class_name is set.
+func (c Config) Project(name string) (Config, error) {
+	if len(c.Projects) == 0 {
+		if name != "" {
+			return Config{}, fmt.Errorf("unknown project %s, the config has no projects", name)
+		}
+		return c, nil
+	}
+	project, ok := c.Projects[name]
+	if !ok {
+		return Config{}, fmt.Errorf("unknown project %q (projects: %s)", name, strings.Join(c.ProjectNames(), ", "))
+	}
+	if project.Output.ClassName == "" {
+		project.Output.ClassName = className(name) + defaultClassName
+	}
+	return Config{Input: project.Input, Output: project.Output}, nil
+}
+
+// ClientEndpoint returns output.endpoint or, if it is not set, the first GraphQL endpoint of input.schemas
+func (c Config) ClientEndpoint() string {
+	if c.Output.Endpoint != "" {
+		return c.Output.Endpoint
+	}
+	if remote := c.Input.Schemas.Remote(); len(remote) > 0 {
+		return remote[0].Path
+	}
+	return ""
+}
+
+// defaultClassName is the name of the generated client class if output.class_name is not set
+const defaultClassName = "GraphQL"
+
+// TypeScriptClassName returns the name of the generated client class
+func (o Output) TypeScriptClassName() string {
+	if o.ClassName == "" {
+		return defaultClassName
+	}
+	return o.ClassName
+}
+
+// className converts a project name like billing-api to BillingApi,
+// names starting with a digit like 2fa are prefixed to Project2fa to be valid identifiers
+func className(name string) string {
+	var b strings.Builder
+	for _, word := range strings.FieldsFunc(name, func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	}) {
+		runes := []rune(word)
+		if b.Len() == 0 && !unicode.IsLetter(runes[0]) {
+			b.WriteString("Project")
+		}
+		runes[0] = unicode.ToUpper(runes[0])
+		b.WriteString(string(runes))
+	}
+	return b.String()
+}
+
+func (c Config) validate() error {
+	if len(c.Input.Schemas) == 0 {
 		return errors.New("input.schemas is required")
 	}
+	for _, source := range c.Input.Schemas {
+		if source.Path == "" {
+			return errors.New("input.schemas must not contain empty paths")
+		}
+	}
 	if c.Input.Operations == "" {
 		return errors.New("input.operations is required")
 	}
@@ -153,9 +400,42 @@ func (c Config) Validate() error {
 	if c.Output.Language == "" {
 		return errors.New("output.language is required")
 	}
+	if _, err := c.Input.IntrospectionCacheTTL(); err != nil {
+		return err
+	}
+	switch c.Output.PersistedQueries {
+	case "", PersistedQueriesAPQ, PersistedQueriesTrusted:
+	default:
+		return fmt.Errorf("invalid output.persisted_queries %q, must be %q or %q", c.Output.PersistedQueries, PersistedQueriesAPQ, PersistedQueriesTrusted)
+	}
+	if language := strings.ToLower(c.Output.Language); c.Output.PersistedQueries != "" && (language == "go" || language == "golang") {
+		return errors.New("output.persisted_queries is only supported for TypeScript")
+	}
 	return nil
 }
 
+const (
+	// PersistedQueriesAPQ sends the hash of the document and the document only if the server doesn't know it yet
+	PersistedQueriesAPQ = "apq"
+	// PersistedQueriesTrusted sends only the hash of the document, the server must know it from the manifest
+	PersistedQueriesTrusted = "trusted"
+)
+
+// defaultCacheTTL is the cache TTL if input.cache_ttl is not set
+const defaultCacheTTL = 24 * time.Hour
+
+// IntrospectionCacheTTL returns the parsed input.cache_ttl
+func (i Input) IntrospectionCacheTTL() (time.Duration, error) {
+	if i.CacheTTL == "" {
+		return defaultCacheTTL, nil
+	}
+	ttl, err := time.ParseDuration(i.CacheTTL)
+	if err != nil {
+		return 0, fmt.Errorf("invalid input.cache_ttl: %w", err)
+	}
+	return ttl, nil
+}
+
 func (c Config) saveAsYaml(ext string) error {
 	file, err := os.Create(configFileName + ext)
 	if err != nil {
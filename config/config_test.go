@@ -0,0 +1,160 @@
+package config
+
+import (
+	"encoding/xml"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func testProject(location string) Project {
+	return Project{
+		Input: Input{
+			Schemas:    SchemaSources{{Path: "graphql/schemas"}},
+			Operations: "graphql/operations",
+		},
+		Output: Output{
+			Location: location,
+			Language: "typescript",
+			Suffix:   "_gqlc",
+		},
+	}
+}
+
+func withSuffix(project Project, suffix string, persistedQueries string) Project {
+	project.Output.Suffix = suffix
+	project.Output.PersistedQueries = persistedQueries
+	return project
+}
+
+func TestConfig_Validate(t *testing.T) {
+	for _, test := range []struct {
+		name     string
+		config   Config
+		expected string
+	}{
+		{"without projects", *New(), ""},
+		{"projects", Config{Projects: Projects{"shop": testProject("src/shop"), "billing": testProject("src/billing")}}, ""},
+		{"top-level input", Config{Input: New().Input, Projects: Projects{"shop": testProject("src/shop")}}, "input and output must be set per project"},
+		{"top-level output", Config{Output: Output{Location: "graphql"}, Projects: Projects{"shop": testProject("src/shop")}}, "input and output must be set per project"},
+		{"duplicate outputs", Config{Projects: Projects{"shop": testProject("src"), "billing": testProject("src/")}}, "projects billing and shop write the same output files"},
+		{"shared location with persisted queries", Config{Projects: Projects{"shop": testProject("src"), "billing": withSuffix(testProject("src"), "_billing", PersistedQueriesAPQ)}}, "projects billing and shop share output.location src"},
+		{"shared location without persisted queries", Config{Projects: Projects{"shop": testProject("src"), "billing": withSuffix(testProject("src"), "_billing", "")}}, ""},
+		{"invalid project", Config{Projects: Projects{"shop": {Output: testProject("src").Output}}}, "project shop: input.schemas is required"},
+	} {
+		err := test.config.Validate()
+		if test.expected == "" {
+			if err != nil {
+				t.Errorf("%s: expected no error, got %v", test.name, err)
+			}
+			continue
+		}
+		if err == nil || !strings.Contains(err.Error(), test.expected) {
+			t.Errorf("%s: expected error containing %q, got %v", test.name, test.expected, err)
+		}
+	}
+}
+
+func TestConfig_Project(t *testing.T) {
+	named := testProject("src/named")
+	named.Output.ClassName = "Named"
+	projects := Config{Projects: Projects{"billing-api": testProject("src/billing"), "named": named}}
+
+	for _, test := range []struct {
+		config    Config
+		name      string
+		className string
+		expected  string
+	}{
+		{*New(), "", "", ""},
+		{*New(), "shop", "", "unknown project shop, the config has no projects"},
+		{projects, "billing-api", "BillingApiGraphQL", ""},
+		{projects, "named", "Named", ""},
+		{projects, "shop", "", `unknown project "shop" (projects: billing-api, named)`},
+	} {
+		project, err := test.config.Project(test.name)
+		if test.expected != "" {
+			if err == nil || err.Error() != test.expected {
+				t.Errorf("project %q: expected error %q, got %v", test.name, test.expected, err)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("project %q: expected no error, got %v", test.name, err)
+			continue
+		}
+		if project.Output.ClassName != test.className {
+			t.Errorf("project %q: expected class name %q, got %q", test.name, test.className, project.Output.ClassName)
+		}
+		if len(project.Projects) != 0 {
+			t.Errorf("project %q: expected a config without projects, got %v", test.name, project.Projects)
+		}
+	}
+}
+
+func TestConfig_SelectProjects(t *testing.T) {
+	projects := Config{Projects: Projects{"shop": testProject("src/shop"), "billing": testProject("src/billing")}}
+
+	for _, test := range []struct {
+		config   Config
+		name     string
+		names    []string
+		expected string
+	}{
+		{*New(), "", []string{""}, ""},
+		{*New(), "shop", nil, "the config has no projects"},
+		{projects, "", []string{"billing", "shop"}, ""},
+		{projects, "shop", []string{"shop"}, ""},
+		{projects, "admin", nil, `unknown project "admin"`},
+	} {
+		names, err := test.config.SelectProjects(test.name)
+		if test.expected != "" {
+			if err == nil || !strings.Contains(err.Error(), test.expected) {
+				t.Errorf("project %q: expected error containing %q, got %v", test.name, test.expected, err)
+			}
+			continue
+		}
+		if err != nil || !reflect.DeepEqual(names, test.names) {
+			t.Errorf("project %q: expected %v, got %v, %v", test.name, test.names, names, err)
+		}
+	}
+}
+
+func TestClassName(t *testing.T) {
+	for name, expected := range map[string]string{
+		"shop":        "Shop",
+		"billing-api": "BillingApi",
+		"admin_v2":    "AdminV2",
+		"2fa":         "Project2fa",
+		"-2fa-login":  "Project2faLogin",
+		"über":        "Über",
+		"--":          "",
+	} {
+		if got := className(name); got != expected {
+			t.Errorf("className(%q): expected %q, got %q", name, expected, got)
+		}
+	}
+}
+
+func TestProjects_XMLRoundTrip(t *testing.T) {
+	billing := testProject("src/billing")
+	billing.Input.Schemas = SchemaSources{{Path: "https://billing.example.com/graphql"}, {Path: "graphql/client", Client: true}}
+	billing.Output.ClassName = "Billing"
+	config := Config{Projects: Projects{"shop": testProject("src/shop"), "billing": billing}}
+
+	data, err := xml.MarshalIndent(config, "", "  ")
+	if err != nil {
+		t.Fatalf("MarshalIndent returned error: %v", err)
+	}
+	if billingIndex, shopIndex := strings.Index(string(data), `<project name="billing">`), strings.Index(string(data), `<project name="shop">`); billingIndex == -1 || shopIndex < billingIndex {
+		t.Errorf("expected the projects sorted by name, got:\n%s", data)
+	}
+
+	var decoded Config
+	if err := xml.Unmarshal(data, &decoded); err != nil {
+		t.Fatalf("Unmarshal returned error: %v", err)
+	}
+	if !reflect.DeepEqual(decoded, config) {
+		t.Errorf("expected %+v, got %+v\nfrom:\n%s", config, decoded, data)
+	}
+}
@@ -0,0 +1,149 @@
+package config
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// IsRemote checks if the source is a GraphQL endpoint instead of files on disk
+func (s SchemaSource) IsRemote() bool {
+	return strings.HasPrefix(s.Path, "https://") || strings.HasPrefix(s.Path, "http://")
+}
+
+// Remote returns the sources that are GraphQL endpoints
+func (s SchemaSources) Remote() []SchemaSource {
+	var remote []SchemaSource
+	for _, source := range s {
+		if source.IsRemote() {
+			remote = append(remote, source)
+		}
+	}
+	return remote
+}
+
+// LocalPaths returns the paths of the sources on disk
+func (s SchemaSources) LocalPaths() []string {
+	var paths []string
+	for _, source := range s {
+		if !source.IsRemote() {
+			paths = append(paths, source.Path)
+		}
+	}
+	return paths
+}
+
+// String returns the paths of the sources separated by commas
+func (s SchemaSources) String() string {
+	paths := make([]string, len(s))
+	for i, source := range s {
+		paths[i] = source.Path
+	}
+	return strings.Join(paths, ", ")
+}
+
+// isPath checks if the sources can be written as a single string
+func (s SchemaSources) isPath() bool {
+	return len(s) == 1 && !s[0].Client
+}
+
+func (s *SchemaSource) UnmarshalYAML(value *yaml.Node) error {
+	if value.Kind == yaml.ScalarNode {
+		return value.Decode(&s.Path)
+	}
+	type plain SchemaSource
+	return value.Decode((*plain)(s))
+}
+
+func (s *SchemaSources) UnmarshalYAML(value *yaml.Node) error {
+	if value.Kind == yaml.ScalarNode {
+		*s = SchemaSources{{}}
+		return value.Decode(&(*s)[0].Path)
+	}
+	var sources []SchemaSource
+	if err := value.Decode(&sources); err != nil {
+		return err
+	}
+	*s = sources
+	return nil
+}
+
+func (s SchemaSources) MarshalYAML() (any, error) {
+	if s.isPath() {
+		return s[0].Path, nil
+	}
+	return []SchemaSource(s), nil
+}
+
+func (s *SchemaSource) UnmarshalJSON(data []byte) error {
+	if err := json.Unmarshal(data, &s.Path); err == nil {
+		return nil
+	}
+	type plain SchemaSource
+	return json.Unmarshal(data, (*plain)(s))
+}
+
+func (s *SchemaSources) UnmarshalJSON(data []byte) error {
+	var path string
+	if err := json.Unmarshal(data, &path); err == nil {
+		*s = SchemaSources{{Path: path}}
+		return nil
+	}
+	var sources []SchemaSource
+	if err := json.Unmarshal(data, &sources); err != nil {
+		return err
+	}
+	*s = sources
+	return nil
+}
+
+func (s SchemaSources) MarshalJSON() ([]byte, error) {
+	if s.isPath() {
+		return json.Marshal(s[0].Path)
+	}
+	return json.Marshal([]SchemaSource(s))
+}
+
+func (s *SchemaSources) UnmarshalTOML(data any) error {
+	switch v := data.(type) {
+	case string:
+		*s = SchemaSources{{Path: v}}
+		return nil
+	case []any:
+		sources := make(SchemaSources, 0, len(v))
+		for _, item := range v {
+			switch item := item.(type) {
+			case string:
+				sources = append(sources, SchemaSource{Path: item})
+			case map[string]any:
+				path, _ := item["path"].(string)
+				client, _ := item["client"].(bool)
+				sources = append(sources, SchemaSource{Path: path, Client: client})
+			default:
+				return fmt.Errorf("invalid schema source %v", item)
+			}
+		}
+		*s = sources
+		return nil
+	default:
+		return fmt.Errorf("invalid schema sources %v", data)
+	}
+}
+
+func (s SchemaSources) MarshalTOML() ([]byte, error) {
+	// JSON strings are valid TOML basic strings
+	if s.isPath() {
+		return json.Marshal(s[0].Path)
+	}
+	items := make([]string, len(s))
+	for i, source := range s {
+		path, err := json.Marshal(source.Path)
+		if err != nil {
+			return nil, err
+		}
+		items[i] = fmt.Sprintf("{ path = %s, client = %t }", path, source.Client)
+	}
+	return []byte("[" + strings.Join(items, ", ") + "]"), nil
+}
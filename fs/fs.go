@@ -12,12 +12,34 @@ import (
 // If the path is a file, it returns that file.
 // If the path is a directory, it walks the directory and collects all .graphql and .gql files.
 func CollectGraphQLFiles(path string) ([]*os.File, error) {
+	paths, err := CollectGraphQLPaths(path)
+	if err != nil {
+		return nil, err
+	}
+
+	files := make([]*os.File, 0, len(paths))
+	for _, p := range paths {
+		f, err := os.Open(p)
+		if err != nil {
+			// Close any opened files on error
+			closeFiles(files)
+			return nil, fmt.Errorf("failed to open file %s: %w", p, err)
+		}
+		files = append(files, f)
+	}
+
+	return files, nil
+}
+
+// CollectGraphQLPaths collects the paths of all GraphQL files from the given path
+// in the same way as CollectGraphQLFiles, without opening them.
+func CollectGraphQLPaths(path string) ([]string, error) {
 	stat, err := os.Stat(path)
 	if err != nil {
 		return nil, fmt.Errorf("failed to stat path %s: %w", path, err)
 	}
 
-	var files []*os.File
+	var paths []string
 
 	if stat.IsDir() {
 		// Walk directory and collect GraphQL files
@@ -31,15 +53,9 @@ func CollectGraphQLFiles(path string) ([]*os.File, error) {
 			if !isGraphQLFile(p) {
 				return nil
 			}
-			f, err := os.Open(p)
-			if err != nil {
-				return fmt.Errorf("failed to open file %s: %w", p, err)
-			}
-			files = append(files, f)
+			paths = append(paths, p)
 			return nil
 		}); err != nil {
-			// Close any opened files on error
-			closeFiles(files)
 			return nil, fmt.Errorf("failed to walk directory %s: %w", path, err)
 		}
 	} else {
@@ -47,18 +63,14 @@ func CollectGraphQLFiles(path string) ([]*os.File, error) {
 		if !isGraphQLFile(path) {
 			return nil, fmt.Errorf("file %s is not a GraphQL file (must have .graphql or .gql extension)", path)
 		}
-		f, err := os.Open(path)
-		if err != nil {
-			return nil, fmt.Errorf("failed to open file %s: %w", path, err)
-		}
-		files = append(files, f)
+		paths = append(paths, path)
 	}
 
-	if len(files) == 0 {
+	if len(paths) == 0 {
 		return nil, fmt.Errorf("no GraphQL files found in %s", path)
 	}
 
-	return files, nil
+	return paths, nil
 }
 
 // isGraphQLFile checks if a file has a GraphQL extension
@@ -1,19 +1,62 @@
 package main
 
 import (
+	"bytes"
+	"errors"
 	"fmt"
 	"gqlc/compiler"
 	"gqlc/config"
 	"gqlc/fs"
+	"gqlc/parser"
+	"gqlc/schema"
+	"gqlc/validate"
+	"gqlc/watch"
+	"io"
 	"os"
 	"path/filepath"
 	"runtime/debug"
+	"strings"
+	"sync"
+	"text/tabwriter"
 	"time"
 )
 
 var buildVersion = "(devel)"
 
+// refresh is set by the --refresh flag to revalidate a cached remote schema regardless of its age
+var refresh bool
+
+// project is set by the --project flag to use only one project of the config
+var project string
+
 func main() {
+	args := os.Args[:1]
+	for i := 1; i < len(os.Args); i++ {
+		arg := os.Args[i]
+		switch {
+		case arg == "--refresh":
+			refresh = true
+		case arg == "--project":
+			if i+1 == len(os.Args) || strings.HasPrefix(os.Args[i+1], "-") {
+				fmt.Fprintln(os.Stderr, "Usage: gqlc --project <name>")
+				os.Exit(1)
+				return
+			}
+			i++
+			project = os.Args[i]
+		case strings.HasPrefix(arg, "--project="):
+			project = strings.TrimPrefix(arg, "--project=")
+			if project == "" {
+				fmt.Fprintln(os.Stderr, "Usage: gqlc --project <name>")
+				os.Exit(1)
+				return
+			}
+		default:
+			args = append(args, arg)
+		}
+	}
+	os.Args = args
+
 	if len(os.Args) > 1 {
 		switch os.Args[1] {
 		case "init":
@@ -23,6 +66,27 @@ func main() {
 				return
 			}
 			return
+		case "watch":
+			if err := runWatch(); err != nil {
+				printError(err)
+				os.Exit(1)
+				return
+			}
+			return
+		case "schema":
+			if err := runSchema(); err != nil {
+				fmt.Fprintln(os.Stderr, err.Error())
+				os.Exit(1)
+				return
+			}
+			return
+		case "cache":
+			if err := runCache(); err != nil {
+				fmt.Fprintln(os.Stderr, err.Error())
+				os.Exit(1)
+				return
+			}
+			return
 		case "version", "--version", "-v":
 			if buildVersion == "dev" {
 				if bi, ok := debug.ReadBuildInfo(); ok {
@@ -35,7 +99,7 @@ func main() {
 	} else {
 		startedAt := time.Now()
 		if err := run(); err != nil {
-			fmt.Fprintln(os.Stderr, err.Error())
+			printError(err)
 			os.Exit(1)
 			return
 		}
@@ -43,10 +107,77 @@ func main() {
 	}
 }
 
-func run() error {
+// projectConfig is the config of a project, projects of a config without projects have no name
+type projectConfig struct {
+	name string
+	cfg  config.Config
+}
+
+// loadProjects loads the config and returns the project selected by the --project flag or all projects
+func loadProjects() ([]projectConfig, error) {
 	cfg, err := config.Load()
 	if err != nil {
-		return fmt.Errorf("failed to load config: %w", err)
+		return nil, fmt.Errorf("failed to load config: %w", err)
+	}
+	names, err := cfg.SelectProjects(project)
+	if err != nil {
+		return nil, err
+	}
+	projects := make([]projectConfig, 0, len(names))
+	for _, name := range names {
+		projectCfg, err := cfg.Project(name)
+		if err != nil {
+			return nil, err
+		}
+		projects = append(projects, projectConfig{name: name, cfg: projectCfg})
+	}
+	return projects, nil
+}
+
+// run compiles the projects concurrently, the warnings and errors of each project are printed after all are done
+func run() error {
+	projects, err := loadProjects()
+	if err != nil {
+		return err
+	}
+	if len(projects) == 1 {
+		return compileProject(projects[0].cfg, os.Stderr)
+	}
+
+	errs := make([]error, len(projects))
+	warnings := make([]bytes.Buffer, len(projects))
+	var wg sync.WaitGroup
+	for i, p := range projects {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			errs[i] = compileProject(p.cfg, &warnings[i])
+		}()
+	}
+	wg.Wait()
+
+	failed := 0
+	for i, err := range errs {
+		if err == nil && warnings[i].Len() == 0 {
+			continue
+		}
+		fmt.Fprintf(os.Stderr, "project %s:\n", projects[i].name)
+		_, _ = warnings[i].WriteTo(os.Stderr)
+		if err != nil {
+			printError(err)
+			failed++
+		}
+	}
+	if failed > 0 {
+		return fmt.Errorf("%s of %d failed", pluralize(failed, "project"), len(projects))
+	}
+	return nil
+}
+
+// compileProject compiles the operations of a project and writes its warnings to warnings
+func compileProject(cfg config.Config, warnings io.Writer) error {
+	if err := refreshSchema(cfg); err != nil {
+		return err
 	}
 
 	// Load operations using the fs utility
@@ -67,22 +198,32 @@ func run() error {
 		return fmt.Errorf("failed to create output directory: %w", err)
 	}
 
-	outSchemaName := fmt.Sprintf("schema%s.%s", cfg.Output.Suffix, cfg.Output.FileExtension())
-	outSchemaPath := filepath.Join(outDir, outSchemaName)
+	outSchemaName, outSchemaPath, outOp := outputPaths(cfg)
 	outSchemaFile, err := os.Create(outSchemaPath)
 	if err != nil {
 		return fmt.Errorf("failed to create output file: %w", err)
 	}
 	defer outSchemaFile.Close()
 
-	outOp := filepath.Join(outDir, fmt.Sprintf("operations%s.%s", cfg.Output.Suffix, cfg.Output.FileExtension()))
 	outOpFile, err := os.Create(outOp)
 	if err != nil {
 		return fmt.Errorf("failed to create output file: %w", err)
 	}
 	defer outOpFile.Close()
 
-	err = compiler.Compile(cfg, operationsSrc, outSchemaFile, outSchemaName, outOpFile)
+	var outManifest io.Writer = io.Discard
+	if cfg.Output.PersistedQueries != "" {
+		outManifestFile, err := os.Create(manifestPath(cfg))
+		if err != nil {
+			return fmt.Errorf("failed to create output file: %w", err)
+		}
+		defer outManifestFile.Close()
+		outManifest = outManifestFile
+	} else if err := removeManifest(cfg); err != nil {
+		return err
+	}
+
+	err = compiler.Compile(cfg, operationsSrc, outSchemaFile, outSchemaName, outOpFile, outManifest, warnings)
 	if err != nil {
 		return fmt.Errorf("failed to compile: %w", err)
 	}
@@ -90,6 +231,391 @@ func run() error {
 	return nil
 }
 
+// refreshSchema revalidates the cached remote schemas if the --refresh flag is set
+func refreshSchema(cfg config.Config) error {
+	if !refresh {
+		return nil
+	}
+	for _, source := range cfg.Input.Schemas.Remote() {
+		if _, err := schema.FetchIntrospection(cfg.Input, source.Path); err != nil {
+			return fmt.Errorf("failed to refresh schema of %s: %w", source.Path, err)
+		}
+	}
+	return nil
+}
+
+// runSchema runs the schema subcommands
+func runSchema() error {
+	usage := "Usage: gqlc schema [fetch|print [sdl|json] [<file>]]"
+	if len(os.Args) < 3 {
+		return errors.New(usage)
+	}
+	switch os.Args[2] {
+	case "fetch":
+		projects, err := loadProjects()
+		if err != nil {
+			return err
+		}
+		fetched := make(map[string]bool)
+		for _, p := range projects {
+			for _, source := range p.cfg.Input.Schemas.Remote() {
+				if fetched[source.Path] {
+					continue
+				}
+				fetched[source.Path] = true
+				if err := fetchSchema(p.cfg.Input, source.Path); err != nil {
+					return err
+				}
+			}
+		}
+		if len(fetched) == 0 {
+			return errors.New("input.schemas contains no GraphQL endpoint")
+		}
+		return nil
+	case "print":
+		return printSchema(os.Args[3:])
+	case "help":
+		fmt.Println(usage)
+		return nil
+	default:
+		return fmt.Errorf("unknown command %q\n%s", os.Args[2], usage)
+	}
+}
+
+// fetchSchema revalidates the cached schema of the endpoint and prints whether it changed
+func fetchSchema(input config.Input, endpoint string) error {
+	updated, err := schema.FetchIntrospection(input, endpoint)
+	if err != nil {
+		return fmt.Errorf("failed to fetch schema of %s: %w", endpoint, err)
+	}
+	if updated {
+		fmt.Printf("Fetched schema of %s\n", endpoint)
+	} else {
+		fmt.Printf("Schema of %s is up to date\n", endpoint)
+	}
+	return nil
+}
+
+// printSchema writes the schema as SDL or introspection JSON to the file given in args or to stdout
+func printSchema(args []string) error {
+	format := "sdl"
+	output := ""
+	for _, arg := range args {
+		switch arg {
+		case "sdl", "graphql", "json":
+			format = arg
+		default:
+			if output != "" {
+				return fmt.Errorf("unexpected argument %q\nUsage: gqlc schema print [sdl|json] [<file>]", arg)
+			}
+			output = arg
+		}
+	}
+
+	projects, err := loadProjects()
+	if err != nil {
+		return err
+	}
+	if len(projects) > 1 {
+		return errors.New("the config has multiple projects, select one with --project")
+	}
+	cfg := projects[0].cfg
+	if err := refreshSchema(cfg); err != nil {
+		return err
+	}
+	sch, err := schema.Load(cfg)
+	if err != nil {
+		return fmt.Errorf("failed to load schema: %w", err)
+	}
+
+	// Write to a buffer first to keep an existing file if printing fails. The fields of client schemas
+	// are left out, the printed schema may be used as schema source and they aren't known to the server.
+	sch = sch.ServerSchema()
+	var buf bytes.Buffer
+	switch {
+	case format != "json":
+		err = sch.WriteSDL(&buf)
+	case len(cfg.Input.Schemas) == 1 && cfg.Input.Schemas[0].IsRemote():
+		var data []byte
+		if data, err = schema.LoadIntrospection(cfg.Input, cfg.Input.Schemas[0].Path); err == nil {
+			buf.Write(data)
+		}
+	default:
+		err = sch.WriteIntrospection(&buf)
+	}
+	if err != nil {
+		return err
+	}
+
+	if output == "" {
+		_, err = buf.WriteTo(os.Stdout)
+		return err
+	}
+	if err := os.WriteFile(output, buf.Bytes(), 0644); err != nil {
+		return fmt.Errorf("failed to write schema: %w", err)
+	}
+	return nil
+}
+
+// runCache runs the cache subcommands
+func runCache() error {
+	usage := "Usage: gqlc cache [list|clear]"
+	if len(os.Args) < 3 {
+		return errors.New(usage)
+	}
+	switch os.Args[2] {
+	case "list":
+		entries, err := schema.ListIntrospectionCache()
+		if err != nil {
+			return err
+		}
+		if len(entries) == 0 {
+			fmt.Println("No cached schemas")
+			return nil
+		}
+		w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+		fmt.Fprintln(w, "ENDPOINT\tCACHED\tSIZE")
+		for _, entry := range entries {
+			age := time.Since(entry.FetchedAt).Truncate(time.Second)
+			fmt.Fprintf(w, "%s\t%s (%s ago)\t%s\n", entry.Endpoint, entry.FetchedAt.Format(time.DateTime), age, formatSize(entry.Size))
+		}
+		return w.Flush()
+	case "clear":
+		removed, err := schema.ClearIntrospectionCache()
+		if err != nil {
+			return err
+		}
+		fmt.Printf("Removed %s\n", pluralize(removed, "cached schema"))
+		return nil
+	case "help":
+		fmt.Println(usage)
+		return nil
+	default:
+		return fmt.Errorf("unknown command %q\n%s", os.Args[2], usage)
+	}
+}
+
+// formatSize formats a number of bytes for humans, e.g. 1.5 MB
+func formatSize(bytes int64) string {
+	const unit = 1000
+	if bytes < unit {
+		return fmt.Sprintf("%d B", bytes)
+	}
+	div, exp := int64(unit), 0
+	for n := bytes / unit; n >= unit; n /= unit {
+		div *= unit
+		exp++
+	}
+	return fmt.Sprintf("%.1f %cB", float64(bytes)/float64(div), "kMGTPE"[exp])
+}
+
+// outputPaths returns the file name of the generated schema and the paths of the generated schema and operations
+func outputPaths(cfg config.Config) (schemaName, schemaPath, operationsPath string) {
+	schemaName = fmt.Sprintf("schema%s.%s", cfg.Output.Suffix, cfg.Output.FileExtension())
+	schemaPath = filepath.Join(cfg.Output.Location, schemaName)
+	operationsPath = filepath.Join(cfg.Output.Location, fmt.Sprintf("operations%s.%s", cfg.Output.Suffix, cfg.Output.FileExtension()))
+	return
+}
+
+// manifestPath returns the path of the persisted query manifest
+func manifestPath(cfg config.Config) string {
+	return filepath.Join(cfg.Output.Location, "persisted-queries.json")
+}
+
+// removeManifest removes the persisted query manifest of an earlier build with persisted queries enabled,
+// a file with the same name that gqlc didn't write is kept
+func removeManifest(cfg config.Config) error {
+	path := manifestPath(cfg)
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("failed to read stale persisted query manifest: %w", err)
+	}
+	if !compiler.IsPersistedQueryManifest(data) {
+		return nil
+	}
+	if err := os.Remove(path); err != nil && !errors.Is(err, os.ErrNotExist) {
+		return fmt.Errorf("failed to remove stale persisted query manifest: %w", err)
+	}
+	return nil
+}
+
+// watchInterval is the time between two scans for changed files in watch mode
+const watchInterval = 300 * time.Millisecond
+
+// runWatch watches the projects concurrently and returns the first error that stops watching
+func runWatch() error {
+	projects, err := loadProjects()
+	if err != nil {
+		return err
+	}
+
+	errs := make(chan error, len(projects))
+	for _, p := range projects {
+		go func() {
+			if err := watchProject(p); err != nil && p.name != "" {
+				err = fmt.Errorf("project %s: %w", p.name, err)
+			}
+			errs <- err
+		}()
+	}
+	return <-errs
+}
+
+// watchOutput serializes the rebuilds of concurrently watched projects, so their output isn't interleaved
+var watchOutput sync.Mutex
+
+// watchProject compiles the operations and recompiles them whenever an operation or schema file changes.
+// Errors of a rebuild are printed without exiting, the previous output is kept until the next successful build.
+func watchProject(p projectConfig) error {
+	cfg := p.cfg
+	if err := refreshSchema(cfg); err != nil {
+		return err
+	}
+	if err := os.MkdirAll(cfg.Output.Location, 0755); err != nil {
+		return fmt.Errorf("failed to create output directory: %w", err)
+	}
+
+	build := compiler.NewBuild(cfg)
+	operationsWatcher := watch.New(cfg.Input.Operations)
+	// Schemas from a GraphQL endpoint are only loaded once
+	var schemaWatcher *watch.Watcher
+	if paths := cfg.Input.Schemas.LocalPaths(); len(paths) > 0 {
+		schemaWatcher = watch.New(paths...)
+	}
+
+	watchOutput.Lock()
+	startedAt := time.Now()
+	build.LoadSchema()
+	if err := build.UpdateFiles(operationsWatcher.Files()); err != nil {
+		watchOutput.Unlock()
+		return err
+	}
+	rebuild(p, build, startedAt)
+	fmt.Printf("Watching %s for changes\n", cfg.Input.Operations)
+	watchOutput.Unlock()
+
+	for range time.Tick(watchInterval) {
+		changedOperations := operationsWatcher.Poll()
+		var changedSchemas []string
+		if schemaWatcher != nil {
+			changedSchemas = schemaWatcher.Poll()
+		}
+		if len(changedOperations) == 0 && len(changedSchemas) == 0 {
+			continue
+		}
+
+		watchOutput.Lock()
+		startedAt := time.Now()
+		for _, path := range append(changedSchemas, changedOperations...) {
+			fmt.Printf("[%s] changed %s\n", startedAt.Format(time.TimeOnly), path)
+		}
+		if len(changedSchemas) > 0 {
+			build.LoadSchema()
+		}
+		if err := build.UpdateFiles(changedOperations); err != nil {
+			printError(err)
+		} else {
+			rebuild(p, build, startedAt)
+		}
+		watchOutput.Unlock()
+	}
+	return nil
+}
+
+// rebuild generates the code of the build and writes the output files whose content changed
+func rebuild(p projectConfig, build *compiler.Build, startedAt time.Time) {
+	outSchemaName, outSchemaPath, outOp := outputPaths(p.cfg)
+	label := ""
+	if p.name != "" {
+		label = " " + p.name + ":"
+	}
+	// Projects are watched concurrently, the warnings of a project are written at once under its name
+	var schemaCode, operationCode, manifest, warnings bytes.Buffer
+	err := build.Generate(&schemaCode, outSchemaName, &operationCode, &manifest, &warnings)
+	if warnings.Len() > 0 && p.name != "" {
+		_, _ = os.Stderr.Write(append([]byte("project "+p.name+":\n"), warnings.Bytes()...))
+	} else {
+		_, _ = warnings.WriteTo(os.Stderr)
+	}
+	if err != nil {
+		printError(err)
+		fmt.Printf("[%s]%s Build failed in %s\n", time.Now().Format(time.TimeOnly), label, time.Since(startedAt))
+		return
+	}
+
+	type output struct {
+		path string
+		code []byte
+	}
+	outputs := []output{
+		{outSchemaPath, schemaCode.Bytes()},
+		{outOp, operationCode.Bytes()},
+	}
+	if p.cfg.Output.PersistedQueries != "" {
+		outputs = append(outputs, output{manifestPath(p.cfg), manifest.Bytes()})
+	} else if err := removeManifest(p.cfg); err != nil {
+		printError(err)
+		return
+	}
+
+	var written []string
+	for _, out := range outputs {
+		changed, err := writeIfChanged(out.path, out.code)
+		if err != nil {
+			printError(err)
+			return
+		}
+		if changed {
+			written = append(written, out.path)
+		}
+	}
+
+	result := "no changes"
+	if len(written) > 0 {
+		result = "wrote " + strings.Join(written, ", ")
+	}
+	fmt.Printf("[%s]%s Finished in %s (%s)\n", time.Now().Format(time.TimeOnly), label, time.Since(startedAt), result)
+}
+
+// writeIfChanged writes the data to the file unless the file already has this content,
+// so tools watching the output don't rebuild for nothing
+func writeIfChanged(path string, data []byte) (bool, error) {
+	if current, err := os.ReadFile(path); err == nil && bytes.Equal(current, data) {
+		return false, nil
+	}
+	if err := os.WriteFile(path, data, 0644); err != nil {
+		return false, fmt.Errorf("failed to write output file: %w", err)
+	}
+	return true, nil
+}
+
+// printError prints an error to stderr.
+// Syntax and validation errors are printed one per line, prefixed with their location.
+func printError(err error) {
+	var syntaxErrors parser.Errors
+	var validationErrors validate.Errors
+	switch {
+	case errors.As(err, &syntaxErrors):
+		fmt.Fprintln(os.Stderr, syntaxErrors.Error())
+		fmt.Fprintln(os.Stderr, pluralize(len(syntaxErrors), "syntax error"))
+	case errors.As(err, &validationErrors):
+		fmt.Fprintln(os.Stderr, validationErrors.Error())
+		fmt.Fprintln(os.Stderr, pluralize(len(validationErrors), "validation error"))
+	default:
+		fmt.Fprintln(os.Stderr, err.Error())
+	}
+}
+
+func pluralize(n int, noun string) string {
+	if n == 1 {
+		return fmt.Sprintf("%d %s", n, noun)
+	}
+	return fmt.Sprintf("%d %ss", n, noun)
+}
+
 func initConfig() error {
 	ext := "yaml"
 	if len(os.Args) > 2 {
@@ -0,0 +1,132 @@
+package parser
+
+import (
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+// goInitialisms are words that are written in upper case in Go identifiers
+var goInitialisms = map[string]bool{
+	"API":  true,
+	"HTML": true,
+	"HTTP": true,
+	"ID":   true,
+	"JSON": true,
+	"SQL":  true,
+	"URI":  true,
+	"URL":  true,
+	"UUID": true,
+}
+
+// GoName converts a GraphQL name to an exported Go identifier,
+// e.g. "userId" becomes "UserID" and "NEW_HOPE" becomes "NewHope"
+func GoName(name string) string {
+	var buf strings.Builder
+	for _, word := range splitWords(name) {
+		upper := strings.ToUpper(word)
+		if goInitialisms[upper] {
+			buf.WriteString(upper)
+			continue
+		}
+		runes := []rune(strings.ToLower(word))
+		runes[0] = unicode.ToUpper(runes[0])
+		buf.WriteString(string(runes))
+	}
+	if buf.Len() == 0 || unicode.IsDigit([]rune(buf.String())[0]) {
+		return "X" + buf.String()
+	}
+	return buf.String()
+}
+
+// splitWords splits a name at underscores and at lower to upper case transitions
+func splitWords(name string) []string {
+	var words []string
+	var current []rune
+	for i, r := range []rune(name) {
+		if r == '_' {
+			if len(current) > 0 {
+				words = append(words, string(current))
+				current = nil
+			}
+			continue
+		}
+		if i > 0 && unicode.IsUpper(r) && len(current) > 0 && !unicode.IsUpper(current[len(current)-1]) {
+			words = append(words, string(current))
+			current = nil
+		}
+		current = append(current, r)
+	}
+	if len(current) > 0 {
+		words = append(words, string(current))
+	}
+	return words
+}
+
+// GoGraphQLString returns the GraphQL document as a Go string literal,
+// a raw string literal is used unless the document contains backticks
+func GoGraphQLString(s string) string {
+	if strings.Contains(s, "`") {
+		return strconv.Quote(s)
+	}
+	return "`" + s + "`"
+}
+
+// GenerateGoMethod generates a Client method for every operation of the document.
+// The variables and response types are generated by the schema package.
+func (d Document) GenerateGoMethod(w io.Writer) error {
+	for _, od := range d.Operations {
+		if err := od.generateGoMethod(w, d.FormattedOperationString(od)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (od OperationDefinition) generateGoMethod(w io.Writer, queryStr string) error {
+	funcName := od.generateFunctionName()
+	goName := GoName(funcName)
+	queryConstName := strings.ToLower(goName[:1]) + goName[1:] + "Query"
+
+	if _, err := fmt.Fprintf(w, "\nconst %s = %s\n", queryConstName, GoGraphQLString(queryStr)); err != nil {
+		return err
+	}
+
+	if len(od.Variables) > 0 {
+		_, err := fmt.Fprintf(w, `
+// %s executes the %s %s
+func (c *Client) %s(ctx context.Context, variables %sVariables) (*%sResponse, error) {
+	var data %sResponse
+	if err := c.execute(ctx, %s, variables, &data); err != nil {
+		return nil, err
+	}
+	return &data, nil
+}
+`,
+			goName, funcName, strings.ToLower(od.Type.String()),
+			goName, goName, goName,
+			goName,
+			queryConstName,
+		)
+		return err
+	}
+
+	_, err := fmt.Fprintf(w, `
+// %s executes the %s %s
+func (c *Client) %s(ctx context.Context) (*%sResponse, error) {
+	var data %sResponse
+	if err := c.execute(ctx, %s, nil, &data); err != nil {
+		return nil, err
+	}
+	return &data, nil
+}
+`,
+		goName, funcName, strings.ToLower(od.Type.String()),
+		goName, goName,
+		goName,
+		queryConstName,
+	)
+	return err
+}
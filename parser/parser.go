@@ -2,9 +2,13 @@ package parser
 
 import (
 	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
 	"fmt"
 	"gqlc/tokenizer"
 	"io"
+	"sort"
+	"strconv"
 	"strings"
 )
 
@@ -37,6 +41,20 @@ func (ot OperationType) String() string {
 	}
 }
 
+// Location is a position in a GraphQL source file
+type Location struct {
+	File   string `json:"file,omitempty"`
+	Line   int    `json:"line"`
+	Column int    `json:"column"`
+}
+
+func (l Location) String() string {
+	if l.File == "" {
+		return fmt.Sprintf("%d:%d", l.Line, l.Column)
+	}
+	return fmt.Sprintf("%s:%d:%d", l.File, l.Line, l.Column)
+}
+
 // Document represents a complete GraphQL document
 type Document struct {
 	Operations []OperationDefinition `json:"operations"`
@@ -46,16 +64,129 @@ type Document struct {
 
 func (d Document) astNode() {}
 
-// Error represents an error in the document
+// NewDocument groups the operation and fragment definitions of the given nodes into a single document
+func NewDocument(nodes []AST) Document {
+	var doc Document
+	for _, node := range nodes {
+		switch n := node.(type) {
+		case OperationDefinition:
+			doc.Operations = append(doc.Operations, n)
+		case FragmentDefinition:
+			doc.Fragments = append(doc.Fragments, n)
+		}
+	}
+	return doc
+}
+
+// Fragment returns the fragment definition with the given name
+func (d Document) Fragment(name string) (FragmentDefinition, bool) {
+	for _, fd := range d.Fragments {
+		if fd.Name == name {
+			return fd, true
+		}
+	}
+	return FragmentDefinition{}, false
+}
+
+// UsedFragments returns all fragments referenced by the selection set, including fragments
+// referenced by other fragments. Each fragment is returned once, in order of first use.
+func (d Document) UsedFragments(ss SelectionSet) []FragmentDefinition {
+	var used []FragmentDefinition
+	d.collectFragments(ss, make(map[string]bool), &used)
+	return used
+}
+
+func (d Document) collectFragments(ss SelectionSet, seen map[string]bool, used *[]FragmentDefinition) {
+	for _, sel := range ss.Selections {
+		switch s := sel.(type) {
+		case Field:
+			if s.SelectionSet != nil {
+				d.collectFragments(*s.SelectionSet, seen, used)
+			}
+		case InlineFragment:
+			d.collectFragments(s.SelectionSet, seen, used)
+		case FragmentSpread:
+			if seen[s.Name] {
+				continue
+			}
+			seen[s.Name] = true
+			fd, ok := d.Fragment(s.Name)
+			if !ok {
+				continue
+			}
+			*used = append(*used, fd)
+			d.collectFragments(fd.SelectionSet, seen, used)
+		}
+	}
+}
+
+// FormattedOperationString returns the formatted GraphQL document for the operation
+// with all fragment definitions it uses appended
+func (d Document) FormattedOperationString(od OperationDefinition) string {
+	var buf bytes.Buffer
+	buf.WriteString(od.generateFormattedGraphQLString())
+	for _, fd := range d.UsedFragments(od.SelectionSet) {
+		buf.WriteString("\n\n")
+		buf.WriteString(fd.FormattedString())
+	}
+	return buf.String()
+}
+
+// DocumentID returns the persisted query id of a formatted document, the hex encoded SHA-256 hash of it
+func DocumentID(document string) string {
+	hash := sha256.Sum256([]byte(document))
+	return hex.EncodeToString(hash[:])
+}
+
+// PersistedQueries returns the formatted documents of all operations keyed by their DocumentID
+func (d Document) PersistedQueries() map[string]string {
+	queries := make(map[string]string, len(d.Operations))
+	for _, od := range d.Operations {
+		document := d.FormattedOperationString(od)
+		queries[DocumentID(document)] = document
+	}
+	return queries
+}
+
+// Error represents a syntax error in the document
 type Error struct {
-	error
+	Loc     Location `json:"loc"`
+	Message string   `json:"message"`
 }
 
 func (e Error) astNode() {}
+func (e Error) Error() string {
+	return fmt.Sprintf("%s: %s", e.Loc, e.Message)
+}
 func (e Error) String() string {
 	return e.Error()
 }
 
+// Errors is a list of syntax errors
+type Errors []Error
+
+func (es Errors) Error() string {
+	messages := make([]string, len(es))
+	for i, e := range es {
+		messages[i] = e.Error()
+	}
+	return strings.Join(messages, "\n")
+}
+
+// Sort orders the errors by file, line and column
+func (es Errors) Sort() {
+	sort.SliceStable(es, func(i, j int) bool {
+		a, b := es[i].Loc, es[j].Loc
+		if a.File != b.File {
+			return a.File < b.File
+		}
+		if a.Line != b.Line {
+			return a.Line < b.Line
+		}
+		return a.Column < b.Column
+	})
+}
+
 // OperationDefinition represents a query, mutation, or subscription
 type OperationDefinition struct {
 	Type         OperationType        `json:"type"`
@@ -63,7 +194,9 @@ type OperationDefinition struct {
 	Variables    []VariableDefinition `json:"variables,omitempty"`
 	Directives   []Directive          `json:"directives,omitempty"`
 	SelectionSet SelectionSet         `json:"selectionSet"`
+	Description  *string              `json:"description,omitempty"`
 	Metadata     []string             `json:"metadata,omitempty"`
+	Loc          Location             `json:"-"`
 }
 
 func (od OperationDefinition) astNode() {}
@@ -75,10 +208,23 @@ type FragmentDefinition struct {
 	Directives   []Directive  `json:"directives,omitempty"`
 	SelectionSet SelectionSet `json:"selectionSet"`
 	Metadata     []string     `json:"metadata,omitempty"`
+	Loc          Location     `json:"-"`
 }
 
 func (fd FragmentDefinition) astNode() {}
 
+func (fd FragmentDefinition) FormattedString() string {
+	var buf bytes.Buffer
+	buf.WriteString("fragment ")
+	buf.WriteString(fd.Name)
+	buf.WriteString(" on ")
+	buf.WriteString(fd.TypeName)
+	buf.WriteString(directivesString(fd.Directives))
+	buf.WriteString(" ")
+	buf.WriteString(fd.SelectionSet.FormattedString(1))
+	return buf.String()
+}
+
 // SelectionSet represents a set of fields
 type SelectionSet struct {
 	Selections []Selection `json:"selections"`
@@ -98,11 +244,14 @@ func (ss SelectionSet) String() string {
 			buf.WriteString(s.String())
 		case FragmentSpread:
 			buf.WriteString("..." + s.Name)
+			buf.WriteString(directivesString(s.Directives))
 		case InlineFragment:
-			buf.WriteString("...on ")
+			buf.WriteString("...")
 			if s.TypeName != nil {
+				buf.WriteString("on ")
 				buf.WriteString(*s.TypeName)
 			}
+			buf.WriteString(directivesString(s.Directives))
 			buf.WriteString(" ")
 			buf.WriteString(s.SelectionSet.String())
 		default:
@@ -125,13 +274,16 @@ func (ss SelectionSet) FormattedString(indent int) string {
 		case FragmentSpread:
 			buf.WriteString(strings.Repeat("  ", indent))
 			buf.WriteString("..." + s.Name)
+			buf.WriteString(directivesString(s.Directives))
 			buf.WriteString("\n")
 		case InlineFragment:
 			buf.WriteString(strings.Repeat("  ", indent))
-			buf.WriteString("...on ")
+			buf.WriteString("...")
 			if s.TypeName != nil {
+				buf.WriteString(" on ")
 				buf.WriteString(*s.TypeName)
 			}
+			buf.WriteString(directivesString(s.Directives))
 			buf.WriteString(" ")
 			buf.WriteString(s.SelectionSet.FormattedString(indent + 1))
 			buf.WriteString("\n")
@@ -155,10 +307,19 @@ type Field struct {
 	Directives   []Directive   `json:"directives,omitempty"`
 	SelectionSet *SelectionSet `json:"selectionSet,omitempty"`
 	Metadata     []string      `json:"metadata,omitempty"`
+	Loc          Location      `json:"-"`
 }
 
 func (f Field) selection() {}
 
+// ResponseKey returns the key of the field in the response, which is the alias if one is given
+func (f Field) ResponseKey() string {
+	if f.Alias != nil {
+		return *f.Alias
+	}
+	return f.Name
+}
+
 func (f Field) String() string {
 	var buf bytes.Buffer
 	if f.Alias != nil {
@@ -174,11 +335,11 @@ func (f Field) String() string {
 			}
 			buf.WriteString(arg.Name)
 			buf.WriteString(": ")
-			// TODO: implement Value.String()
-			buf.WriteString("$" + arg.Name)
+			buf.WriteString(arg.Value.String())
 		}
 		buf.WriteString(")")
 	}
+	buf.WriteString(directivesString(f.Directives))
 	if f.SelectionSet != nil {
 		buf.WriteString(" ")
 		buf.WriteString(f.SelectionSet.String())
@@ -201,17 +362,11 @@ func (f Field) FormattedString(indent int) string {
 			}
 			buf.WriteString(arg.Name)
 			buf.WriteString(": ")
-			// For variables, use $ prefix
-			switch v := arg.Value.(type) {
-			case Variable:
-				buf.WriteString("$" + v.Name)
-			default:
-				// TODO: implement proper Value.String() for other types
-				buf.WriteString("$" + arg.Name)
-			}
+			buf.WriteString(arg.Value.String())
 		}
 		buf.WriteString(")")
 	}
+	buf.WriteString(directivesString(f.Directives))
 	if f.SelectionSet != nil {
 		buf.WriteString(" ")
 		buf.WriteString(f.SelectionSet.FormattedString(indent + 1))
@@ -225,6 +380,7 @@ type InlineFragment struct {
 	Directives   []Directive  `json:"directives,omitempty"`
 	SelectionSet SelectionSet `json:"selectionSet"`
 	Metadata     []string     `json:"metadata,omitempty"`
+	Loc          Location     `json:"-"`
 }
 
 func (if_ InlineFragment) selection() {}
@@ -234,6 +390,7 @@ type FragmentSpread struct {
 	Name       string      `json:"name"`
 	Directives []Directive `json:"directives,omitempty"`
 	Metadata   []string    `json:"metadata,omitempty"`
+	Loc        Location    `json:"-"`
 }
 
 func (fs FragmentSpread) selection() {}
@@ -244,6 +401,7 @@ type VariableDefinition struct {
 	Type         Type     `json:"type"`
 	DefaultValue *Value   `json:"defaultValue,omitempty"`
 	Metadata     []string `json:"metadata,omitempty"`
+	Loc          Location `json:"-"`
 }
 
 // Type represents a GraphQL type
@@ -287,19 +445,51 @@ func (nnt NonNullType) String() string {
 
 // Argument represents a field argument
 type Argument struct {
-	Name  string `json:"name"`
-	Value Value  `json:"value"`
+	Name  string   `json:"name"`
+	Value Value    `json:"value"`
+	Loc   Location `json:"-"`
 }
 
 // Directive represents a directive
 type Directive struct {
 	Name      string     `json:"name"`
 	Arguments []Argument `json:"arguments,omitempty"`
+	Loc       Location   `json:"-"`
+}
+
+func (d Directive) String() string {
+	var buf bytes.Buffer
+	buf.WriteString("@")
+	buf.WriteString(d.Name)
+	if len(d.Arguments) > 0 {
+		buf.WriteString("(")
+		for i, arg := range d.Arguments {
+			if i > 0 {
+				buf.WriteString(", ")
+			}
+			buf.WriteString(arg.Name)
+			buf.WriteString(": ")
+			buf.WriteString(arg.Value.String())
+		}
+		buf.WriteString(")")
+	}
+	return buf.String()
+}
+
+// directivesString formats directives to follow another token, separated by leading spaces
+func directivesString(directives []Directive) string {
+	var buf bytes.Buffer
+	for _, d := range directives {
+		buf.WriteString(" ")
+		buf.WriteString(d.String())
+	}
+	return buf.String()
 }
 
 // Value represents any GraphQL value
 type Value interface {
 	value()
+	String() string
 }
 
 // StringValue represents a string literal
@@ -309,6 +499,57 @@ type StringValue struct {
 
 func (sv StringValue) value() {}
 
+func (sv StringValue) String() string {
+	return sv.Value
+}
+
+// Text returns the content of the string without quotes and escape sequences
+func (sv StringValue) Text() string {
+	if strings.HasPrefix(sv.Value, `"""`) {
+		return blockStringValue(strings.TrimSuffix(strings.TrimPrefix(sv.Value, `"""`), `"""`))
+	}
+	// GraphQL allows an escaped slash, Go does not
+	text, err := strconv.Unquote(strings.ReplaceAll(sv.Value, `\/`, "/"))
+	if err != nil {
+		return strings.Trim(sv.Value, `"`)
+	}
+	return text
+}
+
+// blockStringValue removes the common indentation and the leading and trailing blank lines of a block string
+func blockStringValue(raw string) string {
+	lines := strings.Split(strings.ReplaceAll(raw, "\r\n", "\n"), "\n")
+
+	commonIndent := -1
+	for _, line := range lines[1:] {
+		trimmed := strings.TrimLeft(line, " \t")
+		if trimmed == "" {
+			continue
+		}
+		if indent := len(line) - len(trimmed); commonIndent == -1 || indent < commonIndent {
+			commonIndent = indent
+		}
+	}
+	if commonIndent > 0 {
+		for i := 1; i < len(lines); i++ {
+			if len(lines[i]) >= commonIndent {
+				lines[i] = lines[i][commonIndent:]
+			} else {
+				lines[i] = ""
+			}
+		}
+	}
+
+	for len(lines) > 0 && strings.TrimSpace(lines[0]) == "" {
+		lines = lines[1:]
+	}
+	for len(lines) > 0 && strings.TrimSpace(lines[len(lines)-1]) == "" {
+		lines = lines[:len(lines)-1]
+	}
+
+	return strings.ReplaceAll(strings.Join(lines, "\n"), `\"""`, `"""`)
+}
+
 // IntValue represents an integer literal
 type IntValue struct {
 	Value string `json:"value"`
@@ -316,6 +557,10 @@ type IntValue struct {
 
 func (iv IntValue) value() {}
 
+func (iv IntValue) String() string {
+	return iv.Value
+}
+
 // FloatValue represents a float literal
 type FloatValue struct {
 	Value string `json:"value"`
@@ -323,6 +568,10 @@ type FloatValue struct {
 
 func (fv FloatValue) value() {}
 
+func (fv FloatValue) String() string {
+	return fv.Value
+}
+
 // BooleanValue represents a boolean literal
 type BooleanValue struct {
 	Value bool `json:"value"`
@@ -330,18 +579,45 @@ type BooleanValue struct {
 
 func (bv BooleanValue) value() {}
 
+func (bv BooleanValue) String() string {
+	if bv.Value {
+		return "true"
+	}
+	return "false"
+}
+
 // NullValue represents a null value
 type NullValue struct{}
 
 func (nv NullValue) value() {}
 
+func (nv NullValue) String() string {
+	return "null"
+}
+
+// EnumValue represents an enum value literal
+type EnumValue struct {
+	Value string `json:"value"`
+}
+
+func (ev EnumValue) value() {}
+
+func (ev EnumValue) String() string {
+	return ev.Value
+}
+
 // Variable represents a variable reference
 type Variable struct {
-	Name string `json:"name"`
+	Name string   `json:"name"`
+	Loc  Location `json:"-"`
 }
 
 func (v Variable) value() {}
 
+func (v Variable) String() string {
+	return "$" + v.Name
+}
+
 // ListValue represents a list literal
 type ListValue struct {
 	Values []Value `json:"values"`
@@ -349,6 +625,19 @@ type ListValue struct {
 
 func (lv ListValue) value() {}
 
+func (lv ListValue) String() string {
+	var buf bytes.Buffer
+	buf.WriteString("[")
+	for i, v := range lv.Values {
+		if i > 0 {
+			buf.WriteString(", ")
+		}
+		buf.WriteString(v.String())
+	}
+	buf.WriteString("]")
+	return buf.String()
+}
+
 // ObjectValue represents an object literal
 type ObjectValue struct {
 	Fields []ObjectField `json:"fields"`
@@ -356,6 +645,21 @@ type ObjectValue struct {
 
 func (ov ObjectValue) value() {}
 
+func (ov ObjectValue) String() string {
+	var buf bytes.Buffer
+	buf.WriteString("{")
+	for i, f := range ov.Fields {
+		if i > 0 {
+			buf.WriteString(", ")
+		}
+		buf.WriteString(f.Name)
+		buf.WriteString(": ")
+		buf.WriteString(f.Value.String())
+	}
+	buf.WriteString("}")
+	return buf.String()
+}
+
 // ObjectField represents a field in an object literal
 type ObjectField struct {
 	Name  string `json:"name"`
@@ -364,22 +668,25 @@ type ObjectField struct {
 
 // TypeDefinition represents a type definition
 type TypeDefinition struct {
-	Name       string            `json:"name"`
-	Interfaces []string          `json:"interfaces,omitempty"`
-	Fields     []FieldDefinition `json:"fields,omitempty"`
-	Directives []Directive       `json:"directives,omitempty"`
-	Metadata   []string          `json:"metadata,omitempty"`
+	Name        string            `json:"name"`
+	Interfaces  []string          `json:"interfaces,omitempty"`
+	Fields      []FieldDefinition `json:"fields,omitempty"`
+	Directives  []Directive       `json:"directives,omitempty"`
+	Description *string           `json:"description,omitempty"`
+	Metadata    []string          `json:"metadata,omitempty"`
+	Extend      bool              `json:"extend,omitempty"`
 }
 
 func (td TypeDefinition) astNode() {}
 
 // FieldDefinition represents a field definition in a type
 type FieldDefinition struct {
-	Name       string                 `json:"name"`
-	Arguments  []InputValueDefinition `json:"arguments,omitempty"`
-	Type       Type                   `json:"type"`
-	Directives []Directive            `json:"directives,omitempty"`
-	Metadata   []string               `json:"metadata,omitempty"`
+	Name        string                 `json:"name"`
+	Arguments   []InputValueDefinition `json:"arguments,omitempty"`
+	Type        Type                   `json:"type"`
+	Directives  []Directive            `json:"directives,omitempty"`
+	Description *string                `json:"description,omitempty"`
+	Metadata    []string               `json:"metadata,omitempty"`
 }
 
 // InputValueDefinition represents an argument or input field definition
@@ -388,75 +695,126 @@ type InputValueDefinition struct {
 	Type         Type        `json:"type"`
 	DefaultValue *Value      `json:"defaultValue,omitempty"`
 	Directives   []Directive `json:"directives,omitempty"`
+	Description  *string     `json:"description,omitempty"`
 	Metadata     []string    `json:"metadata,omitempty"`
 }
 
 // InputTypeDefinition represents an input type definition
 type InputTypeDefinition struct {
-	Name       string                 `json:"name"`
-	Fields     []InputValueDefinition `json:"fields,omitempty"`
-	Directives []Directive            `json:"directives,omitempty"`
-	Metadata   []string               `json:"metadata,omitempty"`
+	Name        string                 `json:"name"`
+	Fields      []InputValueDefinition `json:"fields,omitempty"`
+	Directives  []Directive            `json:"directives,omitempty"`
+	Description *string                `json:"description,omitempty"`
+	Metadata    []string               `json:"metadata,omitempty"`
+	Extend      bool                   `json:"extend,omitempty"`
 }
 
 func (itd InputTypeDefinition) astNode() {}
 
 // EnumTypeDefinition represents an enum type definition
 type EnumTypeDefinition struct {
-	Name       string                `json:"name"`
-	Values     []EnumValueDefinition `json:"values,omitempty"`
-	Directives []Directive           `json:"directives,omitempty"`
-	Metadata   []string              `json:"metadata,omitempty"`
+	Name        string                `json:"name"`
+	Values      []EnumValueDefinition `json:"values,omitempty"`
+	Directives  []Directive           `json:"directives,omitempty"`
+	Description *string               `json:"description,omitempty"`
+	Metadata    []string              `json:"metadata,omitempty"`
+	Extend      bool                  `json:"extend,omitempty"`
 }
 
 func (etd EnumTypeDefinition) astNode() {}
 
 // EnumValueDefinition represents an enum value definition
 type EnumValueDefinition struct {
-	Name       string      `json:"name"`
-	Directives []Directive `json:"directives,omitempty"`
-	Metadata   []string    `json:"metadata,omitempty"`
+	Name        string      `json:"name"`
+	Directives  []Directive `json:"directives,omitempty"`
+	Description *string     `json:"description,omitempty"`
+	Metadata    []string    `json:"metadata,omitempty"`
 }
 
 // ScalarTypeDefinition represents a scalar type definition
 type ScalarTypeDefinition struct {
-	Name       string      `json:"name"`
-	Directives []Directive `json:"directives,omitempty"`
-	Metadata   []string    `json:"metadata,omitempty"`
+	Name        string      `json:"name"`
+	Directives  []Directive `json:"directives,omitempty"`
+	Description *string     `json:"description,omitempty"`
+	Metadata    []string    `json:"metadata,omitempty"`
+	Extend      bool        `json:"extend,omitempty"`
 }
 
 func (std ScalarTypeDefinition) astNode() {}
 
 // InterfaceTypeDefinition represents an interface type definition
 type InterfaceTypeDefinition struct {
-	Name       string            `json:"name"`
-	Fields     []FieldDefinition `json:"fields,omitempty"`
-	Directives []Directive       `json:"directives,omitempty"`
-	Metadata   []string          `json:"metadata,omitempty"`
+	Name        string            `json:"name"`
+	Interfaces  []string          `json:"interfaces,omitempty"`
+	Fields      []FieldDefinition `json:"fields,omitempty"`
+	Directives  []Directive       `json:"directives,omitempty"`
+	Description *string           `json:"description,omitempty"`
+	Metadata    []string          `json:"metadata,omitempty"`
+	Extend      bool              `json:"extend,omitempty"`
 }
 
 func (itd InterfaceTypeDefinition) astNode() {}
 
 // UnionTypeDefinition represents a union type definition
 type UnionTypeDefinition struct {
-	Name       string      `json:"name"`
-	Types      []string    `json:"types,omitempty"`
-	Directives []Directive `json:"directives,omitempty"`
-	Metadata   []string    `json:"metadata,omitempty"`
+	Name        string      `json:"name"`
+	Types       []string    `json:"types,omitempty"`
+	Directives  []Directive `json:"directives,omitempty"`
+	Description *string     `json:"description,omitempty"`
+	Metadata    []string    `json:"metadata,omitempty"`
+	Extend      bool        `json:"extend,omitempty"`
 }
 
 func (utd UnionTypeDefinition) astNode() {}
 
+// SchemaDefinition represents a schema definition or extension
+type SchemaDefinition struct {
+	OperationTypes []OperationTypeDefinition `json:"operationTypes,omitempty"`
+	Directives     []Directive               `json:"directives,omitempty"`
+	Metadata       []string                  `json:"metadata,omitempty"`
+	Extend         bool                      `json:"extend,omitempty"`
+}
+
+func (sd SchemaDefinition) astNode() {}
+
+// OperationTypeDefinition maps an operation type to its root type in a schema definition
+type OperationTypeDefinition struct {
+	Operation OperationType `json:"operation"`
+	Type      string        `json:"type"`
+}
+
+// DirectiveDefinition represents a directive definition
+type DirectiveDefinition struct {
+	Name        string                 `json:"name"`
+	Arguments   []InputValueDefinition `json:"arguments,omitempty"`
+	Repeatable  bool                   `json:"repeatable,omitempty"`
+	Locations   []string               `json:"locations"`
+	Description *string                `json:"description,omitempty"`
+	Metadata    []string               `json:"metadata,omitempty"`
+}
+
+func (dd DirectiveDefinition) astNode() {}
+
 // Parser state
 type parser struct {
+	file           string
 	tokens         <-chan tokenizer.Token
 	currentToken   tokenizer.Token
 	peekToken      tokenizer.Token
 	pendingComment []string
+	// pendingDescription is the description string preceding the next definition
+	pendingDescription *string
+	depth              int
 }
 
 // Parse creates a streaming parser that outputs AST nodes
 func Parse(r io.Reader) <-chan AST {
+	return ParseFile("", r)
+}
+
+// ParseFile creates a streaming parser that outputs AST nodes
+// with locations referring to the given file name
+func ParseFile(name string, r io.Reader) <-chan AST {
 	ch := make(chan AST)
 
 	go func() {
@@ -464,6 +822,7 @@ func Parse(r io.Reader) <-chan AST {
 
 		tokens := tokenizer.Tokenize(r)
 		p := newParser(tokens)
+		p.file = name
 
 		for p.currentToken.Type != tokenizer.EOF {
 			if p.currentToken.Type == tokenizer.COMMENT {
@@ -477,7 +836,7 @@ func Parse(r io.Reader) <-chan AST {
 				continue
 			}
 
-			ast := parseDefinition(p)
+			ast := p.parseDefinitionOrError()
 			if ast != nil {
 				ch <- ast
 			}
@@ -497,6 +856,16 @@ func newParser(tokens <-chan tokenizer.Token) *parser {
 
 // nextToken advances the parser to the next token
 func (p *parser) nextToken() {
+	// Track the brace depth to find the next top-level definition after a syntax error
+	switch p.currentToken.Type {
+	case tokenizer.LBRACE:
+		p.depth++
+	case tokenizer.RBRACE:
+		if p.depth > 0 {
+			p.depth--
+		}
+	}
+
 	p.currentToken = p.peekToken
 
 	token, ok := <-p.tokens
@@ -508,6 +877,86 @@ func (p *parser) nextToken() {
 	}
 }
 
+// location returns the location of the current token
+func (p *parser) location() Location {
+	return Location{File: p.file, Line: p.currentToken.Line, Column: p.currentToken.Column}
+}
+
+// errorf aborts parsing the current definition with a syntax error at the current token
+func (p *parser) errorf(format string, args ...any) {
+	panic(Error{Loc: p.location(), Message: fmt.Sprintf(format, args...)})
+}
+
+// parseDefinitionOrError parses a top-level definition.
+// If the definition contains a syntax error, the error is returned as an Error node
+// and the parser skips ahead to the next top-level definition.
+func (p *parser) parseDefinitionOrError() (ast AST) {
+	start := p.currentToken
+	defer func() {
+		if r := recover(); r != nil {
+			err, ok := r.(Error)
+			if !ok {
+				panic(r)
+			}
+			p.synchronize(start)
+			ast = err
+		}
+	}()
+	return parseDefinition(p)
+}
+
+// synchronize skips tokens until the start of the next top-level definition.
+// A definition keyword starts a new definition if it is not nested in braces or if it is at the start of a line,
+// so an unclosed selection set doesn't swallow the rest of the file.
+func (p *parser) synchronize(start tokenizer.Token) {
+	p.pendingComment = nil
+	if p.currentToken == start {
+		p.nextToken()
+	}
+	for p.currentToken.Type != tokenizer.EOF {
+		if isDefinitionStart(p.currentToken.Type) && (p.depth == 0 || p.currentToken.Column == 1) {
+			break
+		}
+		if p.currentToken.Type == tokenizer.LBRACE && p.currentToken.Column == 1 {
+			break
+		}
+		p.nextToken()
+	}
+	p.depth = 0
+}
+
+// isDefinitionStart checks if a token can start a top-level definition
+func isDefinitionStart(tokenType tokenizer.TokenType) bool {
+	switch tokenType {
+	case tokenizer.QUERY,
+		tokenizer.MUTATION,
+		tokenizer.SUBSCRIPTION,
+		tokenizer.FRAGMENT,
+		tokenizer.TYPE,
+		tokenizer.SCHEMA,
+		tokenizer.SCALAR,
+		tokenizer.ENUM,
+		tokenizer.INTERFACE,
+		tokenizer.UNION,
+		tokenizer.INPUT,
+		tokenizer.EXTEND,
+		tokenizer.DIRECTIVE:
+		return true
+	default:
+		return false
+	}
+}
+
+// describeToken returns a human readable description of a token for error messages
+func describeToken(token tokenizer.Token) string {
+	switch token.Type {
+	case tokenizer.IDENT, tokenizer.INT, tokenizer.FLOAT, tokenizer.STRING, tokenizer.ILLEGAL:
+		return fmt.Sprintf("%s %q", token.Type, token.Literal)
+	default:
+		return token.Type.String()
+	}
+}
+
 // handleComment processes comments and extracts gqlc metadata
 func (p *parser) handleComment() {
 	content := strings.TrimSpace(p.currentToken.Literal[1:])
@@ -516,7 +965,11 @@ func (p *parser) handleComment() {
 }
 
 // handleDocumentation processes documentation strings (triple-quoted strings in GraphQL)
+// and remembers them as the description of the next definition
 func (p *parser) handleDocumentation() {
+	description := StringValue{Value: p.currentToken.Literal}.Text()
+	p.pendingDescription = &description
+
 	// Extract the content from the triple-quoted string
 	// Remove the triple quotes and trim whitespace
 	content := p.currentToken.Literal
@@ -550,8 +1003,31 @@ func (p *parser) skipCommentsAndDocs() {
 	}
 }
 
+// skipCommentsAndDescriptions is like skipCommentsAndDocs, but also accepts single-quoted
+// strings as descriptions, which is only valid in type system definitions
+func (p *parser) skipCommentsAndDescriptions() {
+	for {
+		switch p.currentToken.Type {
+		case tokenizer.COMMENT:
+			p.handleComment()
+		case tokenizer.STRING:
+			p.handleDocumentation()
+		default:
+			return
+		}
+	}
+}
+
+// extractDescription returns the pending description
+func (p *parser) extractDescription() *string {
+	description := p.pendingDescription
+	p.pendingDescription = nil
+	return description
+}
+
 // extractMetadata creates metadata from pending comments
 func (p *parser) extractMetadata() []string {
+	p.pendingDescription = nil
 	if len(p.pendingComment) == 0 {
 		return nil
 	}
@@ -606,20 +1082,28 @@ func parseDefinition(p *parser) AST {
 		return parseInterfaceTypeDefinition(p)
 	case tokenizer.UNION:
 		return parseUnionTypeDefinition(p)
+	case tokenizer.SCHEMA:
+		return parseSchemaDefinition(p)
+	case tokenizer.EXTEND:
+		return parseTypeExtension(p)
+	case tokenizer.DIRECTIVE:
+		return parseDirectiveDefinition(p)
 	case tokenizer.LBRACE:
 		// Anonymous query
 		return parseAnonymousQuery(p)
 	case tokenizer.EOF:
 		return nil
 	default:
-		panic(fmt.Sprintf("unexpected token %s at line %d, column %d",
-			p.currentToken.Type, p.currentToken.Line, p.currentToken.Column))
+		p.errorf("unexpected token %s", describeToken(p.currentToken))
 	}
+	return nil
 }
 
 // parseOperationDefinition parses a named operation
 func parseOperationDefinition(p *parser) OperationDefinition {
+	description := p.extractDescription()
 	metadata := p.extractMetadata()
+	loc := p.location()
 
 	var opType OperationType
 	switch p.currentToken.Type {
@@ -630,8 +1114,7 @@ func parseOperationDefinition(p *parser) OperationDefinition {
 	case tokenizer.SUBSCRIPTION:
 		opType = Subscription
 	default:
-		panic(fmt.Sprintf("expected operation type, got %s at line %d, column %d",
-			p.currentToken.Type, p.currentToken.Line, p.currentToken.Column))
+		p.errorf("expected operation type, got %s", describeToken(p.currentToken))
 	}
 
 	p.nextToken()
@@ -666,32 +1149,36 @@ func parseOperationDefinition(p *parser) OperationDefinition {
 		Variables:    variables,
 		Directives:   directives,
 		SelectionSet: selectionSet,
+		Description:  description,
 		Metadata:     metadata,
+		Loc:          loc,
 	}
 }
 
 // parseAnonymousQuery parses an anonymous query (starts with {)
 func parseAnonymousQuery(p *parser) OperationDefinition {
 	metadata := p.extractMetadata()
+	loc := p.location()
 	selectionSet := parseSelectionSet(p)
 
 	return OperationDefinition{
 		Type:         Query,
 		SelectionSet: selectionSet,
 		Metadata:     metadata,
+		Loc:          loc,
 	}
 }
 
 // parseFragmentDefinition parses a fragment definition
 func parseFragmentDefinition(p *parser) FragmentDefinition {
 	metadata := p.extractMetadata()
+	loc := p.location()
 
 	expectToken(p, tokenizer.FRAGMENT)
 	p.nextToken()
 
 	if p.currentToken.Type != tokenizer.IDENT {
-		panic(fmt.Sprintf("expected fragment name, got %s at line %d, column %d",
-			p.currentToken.Type, p.currentToken.Line, p.currentToken.Column))
+		p.errorf("expected fragment name, got %s", describeToken(p.currentToken))
 	}
 
 	name := p.currentToken.Literal
@@ -701,8 +1188,7 @@ func parseFragmentDefinition(p *parser) FragmentDefinition {
 	p.nextToken()
 
 	if p.currentToken.Type != tokenizer.IDENT {
-		panic(fmt.Sprintf("expected type name, got %s at line %d, column %d",
-			p.currentToken.Type, p.currentToken.Line, p.currentToken.Column))
+		p.errorf("expected type name, got %s", describeToken(p.currentToken))
 	}
 
 	typeName := p.currentToken.Literal
@@ -721,19 +1207,20 @@ func parseFragmentDefinition(p *parser) FragmentDefinition {
 		Directives:   directives,
 		SelectionSet: selectionSet,
 		Metadata:     metadata,
+		Loc:          loc,
 	}
 }
 
 // parseTypeDefinition parses a type definition
 func parseTypeDefinition(p *parser) TypeDefinition {
+	description := p.extractDescription()
 	metadata := p.extractMetadata()
 
 	expectToken(p, tokenizer.TYPE)
 	p.nextToken()
 
 	if p.currentToken.Type != tokenizer.IDENT {
-		panic(fmt.Sprintf("expected type name, got %s at line %d, column %d",
-			p.currentToken.Type, p.currentToken.Line, p.currentToken.Column))
+		p.errorf("expected type name, got %s", describeToken(p.currentToken))
 	}
 
 	name := p.currentToken.Literal
@@ -755,11 +1242,12 @@ func parseTypeDefinition(p *parser) TypeDefinition {
 	}
 
 	return TypeDefinition{
-		Name:       name,
-		Interfaces: interfaces,
-		Fields:     fields,
-		Directives: directives,
-		Metadata:   metadata,
+		Name:        name,
+		Interfaces:  interfaces,
+		Fields:      fields,
+		Directives:  directives,
+		Description: description,
+		Metadata:    metadata,
 	}
 }
 
@@ -772,8 +1260,7 @@ func parseImplementsInterfaces(p *parser) []string {
 
 	for {
 		if p.currentToken.Type != tokenizer.IDENT {
-			panic(fmt.Sprintf("expected interface name, got %s at line %d, column %d",
-				p.currentToken.Type, p.currentToken.Line, p.currentToken.Column))
+			p.errorf("expected interface name, got %s", describeToken(p.currentToken))
 		}
 
 		interfaces = append(interfaces, p.currentToken.Literal)
@@ -798,11 +1285,11 @@ func parseFieldDefinitions(p *parser) []FieldDefinition {
 
 	for p.currentToken.Type != tokenizer.RBRACE {
 		if p.currentToken.Type == tokenizer.EOF {
-			panic("unexpected EOF in field definitions")
+			p.errorf("unexpected EOF in field definitions")
 		}
 
 		// Skip comments and documentation strings
-		p.skipCommentsAndDocs()
+		p.skipCommentsAndDescriptions()
 
 		// Check again after skipping ignored tokens
 		if p.currentToken.Type == tokenizer.RBRACE {
@@ -821,11 +1308,11 @@ func parseFieldDefinitions(p *parser) []FieldDefinition {
 
 // parseFieldDefinition parses a single field definition
 func parseFieldDefinition(p *parser) FieldDefinition {
+	description := p.extractDescription()
 	metadata := p.extractMetadata()
 
 	if !isNameToken(p.currentToken.Type) {
-		panic(fmt.Sprintf("expected field name, got %s at line %d, column %d",
-			p.currentToken.Type, p.currentToken.Line, p.currentToken.Column))
+		p.errorf("expected field name, got %s", describeToken(p.currentToken))
 	}
 
 	name := p.currentToken.Literal
@@ -847,11 +1334,12 @@ func parseFieldDefinition(p *parser) FieldDefinition {
 	}
 
 	return FieldDefinition{
-		Name:       name,
-		Arguments:  arguments,
-		Type:       fieldType,
-		Directives: directives,
-		Metadata:   metadata,
+		Name:        name,
+		Arguments:   arguments,
+		Type:        fieldType,
+		Directives:  directives,
+		Description: description,
+		Metadata:    metadata,
 	}
 }
 
@@ -864,11 +1352,11 @@ func parseInputValueDefinitions(p *parser) []InputValueDefinition {
 
 	for p.currentToken.Type != tokenizer.RPAREN {
 		if p.currentToken.Type == tokenizer.EOF {
-			panic("unexpected EOF in input value definitions")
+			p.errorf("unexpected EOF in input value definitions")
 		}
 
 		// Skip comments and documentation strings
-		p.skipCommentsAndDocs()
+		p.skipCommentsAndDescriptions()
 
 		// Check again after skipping ignored tokens
 		if p.currentToken.Type == tokenizer.RPAREN {
@@ -891,11 +1379,11 @@ func parseInputValueDefinitions(p *parser) []InputValueDefinition {
 
 // parseInputValueDefinition parses a single input value definition
 func parseInputValueDefinition(p *parser) InputValueDefinition {
+	description := p.extractDescription()
 	metadata := p.extractMetadata()
 
 	if !isNameToken(p.currentToken.Type) {
-		panic(fmt.Sprintf("expected input name, got %s at line %d, column %d",
-			p.currentToken.Type, p.currentToken.Line, p.currentToken.Column))
+		p.errorf("expected input name, got %s", describeToken(p.currentToken))
 	}
 
 	name := p.currentToken.Literal
@@ -923,20 +1411,21 @@ func parseInputValueDefinition(p *parser) InputValueDefinition {
 		Type:         inputType,
 		DefaultValue: defaultValue,
 		Directives:   directives,
+		Description:  description,
 		Metadata:     metadata,
 	}
 }
 
 // parseInputTypeDefinition parses an input type definition
 func parseInputTypeDefinition(p *parser) InputTypeDefinition {
+	description := p.extractDescription()
 	metadata := p.extractMetadata()
 
 	expectToken(p, tokenizer.INPUT)
 	p.nextToken()
 
 	if p.currentToken.Type != tokenizer.IDENT {
-		panic(fmt.Sprintf("expected input type name, got %s at line %d, column %d",
-			p.currentToken.Type, p.currentToken.Line, p.currentToken.Column))
+		p.errorf("expected input type name, got %s", describeToken(p.currentToken))
 	}
 
 	name := p.currentToken.Literal
@@ -954,11 +1443,11 @@ func parseInputTypeDefinition(p *parser) InputTypeDefinition {
 
 		for p.currentToken.Type != tokenizer.RBRACE {
 			if p.currentToken.Type == tokenizer.EOF {
-				panic("unexpected EOF in input type definition")
+				p.errorf("unexpected EOF in input type definition")
 			}
 
 			// Skip comments and documentation strings
-			p.skipCommentsAndDocs()
+			p.skipCommentsAndDescriptions()
 
 			// Check again after skipping ignored tokens
 			if p.currentToken.Type == tokenizer.RBRACE {
@@ -974,23 +1463,24 @@ func parseInputTypeDefinition(p *parser) InputTypeDefinition {
 	}
 
 	return InputTypeDefinition{
-		Name:       name,
-		Fields:     fields,
-		Directives: directives,
-		Metadata:   metadata,
+		Name:        name,
+		Fields:      fields,
+		Directives:  directives,
+		Description: description,
+		Metadata:    metadata,
 	}
 }
 
 // parseEnumTypeDefinition parses an enum type definition
 func parseEnumTypeDefinition(p *parser) EnumTypeDefinition {
+	description := p.extractDescription()
 	metadata := p.extractMetadata()
 
 	expectToken(p, tokenizer.ENUM)
 	p.nextToken()
 
 	if p.currentToken.Type != tokenizer.IDENT {
-		panic(fmt.Sprintf("expected enum name, got %s at line %d, column %d",
-			p.currentToken.Type, p.currentToken.Line, p.currentToken.Column))
+		p.errorf("expected enum name, got %s", describeToken(p.currentToken))
 	}
 
 	name := p.currentToken.Literal
@@ -1008,11 +1498,11 @@ func parseEnumTypeDefinition(p *parser) EnumTypeDefinition {
 
 		for p.currentToken.Type != tokenizer.RBRACE {
 			if p.currentToken.Type == tokenizer.EOF {
-				panic("unexpected EOF in enum definition")
+				p.errorf("unexpected EOF in enum definition")
 			}
 
 			// Skip comments and documentation strings
-			p.skipCommentsAndDocs()
+			p.skipCommentsAndDescriptions()
 
 			// Check again after skipping ignored tokens
 			if p.currentToken.Type == tokenizer.RBRACE {
@@ -1028,20 +1518,21 @@ func parseEnumTypeDefinition(p *parser) EnumTypeDefinition {
 	}
 
 	return EnumTypeDefinition{
-		Name:       name,
-		Values:     values,
-		Directives: directives,
-		Metadata:   metadata,
+		Name:        name,
+		Values:      values,
+		Directives:  directives,
+		Description: description,
+		Metadata:    metadata,
 	}
 }
 
 // parseEnumValueDefinition parses an enum value definition
 func parseEnumValueDefinition(p *parser) EnumValueDefinition {
+	description := p.extractDescription()
 	metadata := p.extractMetadata()
 
 	if p.currentToken.Type != tokenizer.IDENT {
-		panic(fmt.Sprintf("expected enum value name, got %s at line %d, column %d",
-			p.currentToken.Type, p.currentToken.Line, p.currentToken.Column))
+		p.errorf("expected enum value name, got %s", describeToken(p.currentToken))
 	}
 
 	name := p.currentToken.Literal
@@ -1053,22 +1544,23 @@ func parseEnumValueDefinition(p *parser) EnumValueDefinition {
 	}
 
 	return EnumValueDefinition{
-		Name:       name,
-		Directives: directives,
-		Metadata:   metadata,
+		Name:        name,
+		Directives:  directives,
+		Description: description,
+		Metadata:    metadata,
 	}
 }
 
 // parseScalarTypeDefinition parses a scalar type definition
 func parseScalarTypeDefinition(p *parser) ScalarTypeDefinition {
+	description := p.extractDescription()
 	metadata := p.extractMetadata()
 
 	expectToken(p, tokenizer.SCALAR)
 	p.nextToken()
 
 	if p.currentToken.Type != tokenizer.IDENT {
-		panic(fmt.Sprintf("expected scalar name, got %s at line %d, column %d",
-			p.currentToken.Type, p.currentToken.Line, p.currentToken.Column))
+		p.errorf("expected scalar name, got %s", describeToken(p.currentToken))
 	}
 
 	name := p.currentToken.Literal
@@ -1080,27 +1572,33 @@ func parseScalarTypeDefinition(p *parser) ScalarTypeDefinition {
 	}
 
 	return ScalarTypeDefinition{
-		Name:       name,
-		Directives: directives,
-		Metadata:   metadata,
+		Name:        name,
+		Directives:  directives,
+		Description: description,
+		Metadata:    metadata,
 	}
 }
 
 // parseInterfaceTypeDefinition parses an interface type definition
 func parseInterfaceTypeDefinition(p *parser) InterfaceTypeDefinition {
+	description := p.extractDescription()
 	metadata := p.extractMetadata()
 
 	expectToken(p, tokenizer.INTERFACE)
 	p.nextToken()
 
 	if p.currentToken.Type != tokenizer.IDENT {
-		panic(fmt.Sprintf("expected interface name, got %s at line %d, column %d",
-			p.currentToken.Type, p.currentToken.Line, p.currentToken.Column))
+		p.errorf("expected interface name, got %s", describeToken(p.currentToken))
 	}
 
 	name := p.currentToken.Literal
 	p.nextToken()
 
+	var interfaces []string
+	if p.currentToken.Type == tokenizer.IMPLEMENTS {
+		interfaces = parseImplementsInterfaces(p)
+	}
+
 	var directives []Directive
 	for p.currentToken.Type == tokenizer.AT {
 		directives = append(directives, parseDirective(p))
@@ -1112,23 +1610,25 @@ func parseInterfaceTypeDefinition(p *parser) InterfaceTypeDefinition {
 	}
 
 	return InterfaceTypeDefinition{
-		Name:       name,
-		Fields:     fields,
-		Directives: directives,
-		Metadata:   metadata,
+		Name:        name,
+		Interfaces:  interfaces,
+		Fields:      fields,
+		Directives:  directives,
+		Description: description,
+		Metadata:    metadata,
 	}
 }
 
 // parseUnionTypeDefinition parses a union type definition
 func parseUnionTypeDefinition(p *parser) UnionTypeDefinition {
+	description := p.extractDescription()
 	metadata := p.extractMetadata()
 
 	expectToken(p, tokenizer.UNION)
 	p.nextToken()
 
 	if p.currentToken.Type != tokenizer.IDENT {
-		panic(fmt.Sprintf("expected union name, got %s at line %d, column %d",
-			p.currentToken.Type, p.currentToken.Line, p.currentToken.Column))
+		p.errorf("expected union name, got %s", describeToken(p.currentToken))
 	}
 
 	name := p.currentToken.Literal
@@ -1145,8 +1645,7 @@ func parseUnionTypeDefinition(p *parser) UnionTypeDefinition {
 
 		for {
 			if p.currentToken.Type != tokenizer.IDENT {
-				panic(fmt.Sprintf("expected type name, got %s at line %d, column %d",
-					p.currentToken.Type, p.currentToken.Line, p.currentToken.Column))
+				p.errorf("expected type name, got %s", describeToken(p.currentToken))
 			}
 
 			types = append(types, p.currentToken.Literal)
@@ -1161,10 +1660,181 @@ func parseUnionTypeDefinition(p *parser) UnionTypeDefinition {
 	}
 
 	return UnionTypeDefinition{
-		Name:       name,
-		Types:      types,
-		Directives: directives,
-		Metadata:   metadata,
+		Name:        name,
+		Types:       types,
+		Directives:  directives,
+		Description: description,
+		Metadata:    metadata,
+	}
+}
+
+// parseSchemaDefinition parses a schema definition
+func parseSchemaDefinition(p *parser) SchemaDefinition {
+	metadata := p.extractMetadata()
+
+	expectToken(p, tokenizer.SCHEMA)
+	p.nextToken()
+
+	var directives []Directive
+	for p.currentToken.Type == tokenizer.AT {
+		directives = append(directives, parseDirective(p))
+	}
+
+	var operationTypes []OperationTypeDefinition
+	if p.currentToken.Type == tokenizer.LBRACE {
+		p.nextToken()
+
+		for p.currentToken.Type != tokenizer.RBRACE {
+			if p.currentToken.Type == tokenizer.EOF {
+				p.errorf("unexpected EOF in schema definition")
+			}
+
+			// Skip comments and documentation strings
+			p.skipCommentsAndDocs()
+
+			// Check again after skipping ignored tokens
+			if p.currentToken.Type == tokenizer.RBRACE {
+				break
+			}
+
+			var operation OperationType
+			switch p.currentToken.Type {
+			case tokenizer.QUERY:
+				operation = Query
+			case tokenizer.MUTATION:
+				operation = Mutation
+			case tokenizer.SUBSCRIPTION:
+				operation = Subscription
+			default:
+				p.errorf("expected operation type, got %s", describeToken(p.currentToken))
+			}
+			p.nextToken()
+
+			expectToken(p, tokenizer.COLON)
+			p.nextToken()
+
+			if p.currentToken.Type != tokenizer.IDENT {
+				p.errorf("expected type name, got %s", describeToken(p.currentToken))
+			}
+			operationTypes = append(operationTypes, OperationTypeDefinition{Operation: operation, Type: p.currentToken.Literal})
+			p.nextToken()
+
+			if p.currentToken.Type == tokenizer.COMMA {
+				p.nextToken()
+			}
+		}
+
+		expectToken(p, tokenizer.RBRACE)
+		p.nextToken()
+	}
+
+	return SchemaDefinition{
+		OperationTypes: operationTypes,
+		Directives:     directives,
+		Metadata:       metadata,
+	}
+}
+
+// parseTypeExtension parses an extension of a schema or type definition
+func parseTypeExtension(p *parser) AST {
+	expectToken(p, tokenizer.EXTEND)
+	p.nextToken()
+
+	switch p.currentToken.Type {
+	case tokenizer.SCHEMA:
+		def := parseSchemaDefinition(p)
+		def.Extend = true
+		return def
+	case tokenizer.TYPE:
+		def := parseTypeDefinition(p)
+		def.Extend = true
+		return def
+	case tokenizer.INPUT:
+		def := parseInputTypeDefinition(p)
+		def.Extend = true
+		return def
+	case tokenizer.ENUM:
+		def := parseEnumTypeDefinition(p)
+		def.Extend = true
+		return def
+	case tokenizer.SCALAR:
+		def := parseScalarTypeDefinition(p)
+		def.Extend = true
+		return def
+	case tokenizer.INTERFACE:
+		def := parseInterfaceTypeDefinition(p)
+		def.Extend = true
+		return def
+	case tokenizer.UNION:
+		def := parseUnionTypeDefinition(p)
+		def.Extend = true
+		return def
+	default:
+		p.errorf("unexpected token after extend: %s", describeToken(p.currentToken))
+	}
+	return nil
+}
+
+// parseDirectiveDefinition parses a directive definition
+func parseDirectiveDefinition(p *parser) DirectiveDefinition {
+	description := p.extractDescription()
+	metadata := p.extractMetadata()
+
+	expectToken(p, tokenizer.DIRECTIVE)
+	p.nextToken()
+
+	expectToken(p, tokenizer.AT)
+	p.nextToken()
+
+	if !isNameToken(p.currentToken.Type) {
+		p.errorf("expected directive name, got %s", describeToken(p.currentToken))
+	}
+
+	name := p.currentToken.Literal
+	p.nextToken()
+
+	var arguments []InputValueDefinition
+	if p.currentToken.Type == tokenizer.LPAREN {
+		arguments = parseInputValueDefinitions(p)
+	}
+
+	repeatable := false
+	if p.currentToken.Type == tokenizer.IDENT && p.currentToken.Literal == "repeatable" {
+		repeatable = true
+		p.nextToken()
+	}
+
+	expectToken(p, tokenizer.ON)
+	p.nextToken()
+
+	// The first location may be preceded by a pipe
+	if p.currentToken.Type == tokenizer.PIPE {
+		p.nextToken()
+	}
+
+	var locations []string
+	for {
+		if p.currentToken.Type != tokenizer.IDENT {
+			p.errorf("expected directive location, got %s", describeToken(p.currentToken))
+		}
+
+		locations = append(locations, p.currentToken.Literal)
+		p.nextToken()
+
+		if p.currentToken.Type == tokenizer.PIPE {
+			p.nextToken() // consume |
+		} else {
+			break
+		}
+	}
+
+	return DirectiveDefinition{
+		Name:        name,
+		Arguments:   arguments,
+		Repeatable:  repeatable,
+		Locations:   locations,
+		Description: description,
+		Metadata:    metadata,
 	}
 }
 
@@ -1177,7 +1847,7 @@ func parseSelectionSet(p *parser) SelectionSet {
 
 	for p.currentToken.Type != tokenizer.RBRACE {
 		if p.currentToken.Type == tokenizer.EOF {
-			panic("unexpected EOF in selection set")
+			p.errorf("unexpected EOF in selection set")
 		}
 
 		// Skip comments and documentation strings
@@ -1205,18 +1875,18 @@ func parseSelection(p *parser) Selection {
 	} else if p.currentToken.Type == tokenizer.SPREAD {
 		return parseFragmentSpread(p)
 	} else {
-		panic(fmt.Sprintf("unexpected token in selection: %s at line %d, column %d",
-			p.currentToken.Type, p.currentToken.Line, p.currentToken.Column))
+		p.errorf("unexpected token in selection: %s", describeToken(p.currentToken))
 	}
+	return nil
 }
 
 // parseField parses a field selection
 func parseField(p *parser) Field {
 	metadata := p.extractMetadata()
+	loc := p.location()
 
 	if !isNameToken(p.currentToken.Type) {
-		panic(fmt.Sprintf("expected field name, got %s at line %d, column %d",
-			p.currentToken.Type, p.currentToken.Line, p.currentToken.Column))
+		p.errorf("expected field name, got %s", describeToken(p.currentToken))
 	}
 
 	name := p.currentToken.Literal
@@ -1230,8 +1900,7 @@ func parseField(p *parser) Field {
 		p.nextToken()
 
 		if !isNameToken(p.currentToken.Type) {
-			panic(fmt.Sprintf("expected field name after alias, got %s at line %d, column %d",
-				p.currentToken.Type, p.currentToken.Line, p.currentToken.Column))
+			p.errorf("expected field name after alias, got %s", describeToken(p.currentToken))
 		}
 
 		name = p.currentToken.Literal
@@ -1261,25 +1930,28 @@ func parseField(p *parser) Field {
 		Directives:   directives,
 		SelectionSet: selectionSet,
 		Metadata:     metadata,
+		Loc:          loc,
 	}
 }
 
 // parseFragmentSpread parses a fragment spread
 func parseFragmentSpread(p *parser) Selection {
 	metadata := p.extractMetadata()
+	loc := p.location()
 
 	expectToken(p, tokenizer.SPREAD)
 	p.nextToken()
 
-	if p.currentToken.Type == tokenizer.ON {
-		// Inline fragment
-		p.nextToken()
-
+	if p.currentToken.Type == tokenizer.ON || p.currentToken.Type == tokenizer.AT || p.currentToken.Type == tokenizer.LBRACE {
+		// Inline fragment, the type condition is optional
 		var typeName *string
-		if p.currentToken.Type == tokenizer.IDENT {
-			tn := p.currentToken.Literal
-			typeName = &tn
+		if p.currentToken.Type == tokenizer.ON {
 			p.nextToken()
+			if p.currentToken.Type == tokenizer.IDENT {
+				tn := p.currentToken.Literal
+				typeName = &tn
+				p.nextToken()
+			}
 		}
 
 		var directives []Directive
@@ -1294,12 +1966,12 @@ func parseFragmentSpread(p *parser) Selection {
 			Directives:   directives,
 			SelectionSet: selectionSet,
 			Metadata:     metadata,
+			Loc:          loc,
 		}
 	}
 
 	if p.currentToken.Type != tokenizer.IDENT {
-		panic(fmt.Sprintf("expected fragment name, got %s at line %d, column %d",
-			p.currentToken.Type, p.currentToken.Line, p.currentToken.Column))
+		p.errorf("expected fragment name, got %s", describeToken(p.currentToken))
 	}
 
 	name := p.currentToken.Literal
@@ -1314,6 +1986,7 @@ func parseFragmentSpread(p *parser) Selection {
 		Name:       name,
 		Directives: directives,
 		Metadata:   metadata,
+		Loc:        loc,
 	}
 }
 
@@ -1326,7 +1999,7 @@ func parseArguments(p *parser) []Argument {
 
 	for p.currentToken.Type != tokenizer.RPAREN {
 		if p.currentToken.Type == tokenizer.EOF {
-			panic("unexpected EOF in arguments")
+			p.errorf("unexpected EOF in arguments")
 		}
 
 		// Skip comments and documentation strings
@@ -1353,9 +2026,9 @@ func parseArguments(p *parser) []Argument {
 
 // parseArgument parses a single argument
 func parseArgument(p *parser) Argument {
+	loc := p.location()
 	if !isNameToken(p.currentToken.Type) {
-		panic(fmt.Sprintf("expected argument name, got %s at line %d, column %d",
-			p.currentToken.Type, p.currentToken.Line, p.currentToken.Column))
+		p.errorf("expected argument name, got %s", describeToken(p.currentToken))
 	}
 
 	name := p.currentToken.Literal
@@ -1366,7 +2039,7 @@ func parseArgument(p *parser) Argument {
 
 	value := parseValue(p)
 
-	return Argument{Name: name, Value: value}
+	return Argument{Name: name, Value: value, Loc: loc}
 }
 
 // parseValue parses a GraphQL value
@@ -1399,22 +2072,21 @@ func parseValue(p *parser) Value {
 		case "null":
 			return NullValue{}
 		default:
-			panic(fmt.Sprintf("unexpected identifier in value: %s at line %d, column %d",
-				literal, p.currentToken.Line, p.currentToken.Column))
+			return EnumValue{Value: literal}
 		}
 	case tokenizer.DOLLAR:
+		loc := p.location()
 		p.nextToken()
 		if !isNameToken(p.currentToken.Type) {
-			panic(fmt.Sprintf("expected variable name, got %s at line %d, column %d",
-				p.currentToken.Type, p.currentToken.Line, p.currentToken.Column))
+			p.errorf("expected variable name, got %s", describeToken(p.currentToken))
 		}
 		name := p.currentToken.Literal
 		p.nextToken()
-		return Variable{Name: name}
+		return Variable{Name: name, Loc: loc}
 	default:
-		panic(fmt.Sprintf("unexpected token in value: %s at line %d, column %d",
-			p.currentToken.Type, p.currentToken.Line, p.currentToken.Column))
+		p.errorf("unexpected token in value: %s", describeToken(p.currentToken))
 	}
+	return nil
 }
 
 func parseListValue(p *parser) Value {
@@ -1424,8 +2096,7 @@ func parseListValue(p *parser) Value {
 	var values []Value
 	for p.currentToken.Type != tokenizer.RBRACKET {
 		if p.currentToken.Type == tokenizer.EOF {
-			panic(fmt.Sprintf("unexpected EOF in list value at line %d, column %d",
-				p.currentToken.Line, p.currentToken.Column))
+			p.errorf("unexpected EOF in list value")
 		}
 
 		p.skipCommentsAndDocs()
@@ -1455,8 +2126,7 @@ func parseObjectValue(p *parser) Value {
 	var fields []ObjectField
 	for p.currentToken.Type != tokenizer.RBRACE {
 		if p.currentToken.Type == tokenizer.EOF {
-			panic(fmt.Sprintf("unexpected EOF in object value at line %d, column %d",
-				p.currentToken.Line, p.currentToken.Column))
+			p.errorf("unexpected EOF in object value")
 		}
 
 		p.skipCommentsAndDocs()
@@ -1466,8 +2136,7 @@ func parseObjectValue(p *parser) Value {
 		}
 
 		if !isNameToken(p.currentToken.Type) {
-			panic(fmt.Sprintf("expected object field name, got %s at line %d, column %d",
-				p.currentToken.Type, p.currentToken.Line, p.currentToken.Column))
+			p.errorf("expected object field name, got %s", describeToken(p.currentToken))
 		}
 
 		name := p.currentToken.Literal
@@ -1499,7 +2168,7 @@ func parseVariableDefinitions(p *parser) []VariableDefinition {
 
 	for p.currentToken.Type != tokenizer.RPAREN {
 		if p.currentToken.Type == tokenizer.EOF {
-			panic("unexpected EOF in variable definitions")
+			p.errorf("unexpected EOF in variable definitions")
 		}
 
 		// Skip comments and documentation strings
@@ -1527,13 +2196,13 @@ func parseVariableDefinitions(p *parser) []VariableDefinition {
 // parseVariableDefinition parses a single variable definition
 func parseVariableDefinition(p *parser) VariableDefinition {
 	metadata := p.extractMetadata()
+	loc := p.location()
 
 	expectToken(p, tokenizer.DOLLAR)
 	p.nextToken()
 
 	if !isNameToken(p.currentToken.Type) {
-		panic(fmt.Sprintf("expected variable name, got %s at line %d, column %d",
-			p.currentToken.Type, p.currentToken.Line, p.currentToken.Column))
+		p.errorf("expected variable name, got %s", describeToken(p.currentToken))
 	}
 
 	name := p.currentToken.Literal
@@ -1556,6 +2225,7 @@ func parseVariableDefinition(p *parser) VariableDefinition {
 		Type:         varType,
 		DefaultValue: defaultValue,
 		Metadata:     metadata,
+		Loc:          loc,
 	}
 }
 
@@ -1574,8 +2244,7 @@ func parseType(p *parser) Type {
 		p.nextToken()
 		t = NamedType{Name: name}
 	} else {
-		panic(fmt.Sprintf("expected type, got %s at line %d, column %d",
-			p.currentToken.Type, p.currentToken.Line, p.currentToken.Column))
+		p.errorf("expected type, got %s", describeToken(p.currentToken))
 	}
 
 	if p.currentToken.Type == tokenizer.BANG {
@@ -1588,12 +2257,12 @@ func parseType(p *parser) Type {
 
 // parseDirective parses a directive
 func parseDirective(p *parser) Directive {
+	loc := p.location()
 	expectToken(p, tokenizer.AT)
 	p.nextToken()
 
 	if !isNameToken(p.currentToken.Type) {
-		panic(fmt.Sprintf("expected directive name, got %s at line %d, column %d",
-			p.currentToken.Type, p.currentToken.Line, p.currentToken.Column))
+		p.errorf("expected directive name, got %s", describeToken(p.currentToken))
 	}
 
 	name := p.currentToken.Literal
@@ -1604,13 +2273,12 @@ func parseDirective(p *parser) Directive {
 		arguments = parseArguments(p)
 	}
 
-	return Directive{Name: name, Arguments: arguments}
+	return Directive{Name: name, Arguments: arguments, Loc: loc}
 }
 
 // expectToken checks if the current token matches the expected type
 func expectToken(p *parser, expected tokenizer.TokenType) {
 	if p.currentToken.Type != expected {
-		panic(fmt.Sprintf("expected %s, got %s at line %d, column %d",
-			expected, p.currentToken.Type, p.currentToken.Line, p.currentToken.Column))
+		p.errorf("expected %s, got %s", expected, describeToken(p.currentToken))
 	}
 }
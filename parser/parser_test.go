@@ -119,6 +119,57 @@ query {
 				},
 			},
 		},
+		{
+			name: "inline fragments",
+			input: `query {
+  search {
+    ... on User {
+      name
+    }
+    ... @include(if: true) {
+      id
+    }
+  }
+}`,
+			expected: []parser.AST{
+				parser.OperationDefinition{
+					Type: parser.Query,
+					SelectionSet: parser.SelectionSet{
+						Selections: []parser.Selection{
+							parser.Field{
+								Name: "search",
+								SelectionSet: &parser.SelectionSet{
+									Selections: []parser.Selection{
+										parser.InlineFragment{
+											TypeName: func() *string {
+												value := "User"
+												return &value
+											}(),
+											SelectionSet: parser.SelectionSet{
+												Selections: []parser.Selection{parser.Field{Name: "name"}},
+											},
+										},
+										parser.InlineFragment{
+											Directives: []parser.Directive{
+												{
+													Name: "include",
+													Arguments: []parser.Argument{
+														{Name: "if", Value: parser.BooleanValue{Value: true}},
+													},
+												},
+											},
+											SelectionSet: parser.SelectionSet{
+												Selections: []parser.Selection{parser.Field{Name: "id"}},
+											},
+										},
+									},
+								},
+							},
+						},
+					},
+				},
+			},
+		},
 		{
 			name: "type definition",
 			input: `type Query {
@@ -181,33 +232,38 @@ enum UserTitleLanguage {
 }`,
 			expected: []parser.AST{
 				parser.TypeDefinition{
-					Name:     "Query",
-					Metadata: []string{"Query"},
+					Name:        "Query",
+					Description: strPtr("Query"),
+					Metadata:    []string{"Query"},
 					Fields: []parser.FieldDefinition{
 						{
 							Name: "Page",
 							Arguments: []parser.InputValueDefinition{
 								{
-									Name:     "page",
-									Type:     parser.NamedType{Name: "Int"},
-									Metadata: []string{"The page number"},
+									Name:        "page",
+									Type:        parser.NamedType{Name: "Int"},
+									Description: strPtr("The page number"),
+									Metadata:    []string{"The page number"},
 								},
 								{
-									Name:     "perPage",
-									Type:     parser.NamedType{Name: "Int"},
-									Metadata: []string{"The amount of entries per page, max 50"},
+									Name:        "perPage",
+									Type:        parser.NamedType{Name: "Int"},
+									Description: strPtr("The amount of entries per page, max 50"),
+									Metadata:    []string{"The amount of entries per page, max 50"},
 								},
 							},
 							Type: parser.NamedType{Name: "Page"},
 						},
 						{
-							Name:     "Media",
-							Metadata: []string{"Media query"},
+							Name:        "Media",
+							Description: strPtr("Media query"),
+							Metadata:    []string{"Media query"},
 							Arguments: []parser.InputValueDefinition{
 								{
-									Name:     "id",
-									Type:     parser.NamedType{Name: "Int"},
-									Metadata: []string{"Filter by the media id"},
+									Name:        "id",
+									Type:        parser.NamedType{Name: "Int"},
+									Description: strPtr("Filter by the media id"),
+									Metadata:    []string{"Filter by the media id"},
 								},
 							},
 							Type: parser.NamedType{Name: "Media"},
@@ -215,14 +271,16 @@ enum UserTitleLanguage {
 					},
 				},
 				parser.EnumTypeDefinition{
-					Name:       "UserTitleLanguage",
-					Directives: []parser.Directive{},
-					Metadata:   []string{"The language the user wants to see media titles in"},
+					Name:        "UserTitleLanguage",
+					Directives:  []parser.Directive{},
+					Description: strPtr("The language the user wants to see media titles in"),
+					Metadata:    []string{"The language the user wants to see media titles in"},
 					Values: []parser.EnumValueDefinition{
 						{
-							Name:       "ROMAJI",
-							Directives: []parser.Directive{},
-							Metadata:   []string{"The romanization of the native language title"},
+							Name:        "ROMAJI",
+							Directives:  []parser.Directive{},
+							Description: strPtr("The romanization of the native language title"),
+							Metadata:    []string{"The romanization of the native language title"},
 						},
 					},
 				},
@@ -242,13 +300,15 @@ enum UserTitleLanguage {
 					Name: "Query",
 					Fields: []parser.FieldDefinition{
 						{
-							Name:     "Media",
-							Metadata: []string{"Media query"},
+							Name:        "Media",
+							Description: strPtr("Media query"),
+							Metadata:    []string{"Media query"},
 							Arguments: []parser.InputValueDefinition{
 								{
-									Name:     "type",
-									Type:     parser.NamedType{Name: "MediaType"},
-									Metadata: []string{"Filter by the media's type"},
+									Name:        "type",
+									Type:        parser.NamedType{Name: "MediaType"},
+									Description: strPtr("Filter by the media's type"),
+									Metadata:    []string{"Filter by the media's type"},
 								},
 							},
 							Type: parser.NamedType{Name: "Media"},
@@ -488,6 +548,47 @@ enum UserTitleLanguage {
 				},
 			},
 		},
+		{
+			name: "schema, extension and directive definitions",
+			input: `schema { query: RootQuery mutation: RootMutation }
+extend schema { subscription: RootSubscription }
+extend type RootQuery implements Node { me: User }
+extend enum Role { ADMIN }
+directive @auth(requires: Role = ADMIN) repeatable on | FIELD_DEFINITION | OBJECT`,
+			expected: []parser.AST{
+				parser.SchemaDefinition{
+					OperationTypes: []parser.OperationTypeDefinition{
+						{Operation: parser.Query, Type: "RootQuery"},
+						{Operation: parser.Mutation, Type: "RootMutation"},
+					},
+				},
+				parser.SchemaDefinition{
+					OperationTypes: []parser.OperationTypeDefinition{{Operation: parser.Subscription, Type: "RootSubscription"}},
+					Extend:         true,
+				},
+				parser.TypeDefinition{
+					Name:       "RootQuery",
+					Interfaces: []string{"Node"},
+					Fields:     []parser.FieldDefinition{{Name: "me", Type: parser.NamedType{Name: "User"}}},
+					Extend:     true,
+				},
+				parser.EnumTypeDefinition{
+					Name:   "Role",
+					Values: []parser.EnumValueDefinition{{Name: "ADMIN"}},
+					Extend: true,
+				},
+				parser.DirectiveDefinition{
+					Name: "auth",
+					Arguments: []parser.InputValueDefinition{{
+						Name:         "requires",
+						Type:         parser.NamedType{Name: "Role"},
+						DefaultValue: valuePtr(parser.EnumValue{Value: "ADMIN"}),
+					}},
+					Repeatable: true,
+					Locations:  []string{"FIELD_DEFINITION", "OBJECT"},
+				},
+			},
+		},
 	}
 
 	for _, test := range tests {
@@ -518,3 +619,203 @@ enum UserTitleLanguage {
 		})
 	}
 }
+
+func TestDocumentAppendsUsedFragments(t *testing.T) {
+	var nodes []parser.AST
+	for ast := range parser.Parse(strings.NewReader(`query GetUser {
+  user {
+    ...UserFields
+  }
+}
+
+fragment Unused on User {
+  id
+}
+
+fragment UserFields on User {
+  name
+  ...Ids
+  friends {
+    ...Ids
+  }
+}
+
+fragment Ids on User {
+  id
+}`)) {
+		nodes = append(nodes, ast)
+	}
+
+	doc := parser.NewDocument(nodes)
+	if len(doc.Operations) != 1 || len(doc.Fragments) != 3 {
+		t.Fatalf("expected 1 operation and 3 fragments, got %d and %d", len(doc.Operations), len(doc.Fragments))
+	}
+
+	expected := `query GetUser {
+  user {
+    ...UserFields
+  }
+}
+
+fragment UserFields on User {
+  name
+  ...Ids
+  friends {
+    ...Ids
+  }
+}
+
+fragment Ids on User {
+  id
+}`
+	if got := doc.FormattedOperationString(doc.Operations[0]); got != expected {
+		t.Errorf("expected document\n%s\ngot\n%s", expected, got)
+	}
+}
+
+func TestDocumentGeneratesClassMethods(t *testing.T) {
+	var nodes []parser.AST
+	for ast := range parser.Parse(strings.NewReader(`query GetUser($id: ID!) { user(id: $id) { id } }
+query Me { me { id } }
+subscription OnUser($id: ID!) { user(id: $id) { id } }`)) {
+		nodes = append(nodes, ast)
+	}
+	doc := parser.NewDocument(nodes)
+
+	var buf strings.Builder
+	if _, err := doc.GenerateTypeScriptClassMethods(&buf, parser.TypeScriptClassOptions{ClassName: "ShopGraphQL"}); err != nil {
+		t.Fatalf("GenerateTypeScriptClassMethods returned error: %v", err)
+	}
+	for _, expected := range []string{
+		`  public async GetUser<O extends GraphQLCallOptions | undefined = undefined>(
+    variables: schema.GetUser_Variables,
+    options?: O,
+  ): Promise<GraphQLReturn<schema.GetUser_Type, O>> {
+    return this.execute(ShopGraphQL.GetUser_query, schema.GetUser_Schema, variables, schema.GetUser_Variables_Schema, options);`,
+		`  public async Me<O extends GraphQLCallOptions | undefined = undefined>(
+    options?: O,
+  ): Promise<GraphQLReturn<schema.Me_Type, O>> {
+    return this.execute(ShopGraphQL.Me_query, schema.Me_Schema, undefined, undefined, options);`,
+		`  public OnUser<O extends GraphQLSubscriptionOptions | undefined = undefined>(
+    variables: schema.OnUser_Variables,
+    options?: O,
+  ): GraphQLSubscription<GraphQLReturn<schema.OnUser_Type, O>> {
+    return this.subscribe(ShopGraphQL.OnUser_query, schema.OnUser_Schema, variables, schema.OnUser_Variables_Schema, options);`,
+	} {
+		if !strings.Contains(buf.String(), expected) {
+			t.Errorf("expected %q in output:\n%s", expected, buf.String())
+		}
+	}
+
+	buf.Reset()
+	if _, err := doc.GenerateTypeScriptClassMethods(&buf, parser.TypeScriptClassOptions{URLArgument: true}); err != nil {
+		t.Fatalf("GenerateTypeScriptClassMethods returned error: %v", err)
+	}
+	expected := `  public async GetUser<O extends GraphQLCallOptions | undefined = undefined>(
+    url: string,
+    variables: schema.GetUser_Variables,
+    options?: O,
+  ): Promise<GraphQLReturn<schema.GetUser_Type, O>> {
+    return this.execute(GraphQL.GetUser_query, schema.GetUser_Schema, variables, schema.GetUser_Variables_Schema, { ...options, url });`
+	if !strings.Contains(buf.String(), expected) {
+		t.Errorf("expected %q in output:\n%s", expected, buf.String())
+	}
+}
+
+func TestDocumentPersistedQueries(t *testing.T) {
+	var nodes []parser.AST
+	for ast := range parser.Parse(strings.NewReader(`query GetUser { user { ...Ids } }
+fragment Ids on User { id }`)) {
+		nodes = append(nodes, ast)
+	}
+	doc := parser.NewDocument(nodes)
+
+	document := "query GetUser {\n  user {\n    ...Ids\n  }\n}\n\nfragment Ids on User {\n  id\n}"
+	id := "b6b1bf8c32c65e3ad72fdde542313aa81aff6ac6dfc65cea9076e31fce5354fd"
+	queries := doc.PersistedQueries()
+	if len(queries) != 1 {
+		t.Fatalf("expected 1 persisted query, got %d", len(queries))
+	}
+	for hash, query := range queries {
+		if query != document {
+			t.Errorf("expected document %q, got %q", document, query)
+		}
+		if hash != id {
+			t.Errorf("expected id %s, got %s", id, hash)
+		}
+	}
+}
+
+func TestParseErrors(t *testing.T) {
+	var nodes []parser.AST
+	for ast := range parser.ParseFile("broken.graphql", strings.NewReader(`query A {
+  user(id: ) {
+    id
+  }
+}
+
+query B {
+  user {
+    name
+  }
+}
+
+fragment C User {
+  id
+}
+
+query D {
+  user {
+    id
+`)) {
+		nodes = append(nodes, ast)
+	}
+
+	var errs parser.Errors
+	var operations []string
+	for _, node := range nodes {
+		switch n := node.(type) {
+		case parser.Error:
+			errs = append(errs, n)
+		case parser.OperationDefinition:
+			operations = append(operations, *n.Name)
+		}
+	}
+
+	if len(operations) != 1 || operations[0] != "B" {
+		t.Errorf("expected only operation B to be parsed, got %v", operations)
+	}
+
+	expected := `broken.graphql:2:12: unexpected token in value: RPAREN
+broken.graphql:13:12: expected ON, got IDENT "User"
+broken.graphql:20:1: unexpected EOF in selection set`
+	if errs.Error() != expected {
+		t.Errorf("expected errors\n%s\ngot\n%s", expected, errs.Error())
+	}
+}
+
+func TestGoName(t *testing.T) {
+	for name, expected := range map[string]string{
+		"user":        "User",
+		"userId":      "UserID",
+		"avatarUrl":   "AvatarURL",
+		"NEW_HOPE":    "NewHope",
+		"__typename":  "Typename",
+		"GetUser":     "GetUser",
+		"3d":          "X3d",
+		"snake_case":  "SnakeCase",
+		"MediaListID": "MediaListID",
+	} {
+		if got := parser.GoName(name); got != expected {
+			t.Errorf("GoName(%q) = %q, expected %q", name, got, expected)
+		}
+	}
+}
+
+func strPtr(s string) *string {
+	return &s
+}
+
+func valuePtr(v parser.Value) *parser.Value {
+	return &v
+}
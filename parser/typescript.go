@@ -2,6 +2,7 @@ package parser
 
 import (
 	"bytes"
+	"encoding/json"
 	"fmt"
 	"io"
 	"strings"
@@ -50,14 +51,15 @@ func (nnt NonNullType) ToTypeScript() (string, string) {
 
 // OperationDefinition TypeScript generation methods
 func (od OperationDefinition) GenerateTypeScript(w io.Writer) (map[string]bool, error) {
+	return od.generateTypeScript(w, od.generateFormattedGraphQLString())
+}
+
+func (od OperationDefinition) generateTypeScript(w io.Writer, queryStr string) (map[string]bool, error) {
 	usedTypes := make(map[string]bool)
 
 	// Generate function name
 	funcName := od.generateFunctionName()
 
-	// Generate GraphQL query string with proper formatting
-	queryStr := od.generateFormattedGraphQLString()
-
 	// Generate variable types
 	varType, varUsedTypes := od.generateVariableInterface()
 	for t := range varUsedTypes {
@@ -79,7 +81,7 @@ func (od OperationDefinition) GenerateTypeScript(w io.Writer) (map[string]bool,
 
 	// Generate the query constant first
 	queryConstName := funcName + "_query"
-	if _, err := fmt.Fprintf(w, "const %s = `%s`;\n", queryConstName, queryStr); err != nil {
+	if _, err := fmt.Fprintf(w, "const %s = %s;\n", queryConstName, tsString(queryStr)); err != nil {
 		return usedTypes, err
 	}
 
@@ -90,7 +92,7 @@ func (od OperationDefinition) GenerateTypeScript(w io.Writer) (map[string]bool,
   url: string,
   variables: %s,
 ): Promise<schema.%s> {
-  return executeGraphQLOperation(url, %s, schema.%s, variables);
+  return executeGraphQLOperation(url, %s, schema.%s, variables, schema.%s_Variables_Schema);
 }
 `,
 			funcName,
@@ -98,6 +100,7 @@ func (od OperationDefinition) GenerateTypeScript(w io.Writer) (map[string]bool,
 			operationTypeName,
 			queryConstName,
 			operationSchemaName,
+			funcName,
 		)
 	} else {
 		funcCode = fmt.Sprintf(`export async function %s(
@@ -118,14 +121,29 @@ func (od OperationDefinition) GenerateTypeScript(w io.Writer) (map[string]bool,
 }
 
 func (od OperationDefinition) GenerateTypeScriptMethod(w io.Writer) (map[string]bool, error) {
+	return od.generateTypeScriptMethod(w, od.generateFormattedGraphQLString(), TypeScriptClassOptions{})
+}
+
+// TypeScriptClassOptions configures the methods generated for the client class
+type TypeScriptClassOptions struct {
+	ClassName string // Defaults to GraphQL
+	// URLArgument generates methods taking the url as first argument, like before the client had a url of its own
+	URLArgument bool
+	// PersistedQueries passes the DocumentID of the operation to the runtime
+	PersistedQueries bool
+}
+
+func (od OperationDefinition) generateTypeScriptMethod(w io.Writer, queryStr string, options TypeScriptClassOptions) (map[string]bool, error) {
+	className := options.ClassName
+	if className == "" {
+		className = "GraphQL"
+	}
+
 	usedTypes := make(map[string]bool)
 
 	// Generate function name
 	funcName := od.generateFunctionName()
 
-	// Generate GraphQL query string with proper formatting
-	queryStr := od.generateFormattedGraphQLString()
-
 	// Generate variable types
 	varType, varUsedTypes := od.generateVariableInterface()
 	for t := range varUsedTypes {
@@ -145,48 +163,86 @@ func (od OperationDefinition) GenerateTypeScriptMethod(w io.Writer) (map[string]
 	// Mark this operation for schema generation
 	usedTypes["__operation:"+funcName] = true
 
-	// Generate the query constant as a private static member
+	// Generate the query constant as a private static member, a string literal keeps the sent document equal to the hashed one
 	queryConstName := funcName + "_query"
-	if _, err := fmt.Fprintf(w, "\n  private static readonly %s = `%s`;\n", queryConstName, queryStr); err != nil {
+	if _, err := fmt.Fprintf(w, "\n  private static readonly %s = %s;\n", queryConstName, tsString(queryStr)); err != nil {
 		return usedTypes, err
 	}
 
-	// Generate the method
-	var methodCode string
+	// Generate the method, the parameters are the url (only for URLArgument), the variables (if any) and the call options
+	var params []string
+	callOptions := "options"
+	if options.URLArgument {
+		params = append(params, "url: string")
+		callOptions = "{ ...options, url }"
+	}
+	variables, variablesSchema := "undefined", "undefined"
 	if len(od.Variables) > 0 {
-		methodCode = fmt.Sprintf(`
-  public async %s(
-    url: string,
-    variables: %s,
-  ): Promise<schema.%s> {
-    return this.execute(url, GraphQL.%s, schema.%s, variables);
-  }
-`,
-			funcName,
-			varType,
-			operationTypeName,
-			queryConstName,
-			operationSchemaName,
-		)
-	} else {
-		methodCode = fmt.Sprintf(`
-  public async %s(
-    url: string,
-  ): Promise<schema.%s> {
-    return this.execute(url, GraphQL.%s, schema.%s);
+		params = append(params, "variables: "+varType)
+		variables, variablesSchema = "variables", fmt.Sprintf("schema.%s_Variables_Schema", funcName)
+	}
+	// Subscriptions return the events instead of a single result
+	signature, call := "async %s<O extends GraphQLCallOptions | undefined = undefined>", "return this.execute"
+	optionsParam, returnType := "options?: O", "Promise<GraphQLReturn<schema.%s, O>>"
+	if od.Type == Subscription {
+		signature, call = "%s<O extends GraphQLSubscriptionOptions | undefined = undefined>", "return this.subscribe"
+		returnType = "GraphQLSubscription<GraphQLReturn<schema.%s, O>>"
+	}
+	params = append(params, optionsParam)
+	// The document id follows the call options
+	if options.PersistedQueries {
+		callOptions += fmt.Sprintf(", %q", DocumentID(queryStr))
+	}
+	methodCode := fmt.Sprintf(`
+%s  public `+signature+`(
+    %s,
+  ): `+returnType+` {
+    `+call+`(%s.%s, schema.%s, %s, %s, %s);
   }
 `,
-			funcName,
-			operationTypeName,
-			queryConstName,
-			operationSchemaName,
-		)
-	}
+		JSDoc(od.Description, "  "),
+		funcName,
+		strings.Join(params, ",\n    "),
+		operationTypeName,
+		className,
+		queryConstName,
+		operationSchemaName,
+		variables,
+		variablesSchema,
+		callOptions,
+	)
 
 	_, err := fmt.Fprint(w, methodCode)
 	return usedTypes, err
 }
 
+// JSDoc returns the description as a JSDoc comment with the given indentation,
+// or an empty string if there is no description
+func JSDoc(description *string, indent string) string {
+	if description == nil || strings.TrimSpace(*description) == "" {
+		return ""
+	}
+	// A description must not end the comment early
+	text := strings.ReplaceAll(strings.TrimSpace(*description), "*/", "*\\/")
+	lines := strings.Split(text, "\n")
+	if len(lines) == 1 {
+		return indent + "/** " + text + " */\n"
+	}
+
+	var buf strings.Builder
+	buf.WriteString(indent + "/**\n")
+	for _, line := range lines {
+		line = strings.TrimRight(line, " \t")
+		if line == "" {
+			buf.WriteString(indent + " *\n")
+		} else {
+			buf.WriteString(indent + " * " + line + "\n")
+		}
+	}
+	buf.WriteString(indent + " */\n")
+	return buf.String()
+}
+
 func (od OperationDefinition) generateFunctionName() string {
 	if od.Name != nil {
 		return *od.Name
@@ -212,9 +268,14 @@ func (od OperationDefinition) generateGraphQLString() string {
 			buf.WriteString(v.Name)
 			buf.WriteString(": ")
 			buf.WriteString(v.Type.String())
+			if v.DefaultValue != nil {
+				buf.WriteString(" = ")
+				buf.WriteString((*v.DefaultValue).String())
+			}
 		}
 		buf.WriteString(")")
 	}
+	buf.WriteString(directivesString(od.Directives))
 	buf.WriteString(" ")
 	buf.WriteString(od.SelectionSet.String())
 	return buf.String()
@@ -237,9 +298,14 @@ func (od OperationDefinition) generateFormattedGraphQLString() string {
 			buf.WriteString(v.Name)
 			buf.WriteString(": ")
 			buf.WriteString(v.Type.String())
+			if v.DefaultValue != nil {
+				buf.WriteString(" = ")
+				buf.WriteString((*v.DefaultValue).String())
+			}
 		}
 		buf.WriteString(")")
 	}
+	buf.WriteString(directivesString(od.Directives))
 	buf.WriteString(" ")
 	buf.WriteString(od.SelectionSet.FormattedString(1))
 	return buf.String()
@@ -255,22 +321,31 @@ func (od OperationDefinition) generateVariableType() string {
 	return "Record<string, any>"
 }
 
+// generateVariableInterface returns the parameter type of the operation's variables,
+// which refers to the variables schema generated by the schema package.
+// The parameter defaults to no variables if all of them are optional.
 func (od OperationDefinition) generateVariableInterface() (string, map[string]bool) {
 	usedTypes := make(map[string]bool)
 	if len(od.Variables) == 0 {
 		return "", usedTypes
 	}
 
-	var fields []string
+	allOptional := true
 	for _, v := range od.Variables {
-		tsType, usedType := ToTypeScript(v.Type)
+		_, usedType := ToTypeScript(v.Type)
 		if usedType != "" {
 			usedTypes[usedType] = true
 		}
-		fields = append(fields, fmt.Sprintf("%s: %s", v.Name, tsType))
+		if _, nonNull := v.Type.(NonNullType); nonNull && v.DefaultValue == nil {
+			allOptional = false
+		}
 	}
 
-	return fmt.Sprintf("{%s}", strings.Join(fields, ", ")), usedTypes
+	varType := "schema." + od.generateFunctionName() + "_Variables"
+	if allOptional {
+		varType += " = {}"
+	}
+	return varType, usedTypes
 }
 
 func (od OperationDefinition) collectUsedTypes() map[string]bool {
@@ -337,17 +412,43 @@ func (fd FragmentDefinition) GenerateTypeScriptMethod(w io.Writer) (map[string]b
 	return make(map[string]bool), nil
 }
 
-// Empty implementations for other AST nodes
+// Document TypeScript generation methods
+// Operations are generated with the fragments they use appended to their query string
 func (d Document) GenerateTypeScript(w io.Writer) (map[string]bool, error) {
-	// Document doesn't generate TypeScript directly
-	return make(map[string]bool), nil
+	usedTypes := make(map[string]bool)
+	for _, od := range d.Operations {
+		opUsedTypes, err := od.generateTypeScript(w, d.FormattedOperationString(od))
+		for t := range opUsedTypes {
+			usedTypes[t] = true
+		}
+		if err != nil {
+			return usedTypes, err
+		}
+	}
+	return usedTypes, nil
 }
 
 func (d Document) GenerateTypeScriptMethod(w io.Writer) (map[string]bool, error) {
-	// Document doesn't generate methods
-	return make(map[string]bool), nil
+	return d.GenerateTypeScriptClassMethods(w, TypeScriptClassOptions{})
+}
+
+// GenerateTypeScriptClassMethods generates the methods of the operations for the client class
+func (d Document) GenerateTypeScriptClassMethods(w io.Writer, options TypeScriptClassOptions) (map[string]bool, error) {
+	usedTypes := make(map[string]bool)
+	for _, od := range d.Operations {
+		opUsedTypes, err := od.generateTypeScriptMethod(w, d.FormattedOperationString(od), options)
+		for t := range opUsedTypes {
+			usedTypes[t] = true
+		}
+		if err != nil {
+			return usedTypes, err
+		}
+	}
+	return usedTypes, nil
 }
 
+// Empty implementations for other AST nodes
+
 func (e Error) GenerateTypeScript(w io.Writer) (map[string]bool, error) {
 	return make(map[string]bool), nil
 }
@@ -403,3 +504,29 @@ func (utd UnionTypeDefinition) GenerateTypeScript(w io.Writer) (map[string]bool,
 func (utd UnionTypeDefinition) GenerateTypeScriptMethod(w io.Writer) (map[string]bool, error) {
 	return make(map[string]bool), nil
 }
+
+func (sd SchemaDefinition) GenerateTypeScript(w io.Writer) (map[string]bool, error) {
+	return make(map[string]bool), nil
+}
+
+func (sd SchemaDefinition) GenerateTypeScriptMethod(w io.Writer) (map[string]bool, error) {
+	return make(map[string]bool), nil
+}
+
+func (dd DirectiveDefinition) GenerateTypeScript(w io.Writer) (map[string]bool, error) {
+	return make(map[string]bool), nil
+}
+
+func (dd DirectiveDefinition) GenerateTypeScriptMethod(w io.Writer) (map[string]bool, error) {
+	return make(map[string]bool), nil
+}
+
+// tsString returns the string as TypeScript string literal
+func tsString(s string) string {
+	// JSON string escapes are valid TypeScript string escapes
+	var b strings.Builder
+	enc := json.NewEncoder(&b)
+	enc.SetEscapeHTML(false)
+	_ = enc.Encode(s)
+	return strings.TrimSuffix(b.String(), "\n")
+}
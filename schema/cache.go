@@ -0,0 +1,290 @@
+package schema
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"gqlc/config"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// CacheEntry describes the cached introspection result of a GraphQL endpoint
+type CacheEntry struct {
+	Endpoint  string    `json:"endpoint"`
+	FetchedAt time.Time `json:"fetchedAt"` // Time of the last download or revalidation
+	ETag      string    `json:"etag,omitempty"`
+	Size      int64     `json:"-"`
+}
+
+// introspectionCacheLock serializes access to the cache, projects sharing an endpoint are loaded concurrently
+var introspectionCacheLock sync.Mutex
+
+// errNotModified is returned when the server confirms that the cached introspection result is up to date
+var errNotModified = errors.New("introspection not modified")
+
+// getIntrospection returns the introspection result of the endpoint from the cache.
+// Results older than the TTL are revalidated first, if the endpoint can't be reached the stale result is used.
+func getIntrospection(endpoint string, authorization string, ttl time.Duration) ([]byte, error) {
+	introspectionCacheLock.Lock()
+	defer introspectionCacheLock.Unlock()
+
+	entry, cached := readIntrospectionCacheEntry(endpoint)
+	if !cached || time.Since(entry.FetchedAt) >= ttl {
+		if _, err := downloadIntrospection(endpoint, authorization, entry); err != nil {
+			if !cached {
+				return nil, err
+			}
+			fmt.Fprintf(os.Stderr, "warning: using the cached schema of %s from %s ago, revalidation failed: %v\n", endpoint, time.Since(entry.FetchedAt).Round(time.Second), err)
+		}
+	}
+	return loadFromIntrospectionCache(endpoint)
+}
+
+// FetchIntrospection downloads the introspection result of the endpoint into the cache,
+// regardless of the age of a cached result. It returns false if the cached result was still up to date.
+func FetchIntrospection(input config.Input, endpoint string) (bool, error) {
+	introspectionCacheLock.Lock()
+	defer introspectionCacheLock.Unlock()
+
+	entry, _ := readIntrospectionCacheEntry(endpoint)
+	return downloadIntrospection(endpoint, input.WebAuthorization, entry)
+}
+
+// LoadIntrospection returns the introspection result of the endpoint as the server sent it
+func LoadIntrospection(input config.Input, endpoint string) ([]byte, error) {
+	ttl, err := input.IntrospectionCacheTTL()
+	if err != nil {
+		return nil, err
+	}
+	data, err := getIntrospection(endpoint, input.WebAuthorization, ttl)
+	if err != nil {
+		return nil, fmt.Errorf("failed to download schema introspection: %w", err)
+	}
+	return data, nil
+}
+
+// downloadIntrospection stores the introspection result of the endpoint in the cache.
+// The previous entry is used to revalidate the cached result with its ETag.
+func downloadIntrospection(endpoint string, authorization string, previous CacheEntry) (bool, error) {
+	data, etag, err := requestIntrospection(endpoint, authorization, introspectionQuery, previous.ETag)
+	var queryErr introspectionQueryError
+	if errors.As(err, &queryErr) {
+		// Servers implementing an older specification reject the newer introspection fields
+		if legacyData, legacyETag, legacyErr := requestIntrospection(endpoint, authorization, legacyIntrospectionQuery, previous.ETag); legacyErr == nil || errors.Is(legacyErr, errNotModified) {
+			data, etag, err = legacyData, legacyETag, legacyErr
+		}
+	}
+
+	entry := CacheEntry{Endpoint: endpoint, FetchedAt: time.Now(), ETag: etag}
+	if errors.Is(err, errNotModified) {
+		entry.ETag = previous.ETag
+		return false, writeIntrospectionCacheEntry(entry)
+	}
+	if err != nil {
+		return false, err
+	}
+
+	if err := storeInIntrospectionCache(bytes.NewReader(data), endpoint); err != nil {
+		return false, err
+	}
+	return true, writeIntrospectionCacheEntry(entry)
+}
+
+// introspectionQueryError is returned when the server responds to the introspection query with errors
+type introspectionQueryError []string
+
+func (e introspectionQueryError) Error() string {
+	return "introspection query failed: " + strings.Join(e, "; ")
+}
+
+// requestIntrospection sends the introspection query and returns the response body and its ETag.
+// If an ETag is given and the server responds with 304 Not Modified, errNotModified is returned.
+func requestIntrospection(endpoint string, authorization string, query string, etag string) ([]byte, string, error) {
+	payload := map[string]string{"query": query}
+	body, err := json.Marshal(payload)
+	if err != nil {
+		return nil, "", fmt.Errorf("failed to marshal introspection query: %w", err)
+	}
+
+	client := &http.Client{Timeout: 30 * time.Second}
+	req, err := http.NewRequest("POST", endpoint, bytes.NewReader(body))
+	if err != nil {
+		return nil, "", fmt.Errorf("failed to create introspection request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	if len(authorization) > 0 {
+		req.Header.Set("Authorization", authorization)
+	}
+	if len(etag) > 0 {
+		req.Header.Set("If-None-Match", etag)
+	}
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, "", fmt.Errorf("failed to send introspection request: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode == http.StatusNotModified && len(etag) > 0 {
+		return nil, "", errNotModified
+	}
+
+	data, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, "", fmt.Errorf("failed to read introspection response: %w", err)
+	}
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		var introspection introspectionResponse
+		if json.Unmarshal(data, &introspection) == nil && len(introspection.Errors) > 0 {
+			return nil, "", introspection.queryError()
+		}
+		return nil, "", fmt.Errorf("non-2xx status %d: %s", resp.StatusCode, string(data))
+	}
+
+	var introspection introspectionResponse
+	if err := json.Unmarshal(data, &introspection); err != nil {
+		return nil, "", fmt.Errorf("failed to parse schema introspection: %w", err)
+	}
+	if len(introspection.Errors) > 0 {
+		return nil, "", introspection.queryError()
+	}
+
+	return data, resp.Header.Get("ETag"), nil
+}
+
+func (r introspectionResponse) queryError() introspectionQueryError {
+	messages := make(introspectionQueryError, len(r.Errors))
+	for i, e := range r.Errors {
+		messages[i] = e.Message
+	}
+	return messages
+}
+
+func introspectionCacheDir() string {
+	return filepath.Join(cacheLocation(), "introspection")
+}
+
+func introspectionCacheLocation(origin string) string {
+	dir := introspectionCacheDir()
+	_ = os.MkdirAll(dir, 0755)
+	return filepath.Join(dir, base64.URLEncoding.EncodeToString([]byte(origin))+".json")
+}
+
+// introspectionCacheEntryLocation is the location of the metadata next to the cached introspection result
+func introspectionCacheEntryLocation(origin string) string {
+	return strings.TrimSuffix(introspectionCacheLocation(origin), ".json") + ".meta.json"
+}
+
+func storeInIntrospectionCache(r io.Reader, origin string) error {
+	file, err := os.Create(introspectionCacheLocation(origin))
+	if err != nil {
+		return fmt.Errorf("failed to create introspection cache file: %w", err)
+	}
+	defer file.Close()
+	_, err = io.Copy(file, r)
+	return err
+}
+
+func loadFromIntrospectionCache(origin string) ([]byte, error) {
+	data, err := os.ReadFile(introspectionCacheLocation(origin))
+	if err != nil {
+		return nil, fmt.Errorf("failed to read introspection cache file: %w", err)
+	}
+	return data, nil
+}
+
+// migrateLegacyIntrospectionCache renames a result cached by an older version, which encoded the endpoint
+// with the standard base64 encoding, to the current location
+func migrateLegacyIntrospectionCache(origin string) {
+	location := introspectionCacheLocation(origin)
+	legacy := filepath.Join(introspectionCacheDir(), base64.StdEncoding.EncodeToString([]byte(origin))+".json")
+	if legacy == location {
+		return
+	}
+	if _, err := os.Stat(location); errors.Is(err, os.ErrNotExist) {
+		_ = os.Rename(legacy, location)
+	}
+}
+
+// readIntrospectionCacheEntry returns the cache entry of the endpoint and whether its introspection result is cached.
+// Results cached by older versions have no metadata, the modification time of the file is used instead.
+func readIntrospectionCacheEntry(origin string) (CacheEntry, bool) {
+	migrateLegacyIntrospectionCache(origin)
+	info, err := os.Stat(introspectionCacheLocation(origin))
+	if err != nil {
+		return CacheEntry{}, false
+	}
+	entry := CacheEntry{Endpoint: origin, FetchedAt: info.ModTime()}
+	if data, err := os.ReadFile(introspectionCacheEntryLocation(origin)); err == nil {
+		_ = json.Unmarshal(data, &entry)
+	}
+	entry.Size = info.Size()
+	return entry, true
+}
+
+func writeIntrospectionCacheEntry(entry CacheEntry) error {
+	data, err := json.Marshal(entry)
+	if err != nil {
+		return fmt.Errorf("failed to marshal introspection cache entry: %w", err)
+	}
+	if err := os.WriteFile(introspectionCacheEntryLocation(entry.Endpoint), data, 0644); err != nil {
+		return fmt.Errorf("failed to write introspection cache entry: %w", err)
+	}
+	return nil
+}
+
+// ListIntrospectionCache returns the cached introspection results sorted by endpoint
+func ListIntrospectionCache() ([]CacheEntry, error) {
+	files, err := os.ReadDir(introspectionCacheDir())
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read introspection cache: %w", err)
+	}
+
+	var entries []CacheEntry
+	for _, file := range files {
+		name := file.Name()
+		if file.IsDir() || !strings.HasSuffix(name, ".json") || strings.HasSuffix(name, ".meta.json") {
+			continue
+		}
+		encoded := strings.TrimSuffix(name, ".json")
+		endpoint, err := base64.URLEncoding.DecodeString(encoded)
+		if err != nil {
+			// Older versions used the standard encoding, readIntrospectionCacheEntry migrates the file
+			if endpoint, err = base64.StdEncoding.DecodeString(encoded); err != nil {
+				continue
+			}
+		}
+		if entry, ok := readIntrospectionCacheEntry(string(endpoint)); ok {
+			entries = append(entries, entry)
+		}
+	}
+
+	sort.Slice(entries, func(i, j int) bool {
+		return entries[i].Endpoint < entries[j].Endpoint
+	})
+	return entries, nil
+}
+
+// ClearIntrospectionCache removes all cached introspection results and returns how many were removed
+func ClearIntrospectionCache() (int, error) {
+	entries, err := ListIntrospectionCache()
+	if err != nil {
+		return 0, err
+	}
+	if err := os.RemoveAll(introspectionCacheDir()); err != nil {
+		return 0, fmt.Errorf("failed to clear introspection cache: %w", err)
+	}
+	return len(entries), nil
+}
@@ -0,0 +1,277 @@
+package schema
+
+import (
+	"gqlc/parser"
+)
+
+// AddTypenames returns a copy of the document in which every selection set on an interface or union
+// that narrows the type with fragments also selects __typename, so the runtime type of a result can be
+// told apart by the generated schemas.
+func (s *Schema) AddTypenames(doc parser.Document) parser.Document {
+	fragments := make(map[string]parser.FragmentDefinition)
+	for _, fd := range doc.Fragments {
+		fragments[fd.Name] = fd
+	}
+
+	result := parser.Document{Metadata: doc.Metadata}
+	for _, od := range doc.Operations {
+		var rootType *TypeDefinition
+		switch od.Type {
+		case parser.Query:
+			rootType = s.Query
+		case parser.Mutation:
+			rootType = s.Mutation
+		case parser.Subscription:
+			rootType = s.Subscription
+		}
+		od.SelectionSet = s.addTypenames(od.SelectionSet, rootType, fragments)
+		result.Operations = append(result.Operations, od)
+	}
+	for _, fd := range doc.Fragments {
+		if typeCondition, ok := s.Types[fd.TypeName]; ok {
+			fd.SelectionSet = s.addTypenames(fd.SelectionSet, &typeCondition, fragments)
+		}
+		result.Fragments = append(result.Fragments, fd)
+	}
+	return result
+}
+
+// DescribeOperations returns a copy of the document in which operations without a description
+// are described by the root field they select, if they select a single one.
+func (s *Schema) DescribeOperations(doc parser.Document) parser.Document {
+	result := parser.Document{Metadata: doc.Metadata, Fragments: doc.Fragments}
+	for _, od := range doc.Operations {
+		var rootType *TypeDefinition
+		switch od.Type {
+		case parser.Query:
+			rootType = s.Query
+		case parser.Mutation:
+			rootType = s.Mutation
+		case parser.Subscription:
+			rootType = s.Subscription
+		}
+		od.Description = operationDescription(od, rootType)
+		result.Operations = append(result.Operations, od)
+	}
+	return result
+}
+
+// operationDescription returns the description of the operation or the documentation of its only root field
+func operationDescription(od parser.OperationDefinition, rootType *TypeDefinition) *string {
+	if od.Description != nil || rootType == nil {
+		return od.Description
+	}
+	var rootField *parser.Field
+	for _, sel := range od.SelectionSet.Selections {
+		field, ok := sel.(parser.Field)
+		if !ok {
+			return nil
+		}
+		if field.Name == "__typename" {
+			continue
+		}
+		if rootField != nil {
+			return nil
+		}
+		rootField = &field
+	}
+	if rootField == nil {
+		return nil
+	}
+	if fieldDef := findFieldDefinition(rootType, rootField.Name); fieldDef != nil {
+		return documentation(fieldDef.Description, fieldDef.IsDeprecated, fieldDef.DeprecationReason)
+	}
+	return nil
+}
+
+func (s *Schema) addTypenames(ss parser.SelectionSet, parentType *TypeDefinition, fragments map[string]parser.FragmentDefinition) parser.SelectionSet {
+	if parentType == nil {
+		return ss
+	}
+
+	selections := make([]parser.Selection, 0, len(ss.Selections)+1)
+	hasTypename := false
+	for _, sel := range ss.Selections {
+		switch sel := sel.(type) {
+		case parser.Field:
+			if sel.Name == "__typename" && sel.Alias == nil {
+				hasTypename = true
+			}
+			if sel.SelectionSet != nil {
+				if fieldDef := findFieldDefinition(parentType, sel.Name); fieldDef != nil {
+					if fieldType, ok := s.Types[baseTypeName(fieldDef.Type)]; ok {
+						subSelection := s.addTypenames(*sel.SelectionSet, &fieldType, fragments)
+						sel.SelectionSet = &subSelection
+					}
+				}
+			}
+			selections = append(selections, sel)
+		case parser.InlineFragment:
+			fragmentType := parentType
+			if sel.TypeName != nil {
+				if typeCondition, ok := s.Types[*sel.TypeName]; ok {
+					fragmentType = &typeCondition
+				}
+			}
+			sel.SelectionSet = s.addTypenames(sel.SelectionSet, fragmentType, fragments)
+			selections = append(selections, sel)
+		default:
+			selections = append(selections, sel)
+		}
+	}
+
+	if !hasTypename && selectionNarrowsType(ss, parentType, s, fragments, make(map[string]bool)) {
+		selections = append([]parser.Selection{parser.Field{Name: "__typename"}}, selections...)
+	}
+
+	return parser.SelectionSet{Selections: selections}
+}
+
+// StripClientFields returns a copy of the document without the fields only known to client schemas,
+// which is the document sent to the server. Selection sets left empty select __typename instead.
+func (s *Schema) StripClientFields(doc parser.Document) parser.Document {
+	result := parser.Document{Metadata: doc.Metadata}
+	for _, od := range doc.Operations {
+		var rootType *TypeDefinition
+		switch od.Type {
+		case parser.Query:
+			rootType = s.Query
+		case parser.Mutation:
+			rootType = s.Mutation
+		case parser.Subscription:
+			rootType = s.Subscription
+		}
+		od.SelectionSet = s.stripClientFields(od.SelectionSet, rootType)
+		result.Operations = append(result.Operations, od)
+	}
+	for _, fd := range doc.Fragments {
+		if typeCondition, ok := s.Types[fd.TypeName]; ok {
+			fd.SelectionSet = s.stripClientFields(fd.SelectionSet, &typeCondition)
+		}
+		result.Fragments = append(result.Fragments, fd)
+	}
+
+	// Variables and fragments only the stripped fields used are removed as well, the server rejects unused ones
+	spread := make(map[string]bool)
+	for i, od := range result.Operations {
+		used := make(map[string]bool)
+		collectDirectiveVariables(od.Directives, used)
+		collectSelectionVariables(od.SelectionSet, used)
+		for _, fd := range result.UsedFragments(od.SelectionSet) {
+			spread[fd.Name] = true
+			collectDirectiveVariables(fd.Directives, used)
+			collectSelectionVariables(fd.SelectionSet, used)
+		}
+		variables := make([]parser.VariableDefinition, 0, len(od.Variables))
+		for _, vd := range od.Variables {
+			if used[vd.Name] {
+				variables = append(variables, vd)
+			}
+		}
+		result.Operations[i].Variables = variables
+	}
+	fragments := make([]parser.FragmentDefinition, 0, len(result.Fragments))
+	for _, fd := range result.Fragments {
+		if spread[fd.Name] {
+			fragments = append(fragments, fd)
+		}
+	}
+	result.Fragments = fragments
+	return result
+}
+
+// collectSelectionVariables collects the names of the variables used in the selection set,
+// without the fragments it spreads
+func collectSelectionVariables(ss parser.SelectionSet, used map[string]bool) {
+	for _, sel := range ss.Selections {
+		switch sel := sel.(type) {
+		case parser.Field:
+			collectDirectiveVariables(sel.Directives, used)
+			for _, arg := range sel.Arguments {
+				collectValueVariables(arg.Value, used)
+			}
+			if sel.SelectionSet != nil {
+				collectSelectionVariables(*sel.SelectionSet, used)
+			}
+		case parser.InlineFragment:
+			collectDirectiveVariables(sel.Directives, used)
+			collectSelectionVariables(sel.SelectionSet, used)
+		case parser.FragmentSpread:
+			collectDirectiveVariables(sel.Directives, used)
+		}
+	}
+}
+
+func collectDirectiveVariables(directives []parser.Directive, used map[string]bool) {
+	for _, d := range directives {
+		for _, arg := range d.Arguments {
+			collectValueVariables(arg.Value, used)
+		}
+	}
+}
+
+func collectValueVariables(value parser.Value, used map[string]bool) {
+	switch val := value.(type) {
+	case parser.Variable:
+		used[val.Name] = true
+	case parser.ListValue:
+		for _, item := range val.Values {
+			collectValueVariables(item, used)
+		}
+	case parser.ObjectValue:
+		for _, field := range val.Fields {
+			collectValueVariables(field.Value, used)
+		}
+	}
+}
+
+func (s *Schema) stripClientFields(ss parser.SelectionSet, parentType *TypeDefinition) parser.SelectionSet {
+	if parentType == nil {
+		return ss
+	}
+
+	selections := make([]parser.Selection, 0, len(ss.Selections))
+	for _, sel := range ss.Selections {
+		switch sel := sel.(type) {
+		case parser.Field:
+			fieldDef := findFieldDefinition(parentType, sel.Name)
+			if fieldDef != nil && fieldDef.IsClientOnly {
+				continue
+			}
+			if sel.SelectionSet != nil && fieldDef != nil {
+				if fieldType, ok := s.Types[baseTypeName(fieldDef.Type)]; ok {
+					subSelection := s.stripClientFields(*sel.SelectionSet, &fieldType)
+					sel.SelectionSet = &subSelection
+				}
+			}
+			selections = append(selections, sel)
+		case parser.InlineFragment:
+			fragmentType := parentType
+			if sel.TypeName != nil {
+				if typeCondition, ok := s.Types[*sel.TypeName]; ok {
+					fragmentType = &typeCondition
+				}
+			}
+			sel.SelectionSet = s.stripClientFields(sel.SelectionSet, fragmentType)
+			selections = append(selections, sel)
+		default:
+			selections = append(selections, sel)
+		}
+	}
+
+	if len(selections) == 0 {
+		selections = append(selections, parser.Field{Name: "__typename"})
+	}
+	return parser.SelectionSet{Selections: selections}
+}
+
+// baseTypeName returns the name of the named type wrapped by list and non-null types
+func baseTypeName(typeRef TypeRef) string {
+	if typeRef.Name != nil {
+		return *typeRef.Name
+	}
+	if typeRef.OfType != nil {
+		return baseTypeName(*typeRef.OfType)
+	}
+	return ""
+}
@@ -0,0 +1,420 @@
+package schema
+
+import (
+	"bytes"
+	"fmt"
+	"gqlc/parser"
+	"io"
+	"strconv"
+	"strings"
+)
+
+// GenerateGo generates Go types for the variables and responses of the operations
+// and for the enum and input object types they use
+func (s *Schema) GenerateGo(operations []parser.AST, w io.Writer) error {
+	gen := &GoGenerator{}
+	return gen.GenerateWithOperations(s, operations, w)
+}
+
+// GoGenerator generates Go types for operations
+type GoGenerator struct {
+	operations []parser.AST
+	fragments  map[string]parser.FragmentDefinition
+	// enums collects the enum types used in responses, they are generated after the operations
+	enums map[string]bool
+	// pending holds the nested types of the type that is currently generated
+	pending []func(w io.Writer) error
+	// usesJSON is set if the generated code needs the encoding/json package
+	usesJSON bool
+}
+
+// GenerateWithOperations generates the Go types used by the operations
+func (g *GoGenerator) GenerateWithOperations(schema *Schema, operations []parser.AST, w io.Writer) error {
+	g.operations = operations
+	g.fragments = make(map[string]parser.FragmentDefinition)
+	g.enums = make(map[string]bool)
+	for _, op := range operations {
+		if fragDef, ok := op.(parser.FragmentDefinition); ok {
+			g.fragments[fragDef.Name] = fragDef
+		}
+	}
+
+	// The imports depend on the generated code, so generate the body first
+	var body bytes.Buffer
+	for _, op := range g.operations {
+		if opDef, ok := op.(parser.OperationDefinition); ok {
+			if err := g.generateOperationTypes(&body, opDef, schema); err != nil {
+				return err
+			}
+		}
+	}
+
+	requiredTypes := make(map[string]bool)
+	for _, op := range g.operations {
+		if opDef, ok := op.(parser.OperationDefinition); ok {
+			for _, variable := range opDef.Variables {
+				collectVariableType(schema, variable.Type, requiredTypes)
+			}
+		}
+	}
+	for name := range g.enums {
+		requiredTypes[name] = true
+	}
+	for _, typeName := range sortedKeys(requiredTypes) {
+		if typeDef, ok := schema.Types[typeName]; ok {
+			if err := g.generateType(&body, typeDef, schema); err != nil {
+				return err
+			}
+		}
+	}
+
+	if g.usesJSON {
+		if _, err := fmt.Fprint(w, "import \"encoding/json\"\n"); err != nil {
+			return err
+		}
+	}
+	_, err := body.WriteTo(w)
+	return err
+}
+
+// generateType generates a Go type for an enum or input object type
+func (g *GoGenerator) generateType(w io.Writer, typeDef TypeDefinition, schema *Schema) error {
+	typeName := parser.GoName(typeDef.Name)
+
+	switch typeDef.Kind {
+	case "ENUM":
+		if _, err := fmt.Fprintf(w, "\n// %s is the %s enum\ntype %s string\n\nconst (\n", typeName, typeDef.Name, typeName); err != nil {
+			return err
+		}
+		for _, enumVal := range typeDef.EnumValues {
+			if _, err := fmt.Fprintf(w, "\t%s%s %s = %s\n", typeName, parser.GoName(enumVal.Name), typeName, strconv.Quote(enumVal.Name)); err != nil {
+				return err
+			}
+		}
+		if _, err := fmt.Fprint(w, ")\n"); err != nil {
+			return err
+		}
+
+	case "INPUT_OBJECT":
+		if _, err := fmt.Fprintf(w, "\n// %s is the %s input object\ntype %s struct {\n", typeName, typeDef.Name, typeName); err != nil {
+			return err
+		}
+		for _, field := range typeDef.InputFields {
+			goType := g.inputTypeRef(field.Type, schema)
+			if _, err := fmt.Fprintf(w, "\t%s %s `json:\"%s%s\"`\n", parser.GoName(field.Name), goType, field.Name, omitEmpty(goType, field.Type.Kind != "NON_NULL")); err != nil {
+				return err
+			}
+		}
+		if _, err := fmt.Fprint(w, "}\n"); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// generateOperationTypes generates the variables and response types of an operation
+func (g *GoGenerator) generateOperationTypes(w io.Writer, op parser.OperationDefinition, schema *Schema) error {
+	var funcNameStr string
+	if op.Name != nil {
+		funcNameStr = *op.Name
+	} else {
+		funcNameStr = fmt.Sprintf("%sOperation", strings.Title(strings.ToLower(op.Type.String())))
+	}
+	goName := parser.GoName(funcNameStr)
+
+	var rootType *TypeDefinition
+	switch op.Type {
+	case parser.Query:
+		rootType = schema.Query
+	case parser.Mutation:
+		rootType = schema.Mutation
+	case parser.Subscription:
+		rootType = schema.Subscription
+	}
+
+	if rootType == nil {
+		return fmt.Errorf("root type not found for operation type %s", op.Type)
+	}
+
+	if len(op.Variables) > 0 {
+		if _, err := fmt.Fprintf(w, "\n// %sVariables are the variables of the %s operation\ntype %sVariables struct {\n", goName, funcNameStr, goName); err != nil {
+			return err
+		}
+		for _, variable := range op.Variables {
+			goType := g.variableType(variable.Type, schema, true)
+			_, nonNull := variable.Type.(parser.NonNullType)
+			if _, err := fmt.Fprintf(w, "\t%s %s `json:\"%s%s\"`\n", parser.GoName(variable.Name), goType, variable.Name, omitEmpty(goType, !nonNull)); err != nil {
+				return err
+			}
+		}
+		if _, err := fmt.Fprint(w, "}\n"); err != nil {
+			return err
+		}
+	}
+
+	comment := fmt.Sprintf("%sResponse is the result of the %s operation", goName, funcNameStr)
+	if err := g.generateSelectionSetType(w, goName+"Response", comment, op.SelectionSet, rootType, schema); err != nil {
+		return fmt.Errorf("operation %s: %w", funcNameStr, err)
+	}
+	return nil
+}
+
+// generateSelectionSetType generates a struct type for the selection set and the types nested in it
+func (g *GoGenerator) generateSelectionSetType(w io.Writer, name string, comment string, ss parser.SelectionSet, parentType *TypeDefinition, schema *Schema) error {
+	if selectionNarrowsType(ss, parentType, schema, g.fragments, make(map[string]bool)) {
+		if err := g.generatePolymorphicType(w, name, comment, ss, parentType, schema); err != nil {
+			return err
+		}
+	} else {
+		runtimeType := ""
+		if parentType != nil && !schema.IsAbstract(parentType.Name) {
+			runtimeType = parentType.Name
+		}
+		fields, err := collectFields(g.fragments, ss, parentType, runtimeType, false, schema, nil, make(map[string]bool))
+		if err != nil {
+			return err
+		}
+		if err := g.generateStruct(w, name, comment, fields, schema); err != nil {
+			return err
+		}
+	}
+
+	// Generate the nested types after their parent
+	pending := g.pending
+	g.pending = nil
+	for _, generate := range pending {
+		if err := generate(w); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// generatePolymorphicType generates a struct with a field per possible runtime type of an interface or union,
+// the field matching the __typename of the response is set when the struct is decoded
+func (g *GoGenerator) generatePolymorphicType(w io.Writer, name string, comment string, ss parser.SelectionSet, parentType *TypeDefinition, schema *Schema) error {
+	possibleTypes := schema.PossibleTypes(parentType.Name)
+	if len(possibleTypes) == 0 {
+		fields, err := collectFields(g.fragments, ss, parentType, "", false, schema, nil, make(map[string]bool))
+		if err != nil {
+			return err
+		}
+		return g.generateStruct(w, name, comment, fields, schema)
+	}
+
+	g.usesJSON = true
+	if _, err := fmt.Fprintf(w, "\n// %s\n// The field of the runtime type named by Typename is set.\ntype %s struct {\n\tTypename string `json:\"__typename\"`\n", comment, name); err != nil {
+		return err
+	}
+	for _, possibleType := range possibleTypes {
+		if _, err := fmt.Fprintf(w, "\t%s *%s%s `json:\"-\"`\n", parser.GoName(possibleType), name, parser.GoName(possibleType)); err != nil {
+			return err
+		}
+	}
+	if _, err := fmt.Fprint(w, "}\n"); err != nil {
+		return err
+	}
+
+	if _, err := fmt.Fprintf(w, `
+// UnmarshalJSON decodes the value into the field of its runtime type
+func (v *%s) UnmarshalJSON(data []byte) error {
+	var typename struct {
+		Typename string `+"`json:\"__typename\"`"+`
+	}
+	if err := json.Unmarshal(data, &typename); err != nil {
+		return err
+	}
+	v.Typename = typename.Typename
+	switch typename.Typename {
+`, name); err != nil {
+		return err
+	}
+	for _, possibleType := range possibleTypes {
+		if _, err := fmt.Fprintf(w, "\tcase %s:\n\t\tv.%s = new(%s%s)\n\t\treturn json.Unmarshal(data, v.%s)\n",
+			strconv.Quote(possibleType), parser.GoName(possibleType), name, parser.GoName(possibleType), parser.GoName(possibleType)); err != nil {
+			return err
+		}
+	}
+	if _, err := fmt.Fprint(w, "\t}\n\treturn nil\n}\n"); err != nil {
+		return err
+	}
+
+	for _, possibleType := range possibleTypes {
+		fields, err := collectFields(g.fragments, ss, parentType, possibleType, false, schema, nil, make(map[string]bool))
+		if err != nil {
+			return err
+		}
+		typeName := name + parser.GoName(possibleType)
+		typeComment := fmt.Sprintf("%s is the %s variant of %s", typeName, possibleType, name)
+		if err := g.generateStruct(w, typeName, typeComment, fields, schema); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// generateStruct generates a struct type for the collected fields
+func (g *GoGenerator) generateStruct(w io.Writer, name string, comment string, fields []*selectedField, schema *Schema) error {
+	if _, err := fmt.Fprintf(w, "\n// %s\ntype %s struct {\n", comment, name); err != nil {
+		return err
+	}
+	for _, sf := range fields {
+		goType, err := g.selectedFieldType(name, sf, schema)
+		if err != nil {
+			return err
+		}
+		// Fields excluded by @include or @skip are missing from the response
+		if sf.conditional && !isNilable(goType) {
+			goType = "*" + goType
+		}
+		if _, err := fmt.Fprintf(w, "\t%s %s `json:\"%s\"`\n", parser.GoName(sf.key), goType, sf.key); err != nil {
+			return err
+		}
+	}
+	if _, err := fmt.Fprint(w, "}\n"); err != nil {
+		return err
+	}
+	return nil
+}
+
+// selectedFieldType returns the Go type of a selected field,
+// a struct type is queued for fields with a selection set
+func (g *GoGenerator) selectedFieldType(parentName string, sf *selectedField, schema *Schema) (string, error) {
+	if sf.field.Name == "__typename" {
+		return "string", nil
+	}
+
+	fieldDef := findFieldDefinition(sf.parentType, sf.field.Name)
+	if fieldDef == nil {
+		g.usesJSON = true
+		return "json.RawMessage", nil
+	}
+
+	named := func(name string) string {
+		return g.outputNamedType(name, schema)
+	}
+	if sf.field.SelectionSet != nil {
+		fieldTypeName := baseTypeName(fieldDef.Type)
+		if fieldType, ok := schema.Types[fieldTypeName]; ok {
+			typeName := parentName + parser.GoName(sf.key)
+			subSelection := parser.SelectionSet{Selections: sf.selections}
+			comment := fmt.Sprintf("%s is the selection of %s on %s", typeName, sf.key, fieldTypeName)
+			g.pending = append(g.pending, func(w io.Writer) error {
+				return g.generateSelectionSetType(w, typeName, comment, subSelection, &fieldType, schema)
+			})
+			named = func(string) string {
+				return typeName
+			}
+		}
+	}
+
+	return goTypeRef(fieldDef.Type, named, true), nil
+}
+
+// outputNamedType returns the Go type of a named type in a response
+func (g *GoGenerator) outputNamedType(name string, schema *Schema) string {
+	if goType, ok := goScalarType(name); ok {
+		return goType
+	}
+	if typeDef, ok := schema.Types[name]; ok && typeDef.Kind == "ENUM" {
+		g.enums[name] = true
+		return parser.GoName(name)
+	}
+	g.usesJSON = true
+	return "json.RawMessage"
+}
+
+// inputTypeRef returns the Go type of an input object field
+func (g *GoGenerator) inputTypeRef(typeRef TypeRef, schema *Schema) string {
+	return goTypeRef(typeRef, func(name string) string {
+		return g.inputNamedType(name, schema)
+	}, true)
+}
+
+// inputNamedType returns the Go type of a named type in variables and input objects
+func (g *GoGenerator) inputNamedType(name string, schema *Schema) string {
+	if goType, ok := goScalarType(name); ok {
+		return goType
+	}
+	if typeDef, ok := schema.Types[name]; ok && (typeDef.Kind == "ENUM" || typeDef.Kind == "INPUT_OBJECT") {
+		return parser.GoName(name)
+	}
+	g.usesJSON = true
+	return "json.RawMessage"
+}
+
+// variableType returns the Go type of an operation variable
+func (g *GoGenerator) variableType(t parser.Type, schema *Schema, nullable bool) string {
+	switch typ := t.(type) {
+	case parser.NonNullType:
+		return g.variableType(typ.Type, schema, false)
+	case parser.ListType:
+		return "[]" + g.variableType(typ.Type, schema, true)
+	case parser.NamedType:
+		goType := g.inputNamedType(typ.Name, schema)
+		if nullable && !isNilable(goType) {
+			return "*" + goType
+		}
+		return goType
+	default:
+		g.usesJSON = true
+		return "json.RawMessage"
+	}
+}
+
+// goTypeRef returns the Go type of a type reference, nullable named types become pointers
+func goTypeRef(typeRef TypeRef, named func(name string) string, nullable bool) string {
+	switch typeRef.Kind {
+	case "NON_NULL":
+		if typeRef.OfType == nil {
+			return "json.RawMessage"
+		}
+		return goTypeRef(*typeRef.OfType, named, false)
+	case "LIST":
+		if typeRef.OfType == nil {
+			return "[]json.RawMessage"
+		}
+		return "[]" + goTypeRef(*typeRef.OfType, named, true)
+	default:
+		name := ""
+		if typeRef.Name != nil {
+			name = *typeRef.Name
+		}
+		goType := named(name)
+		if nullable && !isNilable(goType) {
+			return "*" + goType
+		}
+		return goType
+	}
+}
+
+// goScalarType returns the Go type of a built-in scalar
+func goScalarType(name string) (string, bool) {
+	switch name {
+	case "String", "ID":
+		return "string", true
+	case "Int":
+		return "int", true
+	case "Float":
+		return "float64", true
+	case "Boolean":
+		return "bool", true
+	default:
+		return "", false
+	}
+}
+
+// isNilable checks if null can be represented by the zero value of the Go type
+func isNilable(goType string) bool {
+	return strings.HasPrefix(goType, "*") || strings.HasPrefix(goType, "[]") || goType == "json.RawMessage"
+}
+
+// omitEmpty returns the omitempty option for nullable values, so unset variables and input fields are not sent
+// and their default values apply. Lists are always sent, because omitempty would drop empty lists as well.
+func omitEmpty(goType string, nullable bool) string {
+	if nullable && !strings.HasPrefix(goType, "[]") {
+		return ",omitempty"
+	}
+	return ""
+}
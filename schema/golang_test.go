@@ -0,0 +1,97 @@
+package schema
+
+import (
+	"bytes"
+	"go/format"
+	"strings"
+	"testing"
+)
+
+func TestGoGenerator_GeneratesOperationTypes(t *testing.T) {
+	s := mustBuildSchema(t, `type Query {
+  user(id: ID!): User
+  search(filter: SearchFilter!): [SearchResult!]!
+}
+
+enum Role {
+  ADMIN
+  REGULAR_USER
+}
+
+input SearchFilter {
+  term: String!
+  roles: [Role!]
+  limit: Int = 10
+}
+
+type User {
+  id: ID!
+  name: String
+  role: Role!
+  friends: [User!]!
+}
+
+type Post {
+  title: String!
+}
+
+union SearchResult = User | Post`)
+
+	operations := mustParse(t, `query GetUser($id: ID!, $withRole: Boolean!) {
+  user(id: $id) {
+    id
+    name
+    role @include(if: $withRole)
+    friends {
+      id
+    }
+  }
+}
+
+query Search($filter: SearchFilter!) {
+  search(filter: $filter) {
+    ... on User {
+      name
+    }
+    ... on Post {
+      title
+    }
+  }
+}`)
+
+	var buf bytes.Buffer
+	if err := s.GenerateGo(operations, &buf); err != nil {
+		t.Fatalf("GenerateGo returned error: %v", err)
+	}
+	formatted, err := format.Source(append([]byte("package gql\n\n"), buf.Bytes()...))
+	if err != nil {
+		t.Fatalf("generated code is not valid Go: %v\n%s", err, buf.String())
+	}
+	output := string(formatted)
+
+	for _, expected := range []string{
+		`type GetUserVariables struct {
+	ID       string ` + "`json:\"id\"`" + `
+	WithRole bool   ` + "`json:\"withRole\"`",
+		`type GetUserResponseUser struct {
+	ID      string                       ` + "`json:\"id\"`" + `
+	Name    *string                      ` + "`json:\"name\"`" + `
+	Role    *Role                        ` + "`json:\"role\"`" + `
+	Friends []GetUserResponseUserFriends ` + "`json:\"friends\"`",
+		`type SearchResponseSearch struct {
+	Typename string                    ` + "`json:\"__typename\"`" + `
+	User     *SearchResponseSearchUser ` + "`json:\"-\"`" + `
+	Post     *SearchResponseSearchPost ` + "`json:\"-\"`",
+		`case "Post":
+		v.Post = new(SearchResponseSearchPost)`,
+		`RoleRegularUser Role = "REGULAR_USER"`,
+		`type SearchFilter struct {
+	Term  string ` + "`json:\"term\"`" + `
+	Roles []Role ` + "`json:\"roles\"`" + `
+	Limit *int   ` + "`json:\"limit,omitempty\"`",
+	} {
+		if !strings.Contains(output, expected) {
+			t.Fatalf("expected %q in output:\n%s", expected, output)
+		}
+	}
+}
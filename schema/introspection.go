@@ -0,0 +1,145 @@
+package schema
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"sort"
+)
+
+// WriteIntrospection writes the schema as introspection result, like a GraphQL endpoint responds to the introspection query
+func (s *Schema) WriteIntrospection(w io.Writer) error {
+	var intro introspectionResponse
+	if s.Query != nil {
+		intro.Data.Schema.QueryType.Name = s.Query.Name
+	}
+	if s.Mutation != nil {
+		intro.Data.Schema.MutationType = &PossibleType{Name: s.Mutation.Name}
+	}
+	if s.Subscription != nil {
+		intro.Data.Schema.SubscriptionType = &PossibleType{Name: s.Subscription.Name}
+	}
+
+	typeNames := make([]string, 0, len(s.Types))
+	for name := range s.Types {
+		typeNames = append(typeNames, name)
+	}
+	sort.Strings(typeNames)
+	intro.Data.Schema.Types = make([]IntrospectionType, 0, len(typeNames))
+	for _, name := range typeNames {
+		intro.Data.Schema.Types = append(intro.Data.Schema.Types, s.introspectionType(s.Types[name]))
+	}
+
+	directiveNames := make([]string, 0, len(s.Directives))
+	for name := range s.Directives {
+		directiveNames = append(directiveNames, name)
+	}
+	sort.Strings(directiveNames)
+	intro.Data.Schema.Directives = make([]IntrospectionDirective, 0, len(directiveNames))
+	for _, name := range directiveNames {
+		d := s.Directives[name]
+		intro.Data.Schema.Directives = append(intro.Data.Schema.Directives, IntrospectionDirective{
+			Name:         d.Name,
+			Description:  d.Description,
+			IsRepeatable: d.IsRepeatable,
+			Locations:    d.Locations,
+			Args:         s.introspectionArgs(d.Args),
+		})
+	}
+
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	if err := enc.Encode(struct {
+		Data introspectionData `json:"data"`
+	}{intro.Data}); err != nil {
+		return fmt.Errorf("failed to write introspection: %w", err)
+	}
+	return nil
+}
+
+func (s *Schema) introspectionType(t TypeDefinition) IntrospectionType {
+	name := t.Name
+	it := IntrospectionType{
+		Kind:           t.Kind,
+		Name:           &name,
+		Description:    t.Description,
+		SpecifiedByURL: t.SpecifiedByURL,
+	}
+	switch t.Kind {
+	case "OBJECT", "INTERFACE":
+		it.Fields = make([]Field, 0, len(t.Fields))
+		for _, f := range t.Fields {
+			it.Fields = append(it.Fields, Field{
+				Name:              f.Name,
+				Description:       f.Description,
+				Args:              s.introspectionArgs(f.Args),
+				Type:              s.introspectionTypeRef(f.Type),
+				IsDeprecated:      f.IsDeprecated,
+				DeprecationReason: f.DeprecationReason,
+			})
+		}
+		it.Interfaces = make([]PossibleType, 0, len(t.Interfaces))
+		for _, name := range t.Interfaces {
+			it.Interfaces = append(it.Interfaces, PossibleType{Name: name})
+		}
+		if t.Kind == "INTERFACE" {
+			for _, name := range s.PossibleTypes(t.Name) {
+				it.PossibleTypes = append(it.PossibleTypes, PossibleType{Name: name})
+			}
+		}
+	case "INPUT_OBJECT":
+		it.InputFields = make([]InputField, 0, len(t.InputFields))
+		for _, f := range t.InputFields {
+			it.InputFields = append(it.InputFields, InputField(s.introspectionArg(f)))
+		}
+	case "ENUM":
+		it.EnumValues = make([]EnumValue, 0, len(t.EnumValues))
+		for _, v := range t.EnumValues {
+			it.EnumValues = append(it.EnumValues, EnumValue{
+				Name:              v.Name,
+				Description:       v.Description,
+				IsDeprecated:      v.IsDeprecated,
+				DeprecationReason: v.DeprecationReason,
+			})
+		}
+	case "UNION":
+		for _, name := range t.PossibleTypes {
+			it.PossibleTypes = append(it.PossibleTypes, PossibleType{Name: name})
+		}
+	}
+	return it
+}
+
+func (s *Schema) introspectionArgs(args []InputValueDefinition) []Arg {
+	result := make([]Arg, 0, len(args))
+	for _, arg := range args {
+		result = append(result, s.introspectionArg(arg))
+	}
+	return result
+}
+
+func (s *Schema) introspectionArg(arg InputValueDefinition) Arg {
+	return Arg{
+		Name:              arg.Name,
+		Description:       arg.Description,
+		Type:              s.introspectionTypeRef(arg.Type),
+		DefaultValue:      arg.DefaultValue,
+		IsDeprecated:      arg.IsDeprecated,
+		DeprecationReason: arg.DeprecationReason,
+	}
+}
+
+// introspectionTypeRef sets the kind of named types, type references of schemas from SDL are all SCALAR
+func (s *Schema) introspectionTypeRef(ref TypeRef) TypeRef {
+	result := TypeRef{Kind: ref.Kind, Name: ref.Name}
+	if ref.OfType != nil {
+		ofType := s.introspectionTypeRef(*ref.OfType)
+		result.OfType = &ofType
+	}
+	if ref.Name != nil {
+		if t, ok := s.Types[*ref.Name]; ok {
+			result.Kind = t.Kind
+		}
+	}
+	return result
+}
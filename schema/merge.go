@@ -0,0 +1,343 @@
+package schema
+
+import (
+	"fmt"
+	"gqlc/config"
+	"gqlc/parser"
+	"strings"
+)
+
+// schemaBuilder merges the definitions of one or more schema sources into one schema.
+// It remembers which source defined each type, directive and field to report conflicts between sources.
+type schemaBuilder struct {
+	schema *Schema
+	// Root type names, set by schema definitions and introspection results
+	rootTypes map[parser.OperationType]string
+	// explicitRoots is set once a source declares its root types, the default names are used otherwise
+	explicitRoots bool
+	// Extensions are merged after all definitions are known, they may come before the type they extend
+	extensions       []parser.AST
+	extensionSources []config.SchemaSource
+	typeSources      map[string]string
+	directiveSources map[string]string
+	fieldSources     map[string]string // Keyed by Type.field
+}
+
+// defaultRootTypes are the root type names of schemas without schema definition
+var defaultRootTypes = map[parser.OperationType]string{
+	parser.Query:        "Query",
+	parser.Mutation:     "Mutation",
+	parser.Subscription: "Subscription",
+}
+
+func newSchemaBuilder() *schemaBuilder {
+	b := &schemaBuilder{
+		schema: &Schema{
+			Types:      make(map[string]TypeDefinition),
+			Directives: make(map[string]DirectiveDefinition),
+		},
+		rootTypes:        make(map[parser.OperationType]string),
+		typeSources:      make(map[string]string),
+		directiveSources: make(map[string]string),
+		fieldSources:     make(map[string]string),
+	}
+	addDefaultScalarTypes(b.schema)
+	return b
+}
+
+// addDefinitions adds the definitions of an SDL source, extensions are kept for build
+func (b *schemaBuilder) addDefinitions(source config.SchemaSource, nodes []parser.AST) error {
+	for _, node := range nodes {
+		var typeDef TypeDefinition
+		switch n := node.(type) {
+		case parser.TypeDefinition:
+			if n.Extend {
+				b.addExtension(source, node)
+				continue
+			}
+			typeDef = TypeDefinition{
+				Name:        n.Name,
+				Kind:        "OBJECT",
+				Description: n.Description,
+				Interfaces:  n.Interfaces,
+				Fields:      convertASTFields(n.Fields),
+				Directives:  appliedDirectives(n.Directives),
+			}
+		case parser.InputTypeDefinition:
+			if n.Extend {
+				b.addExtension(source, node)
+				continue
+			}
+			typeDef = TypeDefinition{
+				Name:        n.Name,
+				Kind:        "INPUT_OBJECT",
+				Description: n.Description,
+				InputFields: convertASTInputValues(n.Fields),
+				Directives:  appliedDirectives(n.Directives),
+			}
+		case parser.EnumTypeDefinition:
+			if n.Extend {
+				b.addExtension(source, node)
+				continue
+			}
+			typeDef = TypeDefinition{
+				Name:        n.Name,
+				Kind:        "ENUM",
+				Description: n.Description,
+				EnumValues:  convertASTEnumValues(n.Values),
+				Directives:  appliedDirectives(n.Directives),
+			}
+		case parser.ScalarTypeDefinition:
+			if n.Extend {
+				b.addExtension(source, node)
+				continue
+			}
+			typeDef = TypeDefinition{
+				Name:           n.Name,
+				Kind:           "SCALAR",
+				Description:    n.Description,
+				SpecifiedByURL: specifiedByURL(n.Directives),
+				Directives:     appliedDirectives(n.Directives),
+			}
+		case parser.InterfaceTypeDefinition:
+			if n.Extend {
+				b.addExtension(source, node)
+				continue
+			}
+			typeDef = TypeDefinition{
+				Name:        n.Name,
+				Kind:        "INTERFACE",
+				Description: n.Description,
+				Interfaces:  n.Interfaces,
+				Fields:      convertASTFields(n.Fields),
+				Directives:  appliedDirectives(n.Directives),
+			}
+		case parser.UnionTypeDefinition:
+			if n.Extend {
+				b.addExtension(source, node)
+				continue
+			}
+			typeDef = TypeDefinition{
+				Name:        n.Name,
+				Kind:        "UNION",
+				Description: n.Description,
+				Directives:  appliedDirectives(n.Directives),
+			}
+			typeDef.PossibleTypes = append(typeDef.PossibleTypes, n.Types...)
+		case parser.SchemaDefinition:
+			if !n.Extend {
+				b.explicitRoots = true
+			}
+			for _, op := range n.OperationTypes {
+				b.rootTypes[op.Operation] = op.Type
+			}
+			continue
+		case parser.DirectiveDefinition:
+			if err := b.addDirective(source, DirectiveDefinition{
+				Name:         n.Name,
+				Description:  n.Description,
+				Args:         convertASTInputValues(n.Arguments),
+				Locations:    n.Locations,
+				IsRepeatable: n.Repeatable,
+			}); err != nil {
+				return err
+			}
+			continue
+		default:
+			return fmt.Errorf("schema %s contains an unexpected %T", source.Path, node)
+		}
+		if err := b.addType(source, typeDef); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// addSchema adds the types, directives and root types of a schema loaded by introspection
+func (b *schemaBuilder) addSchema(source config.SchemaSource, s *Schema) error {
+	for _, typeDef := range s.Types {
+		if err := b.addType(source, typeDef); err != nil {
+			return err
+		}
+	}
+	for _, directive := range s.Directives {
+		if err := b.addDirective(source, directive); err != nil {
+			return err
+		}
+	}
+	b.explicitRoots = true
+	if s.Query != nil {
+		b.rootTypes[parser.Query] = s.Query.Name
+	}
+	if s.Mutation != nil {
+		b.rootTypes[parser.Mutation] = s.Mutation.Name
+	}
+	if s.Subscription != nil {
+		b.rootTypes[parser.Subscription] = s.Subscription.Name
+	}
+	return nil
+}
+
+// addType adds a type definition. Built-in types may be defined by every source,
+// other types only by one (a source may redefine its own types).
+func (b *schemaBuilder) addType(source config.SchemaSource, typeDef TypeDefinition) error {
+	if isBuiltInScalar(typeDef.Name) || strings.HasPrefix(typeDef.Name, "__") {
+		if _, ok := b.schema.Types[typeDef.Name]; ok {
+			return nil
+		}
+	}
+	if definedBy, ok := b.typeSources[typeDef.Name]; ok && definedBy != source.Path {
+		return fmt.Errorf("type %s is defined by both %s and %s", typeDef.Name, definedBy, source.Path)
+	}
+	b.typeSources[typeDef.Name] = source.Path
+	typeDef.IsClientOnly = source.Client
+
+	typeDef.Fields = append([]FieldDefinition(nil), typeDef.Fields...)
+	for i := range typeDef.Fields {
+		typeDef.Fields[i].IsClientOnly = typeDef.Fields[i].IsClientOnly || source.Client
+		b.fieldSources[typeDef.Name+"."+typeDef.Fields[i].Name] = source.Path
+	}
+	b.schema.Types[typeDef.Name] = typeDef
+	return nil
+}
+
+func (b *schemaBuilder) addDirective(source config.SchemaSource, directive DirectiveDefinition) error {
+	if builtInDirectives[directive.Name] {
+		if _, ok := b.schema.Directives[directive.Name]; ok {
+			return nil
+		}
+	}
+	if definedBy, ok := b.directiveSources[directive.Name]; ok && definedBy != source.Path {
+		return fmt.Errorf("directive @%s is defined by both %s and %s", directive.Name, definedBy, source.Path)
+	}
+	b.directiveSources[directive.Name] = source.Path
+	directive.IsClientOnly = source.Client
+	b.schema.Directives[directive.Name] = directive
+	return nil
+}
+
+func (b *schemaBuilder) addExtension(source config.SchemaSource, node parser.AST) {
+	b.extensions = append(b.extensions, node)
+	b.extensionSources = append(b.extensionSources, source)
+}
+
+// build applies the extensions and returns the merged schema
+func (b *schemaBuilder) build() (*Schema, error) {
+	for i, node := range b.extensions {
+		if err := b.applyExtension(b.extensionSources[i], node); err != nil {
+			return nil, err
+		}
+	}
+
+	// Without a schema definition the root types are the types named after the operations
+	if !b.explicitRoots {
+		for op, name := range defaultRootTypes {
+			if _, ok := b.rootTypes[op]; !ok {
+				b.rootTypes[op] = name
+			}
+		}
+	}
+
+	// Set the root types last, the pointers must see the extended definitions
+	for op, name := range b.rootTypes {
+		typeDef, ok := b.schema.Types[name]
+		if !ok {
+			continue
+		}
+		switch op {
+		case parser.Query:
+			b.schema.Query = &typeDef
+		case parser.Mutation:
+			b.schema.Mutation = &typeDef
+		case parser.Subscription:
+			b.schema.Subscription = &typeDef
+		}
+	}
+
+	// Validate that we have at least a Query type
+	if b.schema.Query == nil {
+		return nil, fmt.Errorf("schema must define a Query type")
+	}
+
+	return b.schema, nil
+}
+
+// applyExtension merges an extension into the schema. Fields added by an extension of another source
+// than the one that defined them are reported with both sources.
+func (b *schemaBuilder) applyExtension(source config.SchemaSource, node parser.AST) error {
+	var typeName string
+	var fields []parser.FieldDefinition
+	switch n := node.(type) {
+	case parser.TypeDefinition:
+		typeName, fields = n.Name, n.Fields
+	case parser.InterfaceTypeDefinition:
+		typeName, fields = n.Name, n.Fields
+	}
+	for _, f := range fields {
+		if definedBy, ok := b.fieldSources[typeName+"."+f.Name]; ok && definedBy != source.Path {
+			return fmt.Errorf("field %s.%s of %s is already defined by %s", typeName, f.Name, source.Path, definedBy)
+		}
+	}
+
+	if err := b.schema.applyExtension(node); err != nil {
+		if source.Path != "" {
+			return fmt.Errorf("%s: %w", source.Path, err)
+		}
+		return err
+	}
+
+	if len(fields) > 0 {
+		typeDef := b.schema.Types[typeName]
+		for i := range typeDef.Fields {
+			key := typeName + "." + typeDef.Fields[i].Name
+			if _, ok := b.fieldSources[key]; !ok {
+				b.fieldSources[key] = source.Path
+				typeDef.Fields[i].IsClientOnly = source.Client
+			}
+		}
+		b.schema.Types[typeName] = typeDef
+	}
+	return nil
+}
+
+// ServerSchema returns the schema without the types, fields and directives only known to client schemas,
+// which is the schema of the server
+func (s *Schema) ServerSchema() *Schema {
+	server := &Schema{
+		Types:      make(map[string]TypeDefinition, len(s.Types)),
+		Directives: make(map[string]DirectiveDefinition, len(s.Directives)),
+	}
+	for name, typeDef := range s.Types {
+		if typeDef.IsClientOnly {
+			continue
+		}
+		fields := typeDef.Fields[:0:0]
+		for _, field := range typeDef.Fields {
+			if !field.IsClientOnly {
+				fields = append(fields, field)
+			}
+		}
+		typeDef.Fields = fields
+		server.Types[name] = typeDef
+	}
+	for name, directive := range s.Directives {
+		if !directive.IsClientOnly {
+			server.Directives[name] = directive
+		}
+	}
+
+	rootType := func(root *TypeDefinition) *TypeDefinition {
+		if root == nil {
+			return nil
+		}
+		typeDef, ok := server.Types[root.Name]
+		if !ok {
+			return nil
+		}
+		return &typeDef
+	}
+	server.Query = rootType(s.Query)
+	server.Mutation = rootType(s.Mutation)
+	server.Subscription = rootType(s.Subscription)
+	return server
+}
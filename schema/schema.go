@@ -1,17 +1,15 @@
 package schema
 
 import (
-	"bytes"
-	"encoding/base64"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"gqlc/config"
 	"gqlc/fs"
 	"gqlc/parser"
 	"io"
-	"net/http"
 	"os"
-	"path/filepath"
+	"sort"
 	"strings"
 	"time"
 )
@@ -19,6 +17,7 @@ import (
 // Schema represents a GraphQL schema with all type definitions
 type Schema struct {
 	Types        map[string]TypeDefinition
+	Directives   map[string]DirectiveDefinition
 	Query        *TypeDefinition
 	Mutation     *TypeDefinition
 	Subscription *TypeDefinition
@@ -26,43 +25,88 @@ type Schema struct {
 
 // TypeDefinition represents a GraphQL type definition
 type TypeDefinition struct {
-	Name          string
-	Kind          string // OBJECT, INTERFACE, INPUT_OBJECT, ENUM, SCALAR, UNION
-	Description   *string
-	Fields        []FieldDefinition
-	InputFields   []InputValueDefinition
-	EnumValues    []EnumValueDefinition
-	Interfaces    []string
-	PossibleTypes []string
+	Name           string
+	Kind           string // OBJECT, INTERFACE, INPUT_OBJECT, ENUM, SCALAR, UNION
+	Description    *string
+	Fields         []FieldDefinition
+	InputFields    []InputValueDefinition
+	EnumValues     []EnumValueDefinition
+	Interfaces     []string
+	PossibleTypes  []string
+	SpecifiedByURL *string // Only for custom scalars
+	IsClientOnly   bool    // Defined by a client schema, the server doesn't know the type
+	// Directives applied to the type, without @specifiedBy. Only known for schemas from SDL.
+	Directives []parser.Directive
 }
 
 // FieldDefinition represents a field in an object or interface type
 type FieldDefinition struct {
-	Name        string
-	Description *string
-	Type        TypeRef
-	Args        []InputValueDefinition
+	Name              string
+	Description       *string
+	Type              TypeRef
+	Args              []InputValueDefinition
+	IsDeprecated      bool
+	DeprecationReason *string
+	IsClientOnly      bool               // Defined by a client schema, the server doesn't know the field
+	Directives        []parser.Directive // Applied directives without @deprecated, only known for schemas from SDL
 }
 
 // InputValueDefinition represents an input value (argument or input field)
 type InputValueDefinition struct {
-	Name         string
-	Description  *string
-	Type         TypeRef
-	DefaultValue *string
+	Name              string
+	Description       *string
+	Type              TypeRef
+	DefaultValue      *string
+	IsDeprecated      bool
+	DeprecationReason *string
+	Directives        []parser.Directive // Applied directives without @deprecated, only known for schemas from SDL
 }
 
 // EnumValueDefinition represents an enum value
 type EnumValueDefinition struct {
-	Name        string
-	Description *string
+	Name              string
+	Description       *string
+	IsDeprecated      bool
+	DeprecationReason *string
+	Directives        []parser.Directive // Applied directives without @deprecated, only known for schemas from SDL
+}
+
+// DirectiveDefinition represents a directive defined by the schema
+type DirectiveDefinition struct {
+	Name         string
+	Description  *string
+	Args         []InputValueDefinition
+	Locations    []string // e.g. FIELD_DEFINITION
+	IsRepeatable bool
+	IsClientOnly bool // Defined by a client schema, the server doesn't know the directive
 }
 
 // TypeRef represents a type reference
 type TypeRef struct {
-	Kind   string
-	Name   *string
-	OfType *TypeRef
+	Kind   string   `json:"kind"`
+	Name   *string  `json:"name"`
+	OfType *TypeRef `json:"ofType"`
+}
+
+// String returns the type reference in GraphQL notation, e.g. [String!]!
+func (t TypeRef) String() string {
+	switch t.Kind {
+	case "NON_NULL":
+		if t.OfType == nil {
+			return "!"
+		}
+		return t.OfType.String() + "!"
+	case "LIST":
+		if t.OfType == nil {
+			return "[]"
+		}
+		return "[" + t.OfType.String() + "]"
+	default:
+		if t.Name == nil {
+			return ""
+		}
+		return *t.Name
+	}
 }
 
 // GetType returns the type definition for the given name
@@ -134,32 +178,151 @@ func (s *Schema) IsUnion(name string) bool {
 	return false
 }
 
+// IsAbstract checks if a type is an interface or a union
+func (s *Schema) IsAbstract(name string) bool {
+	return s.IsInterface(name) || s.IsUnion(name)
+}
+
+// PossibleTypes returns the names of the object types a value of the given type can have at runtime
+func (s *Schema) PossibleTypes(name string) []string {
+	td, ok := s.Types[name]
+	if !ok {
+		return nil
+	}
+	switch td.Kind {
+	case "OBJECT":
+		return []string{name}
+	case "UNION":
+		return td.PossibleTypes
+	case "INTERFACE":
+		if len(td.PossibleTypes) > 0 {
+			return td.PossibleTypes
+		}
+		// Schemas loaded from SDL only know the interfaces an object implements
+		var possibleTypes []string
+		for _, candidate := range s.Types {
+			if candidate.Kind != "OBJECT" {
+				continue
+			}
+			for _, iface := range candidate.Interfaces {
+				if iface == name {
+					possibleTypes = append(possibleTypes, candidate.Name)
+					break
+				}
+			}
+		}
+		sort.Strings(possibleTypes)
+		return possibleTypes
+	default:
+		return nil
+	}
+}
+
+// DoesTypeApply checks if a fragment with the given type condition applies to the given object type
+func (s *Schema) DoesTypeApply(typeCondition string, objectType string) bool {
+	if typeCondition == objectType {
+		return true
+	}
+	for _, possibleType := range s.PossibleTypes(typeCondition) {
+		if possibleType == objectType {
+			return true
+		}
+	}
+	return false
+}
+
 // Generator interface for code generation
 type Generator interface {
 	Generate(schema *Schema, w io.Writer) error
 }
 
+// Load loads the schema sources of the config and merges them into one schema.
+// Syntax errors of all SDL sources are returned together.
 func Load(config config.Config) (*Schema, error) {
-	if strings.HasPrefix(config.Input.Schemas, "https://") || strings.HasPrefix(config.Input.Schemas, "http://") {
-		return loadSchemaFromWeb(config.Input.Schemas, config.Input.WebAuthorization)
+	ttl, err := config.Input.IntrospectionCacheTTL()
+	if err != nil {
+		return nil, err
+	}
+
+	b := newSchemaBuilder()
+	var syntaxErrors parser.Errors
+	for _, source := range config.Input.Schemas {
+		var s *Schema
+		switch {
+		case source.IsRemote():
+			s, err = loadSchemaFromWeb(source.Path, config.Input.WebAuthorization, ttl)
+		case strings.HasSuffix(strings.ToLower(source.Path), ".json"):
+			s, err = loadSchemaFromIntrospectionFile(source.Path)
+		default:
+			nodes, err := loadSchemaFromDisk(source.Path)
+			var sourceSyntaxErrors parser.Errors
+			if errors.As(err, &sourceSyntaxErrors) {
+				syntaxErrors = append(syntaxErrors, sourceSyntaxErrors...)
+				continue
+			}
+			if err != nil {
+				return nil, err
+			}
+			if err := b.addDefinitions(source, nodes); err != nil {
+				return nil, err
+			}
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		if err := b.addSchema(source, s); err != nil {
+			return nil, err
+		}
+	}
+	if len(syntaxErrors) > 0 {
+		return nil, syntaxErrors
 	}
-	return loadSchemaFromDisk(config.Input.Schemas)
+	return b.build()
 }
 
-func loadSchemaFromWeb(url string, authorization string) (*Schema, error) {
-	data, err := getIntrospection(url, authorization)
+func loadSchemaFromWeb(url string, authorization string, ttl time.Duration) (*Schema, error) {
+	data, err := getIntrospection(url, authorization, ttl)
 	if err != nil {
 		return nil, fmt.Errorf("failed to download schema introspection: %w", err)
 	}
 	var introspection introspectionResponse
-	if err := json.NewDecoder(data).Decode(&introspection); err != nil {
+	if err := json.Unmarshal(data, &introspection); err != nil {
 		return nil, fmt.Errorf("failed to parse schema introspection: %w", err)
 	}
 
 	return buildSchemaFromIntrospection(&introspection)
 }
 
-func loadSchemaFromDisk(path string) (*Schema, error) {
+// loadSchemaFromIntrospectionFile loads an introspection result saved as JSON file.
+// Both the response of the endpoint ({"data": {"__schema": ...}}) and the bare {"__schema": ...} are accepted.
+func loadSchemaFromIntrospectionFile(path string) (*Schema, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read schema introspection: %w", err)
+	}
+
+	var introspection introspectionResponse
+	if err := json.Unmarshal(data, &introspection); err != nil {
+		return nil, fmt.Errorf("failed to parse schema introspection %s: %w", path, err)
+	}
+	if len(introspection.Errors) > 0 {
+		return nil, fmt.Errorf("schema introspection %s contains errors: %w", path, introspection.queryError())
+	}
+	if introspection.Data.Schema.Types == nil {
+		if err := json.Unmarshal(data, &introspection.Data); err != nil {
+			return nil, fmt.Errorf("failed to parse schema introspection %s: %w", path, err)
+		}
+	}
+	if introspection.Data.Schema.Types == nil {
+		return nil, fmt.Errorf("schema introspection %s has no __schema", path)
+	}
+
+	return buildSchemaFromIntrospection(&introspection)
+}
+
+// loadSchemaFromDisk parses the SDL files of the path
+func loadSchemaFromDisk(path string) ([]parser.AST, error) {
 	// Collect all GraphQL files from the path
 	files, err := fs.CollectGraphQLFiles(path)
 	if err != nil {
@@ -175,24 +338,53 @@ func loadSchemaFromDisk(path string) (*Schema, error) {
 
 	// Parse all files to get AST nodes
 	var allNodes []parser.AST
+	var syntaxErrors parser.Errors
 	for _, file := range files {
-		ch := parser.Parse(file)
+		ch := parser.ParseFile(file.Name(), file)
 		for node := range ch {
-			// Filter out error nodes
-			if _, isErr := node.(parser.Error); !isErr {
-				allNodes = append(allNodes, node)
+			if err, isErr := node.(parser.Error); isErr {
+				syntaxErrors = append(syntaxErrors, err)
+				continue
 			}
+			allNodes = append(allNodes, node)
 		}
 	}
+	if len(syntaxErrors) > 0 {
+		return nil, syntaxErrors
+	}
 
-	// Build schema from AST nodes
-	return buildSchemaFromAST(allNodes)
+	return allNodes, nil
+}
+
+func convertIntrospectionInputValue(arg Arg) InputValueDefinition {
+	return InputValueDefinition{
+		Name:              arg.Name,
+		Description:       arg.Description,
+		Type:              convertTypeRef(arg.Type),
+		DefaultValue:      arg.DefaultValue,
+		IsDeprecated:      arg.IsDeprecated,
+		DeprecationReason: arg.DeprecationReason,
+	}
 }
 
 // buildSchemaFromIntrospection converts introspection response to Schema
 func buildSchemaFromIntrospection(intro *introspectionResponse) (*Schema, error) {
 	schema := &Schema{
-		Types: make(map[string]TypeDefinition),
+		Types:      make(map[string]TypeDefinition),
+		Directives: make(map[string]DirectiveDefinition),
+	}
+
+	for _, d := range intro.Data.Schema.Directives {
+		directive := DirectiveDefinition{
+			Name:         d.Name,
+			Description:  d.Description,
+			Locations:    d.Locations,
+			IsRepeatable: d.IsRepeatable,
+		}
+		for _, arg := range d.Args {
+			directive.Args = append(directive.Args, convertIntrospectionInputValue(arg))
+		}
+		schema.Directives[d.Name] = directive
 	}
 
 	for _, t := range intro.Data.Schema.Types {
@@ -201,42 +393,45 @@ func buildSchemaFromIntrospection(intro *introspectionResponse) (*Schema, error)
 		}
 
 		typeDef := TypeDefinition{
-			Name:        *t.Name,
-			Kind:        t.Kind,
-			Description: t.Description,
+			Name:           *t.Name,
+			Kind:           t.Kind,
+			Description:    t.Description,
+			SpecifiedByURL: t.SpecifiedByURL,
 		}
 
 		// Convert fields
 		for _, f := range t.Fields {
 			fieldDef := FieldDefinition{
-				Name:        f.Name,
-				Description: f.Description,
-				Type:        convertTypeRef(f.Type),
+				Name:              f.Name,
+				Description:       f.Description,
+				Type:              convertTypeRef(f.Type),
+				IsDeprecated:      f.IsDeprecated,
+				DeprecationReason: f.DeprecationReason,
 			}
 			// Convert args
 			for _, arg := range f.Args {
-				fieldDef.Args = append(fieldDef.Args, InputValueDefinition{
-					Name: arg.Name,
-					Type: convertTypeRef(arg.Type),
-				})
+				fieldDef.Args = append(fieldDef.Args, convertIntrospectionInputValue(arg))
 			}
 			typeDef.Fields = append(typeDef.Fields, fieldDef)
 		}
 
 		// Convert input fields
 		for _, inputField := range t.InputFields {
-			typeDef.InputFields = append(typeDef.InputFields, InputValueDefinition{
-				Name:        inputField.Name,
-				Description: inputField.Description,
-				Type:        convertTypeRef(inputField.Type),
-			})
+			typeDef.InputFields = append(typeDef.InputFields, convertIntrospectionInputValue(Arg(inputField)))
+		}
+
+		// Convert interfaces
+		for _, i := range t.Interfaces {
+			typeDef.Interfaces = append(typeDef.Interfaces, i.Name)
 		}
 
 		// Convert enum values
 		for _, enumVal := range t.EnumValues {
 			typeDef.EnumValues = append(typeDef.EnumValues, EnumValueDefinition{
-				Name:        enumVal.Name,
-				Description: enumVal.Description,
+				Name:              enumVal.Name,
+				Description:       enumVal.Description,
+				IsDeprecated:      enumVal.IsDeprecated,
+				DeprecationReason: enumVal.DeprecationReason,
 			})
 		}
 
@@ -277,113 +472,214 @@ func convertTypeRef(ref TypeRef) TypeRef {
 
 // buildSchemaFromAST converts AST nodes to Schema
 func buildSchemaFromAST(nodes []parser.AST) (*Schema, error) {
-	schema := &Schema{
-		Types: make(map[string]TypeDefinition),
+	b := newSchemaBuilder()
+	if err := b.addDefinitions(config.SchemaSource{}, nodes); err != nil {
+		return nil, err
+	}
+	return b.build()
+}
+
+// applyExtension merges a type extension into the definition it extends
+func (s *Schema) applyExtension(node parser.AST) error {
+	var name, kind string
+	switch n := node.(type) {
+	case parser.TypeDefinition:
+		name, kind = n.Name, "OBJECT"
+	case parser.InputTypeDefinition:
+		name, kind = n.Name, "INPUT_OBJECT"
+	case parser.EnumTypeDefinition:
+		name, kind = n.Name, "ENUM"
+	case parser.ScalarTypeDefinition:
+		name, kind = n.Name, "SCALAR"
+	case parser.InterfaceTypeDefinition:
+		name, kind = n.Name, "INTERFACE"
+	case parser.UnionTypeDefinition:
+		name, kind = n.Name, "UNION"
 	}
 
-	// Add default scalar types
-	addDefaultScalarTypes(schema)
+	typeDef, ok := s.Types[name]
+	if !ok {
+		return fmt.Errorf("cannot extend unknown type %s", name)
+	}
+	if typeDef.Kind != kind {
+		return fmt.Errorf("cannot extend %s %s as %s", strings.ToLower(typeDef.Kind), name, strings.ToLower(kind))
+	}
 
-	for _, node := range nodes {
-		switch n := node.(type) {
-		case parser.TypeDefinition:
-			typeDef := TypeDefinition{
-				Name:        n.Name,
-				Kind:        "OBJECT",
-				Description: nil, // TODO: extract from comments if available
-				Interfaces:  n.Interfaces,
-			}
-			// Convert fields
-			for _, f := range n.Fields {
-				fieldDef := FieldDefinition{
-					Name: f.Name,
-					Type: convertASTType(f.Type),
-				}
-				// Convert args
-				for _, arg := range f.Arguments {
-					fieldDef.Args = append(fieldDef.Args, InputValueDefinition{
-						Name: arg.Name,
-						Type: convertASTType(arg.Type),
-					})
+	switch n := node.(type) {
+	case parser.TypeDefinition:
+		typeDef.Interfaces = append(typeDef.Interfaces, n.Interfaces...)
+		fields, err := extendFields(name, typeDef.Fields, n.Fields)
+		if err != nil {
+			return err
+		}
+		typeDef.Fields = fields
+	case parser.InterfaceTypeDefinition:
+		typeDef.Interfaces = append(typeDef.Interfaces, n.Interfaces...)
+		fields, err := extendFields(name, typeDef.Fields, n.Fields)
+		if err != nil {
+			return err
+		}
+		typeDef.Fields = fields
+	case parser.InputTypeDefinition:
+		for _, f := range convertASTInputValues(n.Fields) {
+			for _, existing := range typeDef.InputFields {
+				if existing.Name == f.Name {
+					return fmt.Errorf("input field %s.%s is already defined", name, f.Name)
 				}
-				typeDef.Fields = append(typeDef.Fields, fieldDef)
-			}
-			schema.Types[n.Name] = typeDef
-
-			// Check if this is a root type
-			if n.Name == "Query" {
-				schema.Query = &typeDef
-			} else if n.Name == "Mutation" {
-				schema.Mutation = &typeDef
-			} else if n.Name == "Subscription" {
-				schema.Subscription = &typeDef
 			}
-		case parser.InputTypeDefinition:
-			typeDef := TypeDefinition{
-				Name: n.Name,
-				Kind: "INPUT_OBJECT",
-			}
-			for _, f := range n.Fields {
-				typeDef.InputFields = append(typeDef.InputFields, InputValueDefinition{
-					Name: f.Name,
-					Type: convertASTType(f.Type),
-				})
-			}
-			schema.Types[n.Name] = typeDef
-		case parser.EnumTypeDefinition:
-			typeDef := TypeDefinition{
-				Name: n.Name,
-				Kind: "ENUM",
-			}
-			for _, v := range n.Values {
-				typeDef.EnumValues = append(typeDef.EnumValues, EnumValueDefinition{
-					Name: v.Name,
-				})
-			}
-			schema.Types[n.Name] = typeDef
-		case parser.ScalarTypeDefinition:
-			typeDef := TypeDefinition{
-				Name: n.Name,
-				Kind: "SCALAR",
-			}
-			schema.Types[n.Name] = typeDef
-		case parser.InterfaceTypeDefinition:
-			typeDef := TypeDefinition{
-				Name: n.Name,
-				Kind: "INTERFACE",
-			}
-			for _, f := range n.Fields {
-				fieldDef := FieldDefinition{
-					Name: f.Name,
-					Type: convertASTType(f.Type),
-				}
-				for _, arg := range f.Arguments {
-					fieldDef.Args = append(fieldDef.Args, InputValueDefinition{
-						Name: arg.Name,
-						Type: convertASTType(arg.Type),
-					})
+			typeDef.InputFields = append(typeDef.InputFields, f)
+		}
+	case parser.EnumTypeDefinition:
+		for _, v := range convertASTEnumValues(n.Values) {
+			for _, existing := range typeDef.EnumValues {
+				if existing.Name == v.Name {
+					return fmt.Errorf("enum value %s.%s is already defined", name, v.Name)
 				}
-				typeDef.Fields = append(typeDef.Fields, fieldDef)
 			}
-			schema.Types[n.Name] = typeDef
-		case parser.UnionTypeDefinition:
-			typeDef := TypeDefinition{
-				Name: n.Name,
-				Kind: "UNION",
+			typeDef.EnumValues = append(typeDef.EnumValues, v)
+		}
+	case parser.UnionTypeDefinition:
+		typeDef.PossibleTypes = append(typeDef.PossibleTypes, n.Types...)
+	case parser.ScalarTypeDefinition:
+		if url := specifiedByURL(n.Directives); url != nil {
+			typeDef.SpecifiedByURL = url
+		}
+	}
+	typeDef.Directives = append(typeDef.Directives, appliedDirectives(extensionDirectives(node))...)
+
+	s.Types[name] = typeDef
+	return nil
+}
+
+// extensionDirectives returns the directives an extension applies to the type
+func extensionDirectives(node parser.AST) []parser.Directive {
+	switch n := node.(type) {
+	case parser.TypeDefinition:
+		return n.Directives
+	case parser.InterfaceTypeDefinition:
+		return n.Directives
+	case parser.InputTypeDefinition:
+		return n.Directives
+	case parser.EnumTypeDefinition:
+		return n.Directives
+	case parser.UnionTypeDefinition:
+		return n.Directives
+	case parser.ScalarTypeDefinition:
+		return n.Directives
+	}
+	return nil
+}
+
+// extendFields appends the fields of an extension, fields may not be redefined
+func extendFields(typeName string, fields []FieldDefinition, extension []parser.FieldDefinition) ([]FieldDefinition, error) {
+	for _, f := range convertASTFields(extension) {
+		for _, existing := range fields {
+			if existing.Name == f.Name {
+				return nil, fmt.Errorf("field %s.%s is already defined", typeName, f.Name)
 			}
-			typeDef.PossibleTypes = append(typeDef.PossibleTypes, n.Types...)
-			schema.Types[n.Name] = typeDef
-		default:
-			fmt.Printf("Unexpected node type: %T\n", node)
 		}
+		fields = append(fields, f)
+	}
+	return fields, nil
+}
+
+// convertASTFields converts field definitions of an object or interface type
+func convertASTFields(fields []parser.FieldDefinition) []FieldDefinition {
+	var result []FieldDefinition
+	for _, f := range fields {
+		isDeprecated, reason := deprecation(f.Directives)
+		result = append(result, FieldDefinition{
+			Name:              f.Name,
+			Description:       f.Description,
+			Type:              convertASTType(f.Type),
+			Args:              convertASTInputValues(f.Arguments),
+			IsDeprecated:      isDeprecated,
+			DeprecationReason: reason,
+			Directives:        appliedDirectives(f.Directives),
+		})
+	}
+	return result
+}
+
+// convertASTInputValues converts arguments or input fields
+func convertASTInputValues(values []parser.InputValueDefinition) []InputValueDefinition {
+	var result []InputValueDefinition
+	for _, v := range values {
+		isDeprecated, reason := deprecation(v.Directives)
+		result = append(result, InputValueDefinition{
+			Name:              v.Name,
+			Description:       v.Description,
+			Type:              convertASTType(v.Type),
+			DefaultValue:      convertASTValue(v.DefaultValue),
+			IsDeprecated:      isDeprecated,
+			DeprecationReason: reason,
+			Directives:        appliedDirectives(v.Directives),
+		})
 	}
+	return result
+}
 
-	// Validate that we have at least a Query type
-	if schema.Query == nil {
-		return nil, fmt.Errorf("schema must define a Query type")
+// convertASTEnumValues converts the values of an enum type
+func convertASTEnumValues(values []parser.EnumValueDefinition) []EnumValueDefinition {
+	var result []EnumValueDefinition
+	for _, v := range values {
+		isDeprecated, reason := deprecation(v.Directives)
+		result = append(result, EnumValueDefinition{
+			Name:              v.Name,
+			Description:       v.Description,
+			IsDeprecated:      isDeprecated,
+			DeprecationReason: reason,
+			Directives:        appliedDirectives(v.Directives),
+		})
 	}
+	return result
+}
 
-	return schema, nil
+// appliedDirectives returns the directives without @deprecated and @specifiedBy, which are kept as fields
+func appliedDirectives(directives []parser.Directive) []parser.Directive {
+	var result []parser.Directive
+	for _, d := range directives {
+		if d.Name != "deprecated" && d.Name != "specifiedBy" {
+			result = append(result, d)
+		}
+	}
+	return result
+}
+
+// specifiedByURL returns the url of a @specifiedBy directive
+func specifiedByURL(directives []parser.Directive) *string {
+	for _, d := range directives {
+		if d.Name != "specifiedBy" {
+			continue
+		}
+		for _, arg := range d.Arguments {
+			if value, ok := arg.Value.(parser.StringValue); ok && arg.Name == "url" {
+				url := value.Text()
+				return &url
+			}
+		}
+	}
+	return nil
+}
+
+// defaultDeprecationReason is the reason of a @deprecated directive without arguments
+const defaultDeprecationReason = "No longer supported"
+
+// deprecation returns whether the directives contain @deprecated and its reason
+func deprecation(directives []parser.Directive) (bool, *string) {
+	for _, d := range directives {
+		if d.Name != "deprecated" {
+			continue
+		}
+		reason := defaultDeprecationReason
+		for _, arg := range d.Arguments {
+			if value, ok := arg.Value.(parser.StringValue); ok && arg.Name == "reason" {
+				reason = value.Text()
+			}
+		}
+		return true, &reason
+	}
+	return false, nil
 }
 
 // addDefaultScalarTypes adds the built-in GraphQL scalar types to the schema
@@ -397,6 +693,15 @@ func addDefaultScalarTypes(schema *Schema) {
 	}
 }
 
+// convertASTValue converts a default value to its GraphQL notation
+func convertASTValue(v *parser.Value) *string {
+	if v == nil {
+		return nil
+	}
+	value := (*v).String()
+	return &value
+}
+
 // convertASTType converts parser.Type to TypeRef
 func convertASTType(t parser.Type) TypeRef {
 	switch typ := t.(type) {
@@ -422,124 +727,171 @@ func convertASTType(t parser.Type) TypeRef {
 	}
 }
 
-const introspectionQuery = `{ __schema { queryType { name } mutationType { name } subscriptionType { name } types { kind name description fields(includeDeprecated: true) { name description args { name type { kind name ofType { kind name ofType { kind name } } } } type { kind name ofType { kind name ofType { kind name } } } } inputFields { name description type { kind name ofType { kind name } } } enumValues(includeDeprecated: true) { name description } possibleTypes { name } } } }`
+// introspectionQuery fetches the whole schema. Type references are nested seven levels deep,
+// which covers any type a schema realistically uses, e.g. [[Int!]!]!
+const introspectionQuery = `query IntrospectionQuery {
+  __schema {
+    queryType { name }
+    mutationType { name }
+    subscriptionType { name }
+    types { ...FullType }
+    directives {
+      name
+      description
+      isRepeatable
+      locations
+      args(includeDeprecated: true) { ...InputValue }
+    }
+  }
+}
+
+fragment FullType on __Type {
+  kind
+  name
+  description
+  specifiedByURL
+  fields(includeDeprecated: true) {
+    name
+    description
+    args(includeDeprecated: true) { ...InputValue }
+    type { ...TypeRef }
+    isDeprecated
+    deprecationReason
+  }
+  inputFields(includeDeprecated: true) { ...InputValue }
+  interfaces { ...TypeRef }
+  enumValues(includeDeprecated: true) {
+    name
+    description
+    isDeprecated
+    deprecationReason
+  }
+  possibleTypes { ...TypeRef }
+}
+
+fragment InputValue on __InputValue {
+  name
+  description
+  type { ...TypeRef }
+  defaultValue
+  isDeprecated
+  deprecationReason
+}
+
+fragment TypeRef on __Type {
+  kind
+  name
+  ofType {
+    kind
+    name
+    ofType {
+      kind
+      name
+      ofType {
+        kind
+        name
+        ofType {
+          kind
+          name
+          ofType {
+            kind
+            name
+            ofType {
+              kind
+              name
+              ofType {
+                kind
+                name
+              }
+            }
+          }
+        }
+      }
+    }
+  }
+}`
+
+// legacyIntrospectionQuery is used for servers that reject introspectionQuery because they implement a version
+// of the specification from before specifiedByURL, isRepeatable and deprecated arguments and input fields
+var legacyIntrospectionQuery = strings.NewReplacer(
+	"\n  specifiedByURL", "",
+	"\n      isRepeatable", "",
+	"(includeDeprecated: true) { ...InputValue }", " { ...InputValue }",
+	"\n  defaultValue\n  isDeprecated\n  deprecationReason", "\n  defaultValue",
+).Replace(introspectionQuery)
 
 // Introspection types for JSON unmarshaling
 type introspectionData struct {
 	Schema struct {
-		QueryType        struct{ Name string }  `json:"queryType"`
-		MutationType     *struct{ Name string } `json:"mutationType"`
-		SubscriptionType *struct{ Name string } `json:"subscriptionType"`
-		Types            []IntrospectionType    `json:"types"`
+		QueryType        PossibleType             `json:"queryType"`
+		MutationType     *PossibleType            `json:"mutationType"`
+		SubscriptionType *PossibleType            `json:"subscriptionType"`
+		Types            []IntrospectionType      `json:"types"`
+		Directives       []IntrospectionDirective `json:"directives"`
 	} `json:"__schema"`
 }
 
 type introspectionResponse struct {
-	Data introspectionData `json:"data"`
+	Data   introspectionData `json:"data"`
+	Errors []struct {
+		Message string `json:"message"`
+	} `json:"errors"`
 }
 
 type IntrospectionType struct {
-	Kind          string         `json:"kind"`
-	Name          *string        `json:"name"`
-	Description   *string        `json:"description"`
-	Fields        []Field        `json:"fields"`
-	InputFields   []InputField   `json:"inputFields"`
-	EnumValues    []EnumValue    `json:"enumValues"`
-	PossibleTypes []PossibleType `json:"possibleTypes"`
+	Kind           string         `json:"kind"`
+	Name           *string        `json:"name"`
+	Description    *string        `json:"description"`
+	SpecifiedByURL *string        `json:"specifiedByURL"`
+	Fields         []Field        `json:"fields"`
+	InputFields    []InputField   `json:"inputFields"`
+	Interfaces     []PossibleType `json:"interfaces"`
+	EnumValues     []EnumValue    `json:"enumValues"`
+	PossibleTypes  []PossibleType `json:"possibleTypes"`
+}
+
+type IntrospectionDirective struct {
+	Name         string   `json:"name"`
+	Description  *string  `json:"description"`
+	IsRepeatable bool     `json:"isRepeatable"`
+	Locations    []string `json:"locations"`
+	Args         []Arg    `json:"args"`
 }
 
 type Field struct {
-	Name        string  `json:"name"`
-	Description *string `json:"description"`
-	Args        []Arg   `json:"args"`
-	Type        TypeRef `json:"type"`
+	Name              string  `json:"name"`
+	Description       *string `json:"description"`
+	Args              []Arg   `json:"args"`
+	Type              TypeRef `json:"type"`
+	IsDeprecated      bool    `json:"isDeprecated"`
+	DeprecationReason *string `json:"deprecationReason"`
 }
 
 type Arg struct {
-	Name string  `json:"name"`
-	Type TypeRef `json:"type"`
+	Name              string  `json:"name"`
+	Description       *string `json:"description"`
+	Type              TypeRef `json:"type"`
+	DefaultValue      *string `json:"defaultValue"`
+	IsDeprecated      bool    `json:"isDeprecated"`
+	DeprecationReason *string `json:"deprecationReason"`
 }
 
 type InputField struct {
-	Name        string  `json:"name"`
-	Description *string `json:"description"`
-	Type        TypeRef `json:"type"`
+	Name              string  `json:"name"`
+	Description       *string `json:"description"`
+	Type              TypeRef `json:"type"`
+	DefaultValue      *string `json:"defaultValue"`
+	IsDeprecated      bool    `json:"isDeprecated"`
+	DeprecationReason *string `json:"deprecationReason"`
 }
 
 type EnumValue struct {
-	Name        string  `json:"name"`
-	Description *string `json:"description"`
+	Name              string  `json:"name"`
+	Description       *string `json:"description"`
+	IsDeprecated      bool    `json:"isDeprecated"`
+	DeprecationReason *string `json:"deprecationReason"`
 }
 
 type PossibleType struct {
 	Name string `json:"name"`
 }
-
-func getIntrospection(endpoint string, authorization string) (io.ReadCloser, error) {
-	if !isInIntrospectionCache(endpoint) {
-		if err := downloadIntrospection(endpoint, authorization); err != nil {
-			return nil, err
-		}
-	}
-	return loadFromIntrospectionCache(endpoint)
-}
-
-func downloadIntrospection(endpoint string, authorization string) error {
-	payload := map[string]string{"query": introspectionQuery}
-	body, err := json.Marshal(payload)
-	if err != nil {
-		return fmt.Errorf("failed to marshal introspection query: %w", err)
-	}
-
-	client := &http.Client{Timeout: 30 * time.Second}
-	req, err := http.NewRequest("POST", endpoint, bytes.NewReader(body))
-	if err != nil {
-		return fmt.Errorf("failed to create introspection request: %w", err)
-	}
-	req.Header.Set("Content-Type", "application/json")
-	if len(authorization) > 0 {
-		req.Header.Set("Authorization", authorization)
-	}
-
-	resp, err := client.Do(req)
-	if err != nil {
-		return fmt.Errorf("failed to send introspection request: %w", err)
-	}
-	defer resp.Body.Close()
-
-	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
-		b, _ := io.ReadAll(resp.Body)
-		return fmt.Errorf("non-2xx status %d: %s", resp.StatusCode, string(b))
-	}
-
-	return storeInIntrospectionCache(resp.Body, endpoint)
-}
-
-func introspectionCacheLocation(origin string) string {
-	dir := filepath.Join(cacheLocation(), "introspection")
-	_ = os.MkdirAll(dir, 0755)
-	return filepath.Join(dir, base64.StdEncoding.EncodeToString([]byte(origin))+".json")
-}
-
-func storeInIntrospectionCache(r io.Reader, origin string) error {
-	file, err := os.Create(introspectionCacheLocation(origin))
-	if err != nil {
-		return fmt.Errorf("failed to create introspection cache file: %w", err)
-	}
-	defer file.Close()
-	_, err = io.Copy(file, r)
-	return err
-}
-
-func isInIntrospectionCache(origin string) bool {
-	_, err := os.Stat(introspectionCacheLocation(origin))
-	return err == nil
-}
-
-func loadFromIntrospectionCache(origin string) (io.ReadCloser, error) {
-	file, err := os.Open(introspectionCacheLocation(origin))
-	if err != nil {
-		return nil, fmt.Errorf("failed to open introspection cache file: %w", err)
-	}
-	return file, nil
-}
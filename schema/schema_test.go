@@ -0,0 +1,557 @@
+package schema
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/json"
+	"gqlc/config"
+	"gqlc/parser"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestBuildSchemaFromAST_MergesSchemaAndTypeExtensions(t *testing.T) {
+	s := mustBuildSchema(t, `schema {
+  query: RootQuery
+}
+
+directive @auth(requires: Role = ADMIN) on FIELD_DEFINITION | OBJECT
+
+extend schema {
+  mutation: RootMutation
+}
+
+extend type RootQuery {
+  me: User
+}
+
+type RootQuery {
+  user(id: ID!): User
+}
+
+type RootMutation {
+  logout: Boolean
+}
+
+interface Node {
+  id: ID!
+}
+
+type User {
+  name: String
+}
+
+extend type User implements Node {
+  id: ID!
+}
+
+enum Role {
+  USER
+}
+
+extend enum Role {
+  ADMIN
+}
+
+input Filter {
+  role: Role
+}
+
+extend input Filter {
+  name: String
+}
+
+type Bot {
+  id: ID!
+}
+
+union Actor = User
+
+extend union Actor = Bot
+`)
+
+	if s.Query == nil || s.Query.Name != "RootQuery" {
+		t.Fatalf("expected RootQuery as query type, got %+v", s.Query)
+	}
+	if len(s.Query.Fields) != 2 {
+		t.Errorf("expected query type to include extension fields, got %+v", s.Query.Fields)
+	}
+	if s.Mutation == nil || s.Mutation.Name != "RootMutation" {
+		t.Errorf("expected RootMutation as mutation type, got %+v", s.Mutation)
+	}
+	if s.Subscription != nil {
+		t.Errorf("expected no subscription type, got %+v", s.Subscription)
+	}
+
+	user := s.Types["User"]
+	if len(user.Fields) != 2 || len(user.Interfaces) != 1 || user.Interfaces[0] != "Node" {
+		t.Errorf("expected User to be extended with id and Node, got %+v", user)
+	}
+	if values := s.Types["Role"].EnumValues; len(values) != 2 || values[1].Name != "ADMIN" {
+		t.Errorf("expected Role to be extended with ADMIN, got %+v", values)
+	}
+	if fields := s.Types["Filter"].InputFields; len(fields) != 2 || fields[1].Name != "name" {
+		t.Errorf("expected Filter to be extended with name, got %+v", fields)
+	}
+	if types := s.Types["Actor"].PossibleTypes; len(types) != 2 || types[1] != "Bot" {
+		t.Errorf("expected Actor to be extended with Bot, got %+v", types)
+	}
+
+	auth, ok := s.Directives["auth"]
+	if !ok {
+		t.Fatalf("expected directive @auth to be recorded")
+	}
+	if len(auth.Args) != 1 || auth.Args[0].DefaultValue == nil || *auth.Args[0].DefaultValue != "ADMIN" {
+		t.Errorf("expected @auth(requires: Role = ADMIN), got %+v", auth.Args)
+	}
+	if strings.Join(auth.Locations, "|") != "FIELD_DEFINITION|OBJECT" {
+		t.Errorf("expected @auth locations FIELD_DEFINITION|OBJECT, got %v", auth.Locations)
+	}
+}
+
+func TestBuildSchemaFromAST_UsesOnlyDeclaredRootTypes(t *testing.T) {
+	s := mustBuildSchema(t, `schema {
+  query: Root
+}
+
+type Root {
+  a: Int
+}
+
+type Mutation {
+  b: Int
+}
+
+type Subscription {
+  c: Int
+}`)
+
+	if s.Query == nil || s.Query.Name != "Root" {
+		t.Fatalf("expected Root as query type, got %+v", s.Query)
+	}
+	if s.Mutation != nil || s.Subscription != nil {
+		t.Errorf("expected no mutation and subscription type, got %+v and %+v", s.Mutation, s.Subscription)
+	}
+
+	var buf bytes.Buffer
+	if err := s.WriteSDL(&buf); err != nil {
+		t.Fatalf("WriteSDL returned error: %v", err)
+	}
+	if !strings.HasPrefix(buf.String(), "schema {\n  query: Root\n}\n") {
+		t.Errorf("expected only the query root in the schema definition, got:\n%s", buf.String())
+	}
+
+	// Types named like the operations are still the root types without schema definition
+	s = mustBuildSchema(t, "type Query {\n  a: Int\n}\n\ntype Mutation {\n  b: Int\n}\n\nextend schema {\n  subscription: Events\n}\n\ntype Events {\n  c: Int\n}")
+	if s.Mutation == nil || s.Mutation.Name != "Mutation" || s.Subscription == nil || s.Subscription.Name != "Events" {
+		t.Errorf("expected Mutation and Events as root types, got %+v and %+v", s.Mutation, s.Subscription)
+	}
+
+	// A type with a default name which isn't a root type keeps the schema definition
+	s = mustBuildSchema(t, "schema {\n  query: Query\n}\n\ntype Query {\n  a: Int\n}\n\ntype Mutation {\n  b: Int\n}")
+	buf.Reset()
+	if err := s.WriteSDL(&buf); err != nil {
+		t.Fatalf("WriteSDL returned error: %v", err)
+	}
+	if !strings.HasPrefix(buf.String(), "schema {\n  query: Query\n}\n") {
+		t.Errorf("expected the schema definition to be printed, got:\n%s", buf.String())
+	}
+}
+
+func TestBuildSchemaFromAST_RejectsInvalidExtensions(t *testing.T) {
+	for sdl, expected := range map[string]string{
+		"type Query { a: Int }\nextend type Missing { b: Int }":               "cannot extend unknown type Missing",
+		"type Query { a: Int }\nextend type Query { a: Int }":                 "field Query.a is already defined",
+		"type Query { a: Int }\nenum Role { A }\nextend type Role { b: Int }": "cannot extend enum Role as object",
+	} {
+		_, err := buildSchemaFromAST(mustParse(t, sdl))
+		if err == nil || err.Error() != expected {
+			t.Errorf("expected error %q, got %v", expected, err)
+		}
+	}
+}
+
+const introspectionFixture = `{"data":{"__schema":{
+  "queryType":{"name":"Query"},"mutationType":null,"subscriptionType":null,
+  "types":[
+    {"kind":"OBJECT","name":"Query","fields":[
+      {"name":"matrix","args":[
+        {"name":"filter","type":{"kind":"INPUT_OBJECT","name":"Filter"},"defaultValue":"{limit: 10}"}
+      ],"type":{"kind":"NON_NULL","ofType":{"kind":"LIST","ofType":{"kind":"NON_NULL","ofType":{"kind":"LIST","ofType":{"kind":"NON_NULL","ofType":{"kind":"SCALAR","name":"Int"}}}}}}},
+      {"name":"node","args":[],"type":{"kind":"INTERFACE","name":"Node"}}
+    ],"interfaces":[]},
+    {"kind":"INTERFACE","name":"Node","fields":[{"name":"id","args":[],"type":{"kind":"NON_NULL","ofType":{"kind":"SCALAR","name":"ID"}}}],"possibleTypes":[{"kind":"OBJECT","name":"User"}]},
+    {"kind":"OBJECT","name":"User","fields":[{"name":"id","args":[],"type":{"kind":"NON_NULL","ofType":{"kind":"SCALAR","name":"ID"}}}],"interfaces":[{"kind":"INTERFACE","name":"Node"}]},
+    {"kind":"INPUT_OBJECT","name":"Filter","inputFields":[
+      {"name":"limit","type":{"kind":"SCALAR","name":"Int"},"defaultValue":"20"},
+      {"name":"ids","type":{"kind":"NON_NULL","ofType":{"kind":"LIST","ofType":{"kind":"NON_NULL","ofType":{"kind":"SCALAR","name":"ID"}}}}}
+    ]},
+    {"kind":"SCALAR","name":"UUID","specifiedByURL":"https://tools.ietf.org/html/rfc4122"}
+  ],
+  "directives":[
+    {"name":"cached","isRepeatable":true,"locations":["FIELD","QUERY"],"args":[{"name":"ttl","type":{"kind":"SCALAR","name":"Int"},"defaultValue":"60"}]}
+  ]
+}}}`
+
+func TestLoad_FromIntrospection(t *testing.T) {
+	for _, legacy := range []bool{false, true} {
+		t.Setenv("XDG_CACHE_HOME", t.TempDir())
+		t.Setenv("LOCALAPPDATA", t.TempDir())
+
+		var queries []string
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			var body struct{ Query string }
+			_ = json.NewDecoder(r.Body).Decode(&body)
+			queries = append(queries, body.Query)
+			if legacy && strings.Contains(body.Query, "specifiedByURL") {
+				_, _ = w.Write([]byte(`{"errors":[{"message":"Cannot query field \"specifiedByURL\" on type \"__Type\"."}]}`))
+				return
+			}
+			_, _ = w.Write([]byte(introspectionFixture))
+		}))
+
+		s, err := Load(config.Config{Input: config.Input{Schemas: config.SchemaSources{{Path: server.URL}}}})
+		server.Close()
+		if err != nil {
+			t.Fatalf("Load returned error: %v", err)
+		}
+
+		if expected := map[bool]int{false: 1, true: 2}[legacy]; len(queries) != expected {
+			t.Errorf("expected %d introspection requests, got %d", expected, len(queries))
+		}
+		if legacy && strings.Contains(queries[len(queries)-1], "specifiedByURL") {
+			t.Errorf("expected the legacy introspection query as fallback")
+		}
+
+		matrix := s.Query.Fields[0]
+		if matrix.Type.String() != "[[Int!]!]!" {
+			t.Errorf("expected matrix of type [[Int!]!]!, got %s", matrix.Type)
+		}
+		if arg := matrix.Args[0]; arg.DefaultValue == nil || *arg.DefaultValue != "{limit: 10}" {
+			t.Errorf("expected default value of argument filter, got %+v", arg)
+		}
+		filter := s.Types["Filter"]
+		if filter.InputFields[0].DefaultValue == nil || *filter.InputFields[0].DefaultValue != "20" {
+			t.Errorf("expected default value of input field limit, got %+v", filter.InputFields[0])
+		}
+		if filter.InputFields[1].Type.String() != "[ID!]!" {
+			t.Errorf("expected input field ids of type [ID!]!, got %s", filter.InputFields[1].Type)
+		}
+		if interfaces := s.Types["User"].Interfaces; len(interfaces) != 1 || interfaces[0] != "Node" {
+			t.Errorf("expected User to implement Node, got %v", interfaces)
+		}
+		if url := s.Types["UUID"].SpecifiedByURL; url == nil || *url != "https://tools.ietf.org/html/rfc4122" {
+			t.Errorf("expected specifiedByURL of UUID, got %v", url)
+		}
+		if cached, ok := s.Directives["cached"]; !ok || !cached.IsRepeatable || len(cached.Locations) != 2 || *cached.Args[0].DefaultValue != "60" {
+			t.Errorf("expected directive @cached, got %+v", cached)
+		}
+	}
+}
+
+func TestLoad_FromIntrospectionFile(t *testing.T) {
+	bare := strings.TrimSuffix(strings.TrimPrefix(introspectionFixture, `{"data":`), "}")
+	for name, content := range map[string]string{"response.json": introspectionFixture, "bare.JSON": bare} {
+		path := filepath.Join(t.TempDir(), name)
+		if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+
+		s, err := Load(config.Config{Input: config.Input{Schemas: config.SchemaSources{{Path: path}}}})
+		if err != nil {
+			t.Fatalf("Load(%s) returned error: %v", name, err)
+		}
+		if s.Query == nil || s.Query.Name != "Query" || len(s.Query.Fields) != 2 {
+			t.Errorf("expected query type of %s, got %+v", name, s.Query)
+		}
+		if interfaces := s.Types["User"].Interfaces; len(interfaces) != 1 || interfaces[0] != "Node" {
+			t.Errorf("expected User of %s to implement Node, got %v", name, interfaces)
+		}
+	}
+
+	path := filepath.Join(t.TempDir(), "schema.json")
+	if err := os.WriteFile(path, []byte(`{"data":null}`), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := Load(config.Config{Input: config.Input{Schemas: config.SchemaSources{{Path: path}}}}); err == nil || !strings.Contains(err.Error(), "has no __schema") {
+		t.Errorf("expected an error for an introspection file without __schema, got %v", err)
+	}
+}
+
+func TestLoad_RevalidatesCachedIntrospection(t *testing.T) {
+	t.Setenv("XDG_CACHE_HOME", t.TempDir())
+	t.Setenv("LOCALAPPDATA", t.TempDir())
+
+	var requests, notModified int
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests++
+		if r.Header.Get("If-None-Match") == `"v1"` {
+			notModified++
+			w.WriteHeader(http.StatusNotModified)
+			return
+		}
+		w.Header().Set("ETag", `"v1"`)
+		_, _ = w.Write([]byte(introspectionFixture))
+	}))
+	defer server.Close()
+
+	cfg := config.Config{Input: config.Input{Schemas: config.SchemaSources{{Path: server.URL}}}}
+	for range 2 {
+		if _, err := Load(cfg); err != nil {
+			t.Fatalf("Load returned error: %v", err)
+		}
+	}
+	if requests != 1 {
+		t.Errorf("expected the cached introspection to be used within the TTL, got %d requests", requests)
+	}
+
+	cfg.Input.CacheTTL = "0s"
+	if _, err := Load(cfg); err != nil {
+		t.Fatalf("Load returned error: %v", err)
+	}
+	if updated, err := FetchIntrospection(cfg.Input, server.URL); err != nil || updated {
+		t.Errorf("expected FetchIntrospection to report an up to date cache, got %v, %v", updated, err)
+	}
+	if notModified != 2 {
+		t.Errorf("expected 2 revalidations with the ETag, got %d", notModified)
+	}
+
+	entries, err := ListIntrospectionCache()
+	if err != nil {
+		t.Fatalf("ListIntrospectionCache returned error: %v", err)
+	}
+	if len(entries) != 1 || entries[0].Endpoint != server.URL || entries[0].ETag != `"v1"` || entries[0].Size != int64(len(introspectionFixture)) {
+		t.Errorf("expected one cache entry of %s, got %+v", server.URL, entries)
+	}
+
+	if removed, err := ClearIntrospectionCache(); err != nil || removed != 1 {
+		t.Errorf("expected 1 removed cache entry, got %d, %v", removed, err)
+	}
+	if entries, _ := ListIntrospectionCache(); len(entries) != 0 {
+		t.Errorf("expected an empty cache, got %+v", entries)
+	}
+}
+
+func TestListIntrospectionCache_MigratesLegacyEntries(t *testing.T) {
+	t.Setenv("XDG_CACHE_HOME", t.TempDir())
+	t.Setenv("LOCALAPPDATA", t.TempDir())
+
+	// Older versions encoded the endpoint with the standard encoding, which differs for + and /
+	endpoint := "https://example.com/graphql?q=~~~"
+	legacyName := base64.StdEncoding.EncodeToString([]byte(endpoint))
+	if legacyName == base64.URLEncoding.EncodeToString([]byte(endpoint)) {
+		t.Fatalf("expected the encodings of %s to differ", endpoint)
+	}
+	if err := os.MkdirAll(introspectionCacheDir(), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(introspectionCacheDir(), legacyName+".json"), []byte(introspectionFixture), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	entries, err := ListIntrospectionCache()
+	if err != nil {
+		t.Fatalf("ListIntrospectionCache returned error: %v", err)
+	}
+	if len(entries) != 1 || entries[0].Endpoint != endpoint || entries[0].Size != int64(len(introspectionFixture)) {
+		t.Fatalf("expected the legacy cache entry of %s, got %+v", endpoint, entries)
+	}
+	if data, err := LoadIntrospection(config.Input{}, endpoint); err != nil || string(data) != introspectionFixture {
+		t.Errorf("expected the migrated introspection result to be loaded, got %v", err)
+	}
+	if removed, err := ClearIntrospectionCache(); err != nil || removed != 1 {
+		t.Errorf("expected 1 removed cache entry, got %d, %v", removed, err)
+	}
+}
+
+func TestLoad_MergesSources(t *testing.T) {
+	server := writeSchemaDir(t, `type Query {
+  user: User
+}
+
+type User {
+  id: ID!
+  name: String!
+}`)
+	client := writeSchemaDir(t, `extend type Query {
+  isLoggedIn: Boolean!
+  draft: Draft
+}
+
+extend type User {
+  selected: Boolean!
+}
+
+type Draft {
+  text: String!
+}`)
+
+	s, err := Load(config.Config{Input: config.Input{Schemas: config.SchemaSources{{Path: server}, {Path: client, Client: true}}}})
+	if err != nil {
+		t.Fatalf("Load returned error: %v", err)
+	}
+	for _, field := range []struct {
+		typeName, name string
+		clientOnly     bool
+	}{
+		{"Query", "user", false},
+		{"Query", "isLoggedIn", true},
+		{"User", "name", false},
+		{"User", "selected", true},
+		{"Draft", "text", true},
+	} {
+		typeDef := s.Types[field.typeName]
+		fieldDef := findFieldDefinition(&typeDef, field.name)
+		if fieldDef == nil || fieldDef.IsClientOnly != field.clientOnly {
+			t.Errorf("expected %s.%s with client-only %v, got %+v", field.typeName, field.name, field.clientOnly, fieldDef)
+		}
+	}
+
+	operations := mustParse(t, `query Home {
+  isLoggedIn
+  user {
+    name
+    selected
+  }
+  draft {
+    text
+  }
+}
+
+query Local {
+  isLoggedIn
+}`)
+	sent := parser.NewDocument(operations)
+	sent = s.StripClientFields(sent)
+	for i, expected := range []string{"user {\n    name\n  }\n}", "query Local {\n  __typename\n}"} {
+		if got := sent.FormattedOperationString(sent.Operations[i]); !strings.Contains(got, expected) || strings.Contains(got, "isLoggedIn") || strings.Contains(got, "selected") {
+			t.Errorf("expected client-only fields to be stripped, got:\n%s", got)
+		}
+	}
+
+	var buf bytes.Buffer
+	if err := s.GenerateTypeScriptWithOperations(nil, operations, &buf); err != nil {
+		t.Fatalf("GenerateTypeScriptWithOperations returned error: %v", err)
+	}
+	for _, expected := range []string{"isLoggedIn: z.boolean().optional()", "selected: z.boolean().optional()", "name: z.string(),"} {
+		if !strings.Contains(buf.String(), expected) {
+			t.Errorf("expected %q in output:\n%s", expected, buf.String())
+		}
+	}
+
+	// The printed schema is the one of the server
+	serverSchema := s.ServerSchema()
+	var sdl, introspection bytes.Buffer
+	if err := serverSchema.WriteSDL(&sdl); err != nil {
+		t.Fatalf("WriteSDL returned error: %v", err)
+	}
+	if err := serverSchema.WriteIntrospection(&introspection); err != nil {
+		t.Fatalf("WriteIntrospection returned error: %v", err)
+	}
+	expected := `type Query {
+  user: User
+}
+
+type User {
+  id: ID!
+  name: String!
+}
+`
+	if sdl.String() != expected {
+		t.Errorf("expected the server schema:\n%s\ngot:\n%s", expected, sdl.String())
+	}
+	for _, clientOnly := range []string{"isLoggedIn", "selected", "Draft"} {
+		if strings.Contains(introspection.String(), clientOnly) {
+			t.Errorf("expected no %s in the introspection result:\n%s", clientOnly, introspection.String())
+		}
+	}
+	if fieldDef := findFieldDefinition(s.Query, "isLoggedIn"); fieldDef == nil {
+		t.Errorf("expected the merged schema to keep its client-only fields")
+	}
+}
+
+func TestStripClientFields_DropsUnusedVariablesAndFragments(t *testing.T) {
+	server := writeSchemaDir(t, "type Query {\n  me: User\n}\n\ntype User {\n  id: ID!\n  friends(first: Int): [User!]!\n}")
+	client := writeSchemaDir(t, "extend type User {\n  isSelected(mode: String): Boolean!\n  draft: User\n}")
+	s, err := Load(config.Config{Input: config.Input{Schemas: config.SchemaSources{{Path: server}, {Path: client, Client: true}}}})
+	if err != nil {
+		t.Fatalf("Load returned error: %v", err)
+	}
+
+	sent := s.StripClientFields(parser.NewDocument(mustParse(t, `query Me($mode: String, $first: Int) {
+  me {
+    id
+    isSelected(mode: $mode)
+    friends(first: $first) {
+      ...Friend
+    }
+    draft {
+      ...Draft
+    }
+  }
+}
+
+fragment Friend on User {
+  id
+}
+
+fragment Draft on User {
+  id
+}`)))
+	expected := `query Me($first: Int) {
+  me {
+    id
+    friends(first: $first) {
+      ...Friend
+    }
+  }
+}
+
+fragment Friend on User {
+  id
+}`
+	if got := sent.FormattedOperationString(sent.Operations[0]); got != expected {
+		t.Errorf("expected:\n%s\ngot:\n%s", expected, got)
+	}
+	if len(sent.Fragments) != 1 || sent.Fragments[0].Name != "Friend" {
+		t.Errorf("expected only the Friend fragment, got %+v", sent.Fragments)
+	}
+}
+
+func TestLoad_ReportsConflictingSources(t *testing.T) {
+	server := writeSchemaDir(t, "type Query {\n  user: User\n}\n\ntype User {\n  id: ID!\n}")
+	for sdl, expected := range map[string]string{
+		"type User {\n  name: String\n}":        "type User is defined by both " + server + " and ",
+		"extend type User {\n  id: ID!\n}":      "field User.id of ",
+		"extend type Missing {\n  id: ID!\n}":   ": cannot extend unknown type Missing",
+		"type Query {\n  me: User\n}":           "type Query is defined by both ",
+		"extend type Query {\n  user: User\n}":  "is already defined by " + server,
+		"extend type User {\n  name: String\n}": "",
+		"query Me {\n  user {\n    id\n  }\n}":  "contains an unexpected parser.OperationDefinition",
+	} {
+		client := writeSchemaDir(t, sdl)
+		_, err := Load(config.Config{Input: config.Input{Schemas: config.SchemaSources{{Path: server}, {Path: client, Client: true}}}})
+		if expected == "" {
+			if err != nil {
+				t.Errorf("expected %q to merge, got %v", sdl, err)
+			}
+			continue
+		}
+		if err == nil || !strings.Contains(err.Error(), expected) {
+			t.Errorf("expected error containing %q for %q, got %v", expected, sdl, err)
+		}
+	}
+}
+
+func writeSchemaDir(t *testing.T, sdl string) string {
+	t.Helper()
+	dir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(dir, "schema.graphql"), []byte(sdl), 0644); err != nil {
+		t.Fatal(err)
+	}
+	return dir
+}
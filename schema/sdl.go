@@ -0,0 +1,253 @@
+package schema
+
+import (
+	"encoding/json"
+	"fmt"
+	"gqlc/parser"
+	"io"
+	"sort"
+	"strings"
+)
+
+// builtInDirectives are defined by the specification and left out of printed schemas
+var builtInDirectives = map[string]bool{
+	"skip":        true,
+	"include":     true,
+	"deprecated":  true,
+	"specifiedBy": true,
+	"oneOf":       true,
+}
+
+// WriteSDL writes the schema as SDL document. Types, directives, fields and the types a type implements
+// or unites are sorted by name, so the output only changes when the schema does. Enum values keep
+// their declared order, which is meaningful.
+func (s *Schema) WriteSDL(w io.Writer) error {
+	var blocks []string
+
+	if definition := s.schemaDefinition(); definition != "" {
+		blocks = append(blocks, definition)
+	}
+
+	directiveNames := make([]string, 0, len(s.Directives))
+	for name := range s.Directives {
+		if !builtInDirectives[name] {
+			directiveNames = append(directiveNames, name)
+		}
+	}
+	sort.Strings(directiveNames)
+	for _, name := range directiveNames {
+		blocks = append(blocks, printDirectiveDefinition(s.Directives[name]))
+	}
+
+	typeNames := make([]string, 0, len(s.Types))
+	for name := range s.Types {
+		if !isBuiltInScalar(name) && !strings.HasPrefix(name, "__") {
+			typeNames = append(typeNames, name)
+		}
+	}
+	sort.Strings(typeNames)
+	for _, name := range typeNames {
+		blocks = append(blocks, printTypeDefinition(s.Types[name]))
+	}
+
+	if _, err := io.WriteString(w, strings.Join(blocks, "\n\n")+"\n"); err != nil {
+		return fmt.Errorf("failed to write SDL: %w", err)
+	}
+	return nil
+}
+
+// schemaDefinition returns the schema definition, which is left out if the root types use the default names
+func (s *Schema) schemaDefinition() string {
+	roots := []struct {
+		operation string
+		typeDef   *TypeDefinition
+	}{
+		{"query", s.Query},
+		{"mutation", s.Mutation},
+		{"subscription", s.Subscription},
+	}
+
+	// A type with a default name that isn't the root type would become one without the schema definition
+	defaultNames := true
+	for _, root := range roots {
+		defaultName := strings.ToUpper(root.operation[:1]) + root.operation[1:]
+		if root.typeDef != nil && root.typeDef.Name != defaultName {
+			defaultNames = false
+		}
+		if _, ok := s.Types[defaultName]; root.typeDef == nil && ok {
+			defaultNames = false
+		}
+	}
+	if defaultNames {
+		return ""
+	}
+
+	var b strings.Builder
+	b.WriteString("schema {\n")
+	for _, root := range roots {
+		if root.typeDef != nil {
+			fmt.Fprintf(&b, "  %s: %s\n", root.operation, root.typeDef.Name)
+		}
+	}
+	b.WriteString("}")
+	return b.String()
+}
+
+func printDirectiveDefinition(d DirectiveDefinition) string {
+	var b strings.Builder
+	b.WriteString(printDescription(d.Description, ""))
+	b.WriteString("directive @" + d.Name + printArguments(d.Args, ""))
+	if d.IsRepeatable {
+		b.WriteString(" repeatable")
+	}
+	b.WriteString(" on " + strings.Join(d.Locations, " | "))
+	return b.String()
+}
+
+func printTypeDefinition(t TypeDefinition) string {
+	var b strings.Builder
+	b.WriteString(printDescription(t.Description, ""))
+
+	switch t.Kind {
+	case "OBJECT", "INTERFACE":
+		keyword := "type"
+		if t.Kind == "INTERFACE" {
+			keyword = "interface"
+		}
+		b.WriteString(keyword + " " + t.Name)
+		if len(t.Interfaces) > 0 {
+			b.WriteString(" implements " + strings.Join(sorted(t.Interfaces), " & "))
+		}
+		b.WriteString(printDirectives(t.Directives))
+		fields := append([]FieldDefinition(nil), t.Fields...)
+		sort.Slice(fields, func(i, j int) bool { return fields[i].Name < fields[j].Name })
+		var lines []string
+		for _, f := range fields {
+			lines = append(lines, printDescription(f.Description, "  ")+"  "+f.Name+printArguments(f.Args, "  ")+": "+f.Type.String()+printDeprecation(f.IsDeprecated, f.DeprecationReason)+printDirectives(f.Directives))
+		}
+		b.WriteString(printBlock(lines))
+	case "INPUT_OBJECT":
+		b.WriteString("input " + t.Name + printDirectives(t.Directives))
+		fields := append([]InputValueDefinition(nil), t.InputFields...)
+		sort.Slice(fields, func(i, j int) bool { return fields[i].Name < fields[j].Name })
+		var lines []string
+		for _, f := range fields {
+			lines = append(lines, printDescription(f.Description, "  ")+"  "+printInputValue(f))
+		}
+		b.WriteString(printBlock(lines))
+	case "ENUM":
+		b.WriteString("enum " + t.Name + printDirectives(t.Directives))
+		var lines []string
+		for _, v := range t.EnumValues {
+			lines = append(lines, printDescription(v.Description, "  ")+"  "+v.Name+printDeprecation(v.IsDeprecated, v.DeprecationReason)+printDirectives(v.Directives))
+		}
+		b.WriteString(printBlock(lines))
+	case "UNION":
+		b.WriteString("union " + t.Name + printDirectives(t.Directives))
+		if len(t.PossibleTypes) > 0 {
+			b.WriteString(" = " + strings.Join(sorted(t.PossibleTypes), " | "))
+		}
+	default:
+		b.WriteString("scalar " + t.Name)
+		if t.SpecifiedByURL != nil {
+			b.WriteString(fmt.Sprintf(" @specifiedBy(url: %s)", printString(*t.SpecifiedByURL)))
+		}
+		b.WriteString(printDirectives(t.Directives))
+	}
+	return b.String()
+}
+
+// printBlock returns the lines in braces, or nothing for a definition without fields
+func printBlock(lines []string) string {
+	if len(lines) == 0 {
+		return ""
+	}
+	return " {\n" + strings.Join(lines, "\n") + "\n}"
+}
+
+// printArguments returns the arguments in parentheses. Arguments with descriptions are printed on their own lines.
+func printArguments(args []InputValueDefinition, indent string) string {
+	if len(args) == 0 {
+		return ""
+	}
+	multiline := false
+	for _, arg := range args {
+		if arg.Description != nil && *arg.Description != "" {
+			multiline = true
+		}
+	}
+	parts := make([]string, len(args))
+	if !multiline {
+		for i, arg := range args {
+			parts[i] = printInputValue(arg)
+		}
+		return "(" + strings.Join(parts, ", ") + ")"
+	}
+	for i, arg := range args {
+		parts[i] = printDescription(arg.Description, indent+"  ") + indent + "  " + printInputValue(arg)
+	}
+	return "(\n" + strings.Join(parts, "\n") + "\n" + indent + ")"
+}
+
+func printInputValue(v InputValueDefinition) string {
+	s := v.Name + ": " + v.Type.String()
+	if v.DefaultValue != nil {
+		s += " = " + *v.DefaultValue
+	}
+	return s + printDeprecation(v.IsDeprecated, v.DeprecationReason) + printDirectives(v.Directives)
+}
+
+// printDirectives returns the applied directives, each preceded by a space
+func printDirectives(directives []parser.Directive) string {
+	var b strings.Builder
+	for _, d := range directives {
+		b.WriteString(" " + d.String())
+	}
+	return b.String()
+}
+
+func printDeprecation(isDeprecated bool, reason *string) string {
+	if !isDeprecated {
+		return ""
+	}
+	if reason == nil || *reason == defaultDeprecationReason {
+		return " @deprecated"
+	}
+	return fmt.Sprintf(" @deprecated(reason: %s)", printString(*reason))
+}
+
+// printDescription returns the description followed by a line break, descriptions with line breaks or quotes are block strings
+func printDescription(description *string, indent string) string {
+	if description == nil || *description == "" {
+		return ""
+	}
+	if !strings.ContainsAny(*description, "\n\"\\") {
+		return indent + `"` + *description + `"` + "\n"
+	}
+	var b strings.Builder
+	b.WriteString(indent + `"""` + "\n")
+	for _, line := range strings.Split(strings.ReplaceAll(*description, `"""`, `\"""`), "\n") {
+		if line != "" {
+			b.WriteString(indent + line)
+		}
+		b.WriteString("\n")
+	}
+	b.WriteString(indent + `"""` + "\n")
+	return b.String()
+}
+
+// printString returns the string as GraphQL string literal
+func printString(s string) string {
+	// JSON string escapes are valid GraphQL string escapes
+	var b strings.Builder
+	enc := json.NewEncoder(&b)
+	enc.SetEscapeHTML(false)
+	_ = enc.Encode(s)
+	return strings.TrimSuffix(b.String(), "\n")
+}
+
+func sorted(names []string) []string {
+	names = append([]string(nil), names...)
+	sort.Strings(names)
+	return names
+}
@@ -0,0 +1,163 @@
+package schema
+
+import (
+	"bytes"
+	"encoding/json"
+	"regexp"
+	"strings"
+	"testing"
+)
+
+func TestWriteSDL_PrintsCanonicalSchema(t *testing.T) {
+	s := mustBuildSchema(t, `schema { query: RootQuery }
+
+"Marks cached fields"
+directive @cached(ttl: Int = 60) repeatable on FIELD_DEFINITION | OBJECT
+
+directive @tag(name: String!) repeatable on SCALAR | OBJECT | FIELD_DEFINITION | ARGUMENT_DEFINITION | UNION | ENUM | ENUM_VALUE | INPUT_OBJECT | INPUT_FIELD_DEFINITION
+
+scalar UUID @specifiedBy(url: "https://tools.ietf.org/html/rfc4122") @tag(name: "id")
+
+type RootQuery {
+  user(
+    "The id of the user"
+    id: ID!
+  ): User
+  search(term: String, limit: Int = 10 @deprecated(reason: "Use pages"), page: Int @tag(name: "paging")): [Actor!]! @cached(ttl: 5) @cached
+}
+
+union Actor @tag(name: "actor") = User | Bot
+
+type Bot implements Node { id: ID! }
+
+"""
+A user of the "app".
+Users log in with their name.
+"""
+type User implements Node {
+  name: String!
+  login: String @deprecated(reason: "Use name")
+  id: ID!
+  role: Role
+}
+
+interface Node { id: ID! }
+
+enum Role @tag(name: "auth") {
+  USER
+  GUEST @deprecated
+  "Can do anything"
+  ADMIN @tag(name: "admin")
+}
+
+input Filter @tag(name: "filter") {
+  role: Role = USER @deprecated
+  name: String @tag(name: "name")
+}
+
+extend type User @cached(ttl: 10)
+`)
+
+	var buf bytes.Buffer
+	if err := s.WriteSDL(&buf); err != nil {
+		t.Fatalf("WriteSDL returned error: %v", err)
+	}
+
+	expected := `schema {
+  query: RootQuery
+}
+
+"Marks cached fields"
+directive @cached(ttl: Int = 60) repeatable on FIELD_DEFINITION | OBJECT
+
+directive @tag(name: String!) repeatable on SCALAR | OBJECT | FIELD_DEFINITION | ARGUMENT_DEFINITION | UNION | ENUM | ENUM_VALUE | INPUT_OBJECT | INPUT_FIELD_DEFINITION
+
+union Actor @tag(name: "actor") = Bot | User
+
+type Bot implements Node {
+  id: ID!
+}
+
+input Filter @tag(name: "filter") {
+  name: String @tag(name: "name")
+  role: Role = USER @deprecated
+}
+
+interface Node {
+  id: ID!
+}
+
+enum Role @tag(name: "auth") {
+  USER
+  GUEST @deprecated
+  "Can do anything"
+  ADMIN @tag(name: "admin")
+}
+
+type RootQuery {
+  search(term: String, limit: Int = 10 @deprecated(reason: "Use pages"), page: Int @tag(name: "paging")): [Actor!]! @cached(ttl: 5) @cached
+  user(
+    "The id of the user"
+    id: ID!
+  ): User
+}
+
+scalar UUID @specifiedBy(url: "https://tools.ietf.org/html/rfc4122") @tag(name: "id")
+
+"""
+A user of the "app".
+Users log in with their name.
+"""
+type User implements Node @cached(ttl: 10) {
+  id: ID!
+  login: String @deprecated(reason: "Use name")
+  name: String!
+  role: Role
+}
+`
+	if buf.String() != expected {
+		t.Errorf("unexpected SDL:\n%s", buf.String())
+	}
+
+	// The printed SDL and the introspection result describe the same schema
+	reparsed := mustBuildSchema(t, buf.String())
+	var reprinted bytes.Buffer
+	if err := reparsed.WriteSDL(&reprinted); err != nil {
+		t.Fatalf("WriteSDL returned error: %v", err)
+	}
+	if reprinted.String() != expected {
+		t.Errorf("expected printed SDL to round-trip, got:\n%s", reprinted.String())
+	}
+
+	var introspection bytes.Buffer
+	if err := s.WriteIntrospection(&introspection); err != nil {
+		t.Fatalf("WriteIntrospection returned error: %v", err)
+	}
+	var intro introspectionResponse
+	if err := json.Unmarshal(introspection.Bytes(), &intro); err != nil {
+		t.Fatalf("failed to parse introspection: %v", err)
+	}
+	fromIntrospection, err := buildSchemaFromIntrospection(&intro)
+	if err != nil {
+		t.Fatalf("buildSchemaFromIntrospection returned error: %v", err)
+	}
+	if kind := fromIntrospection.Types["User"].Fields[3].Type.Kind; kind != "ENUM" {
+		t.Errorf("expected the kind of named type references to be resolved, got %s", kind)
+	}
+	reprinted.Reset()
+	if err := fromIntrospection.WriteSDL(&reprinted); err != nil {
+		t.Fatalf("WriteSDL returned error: %v", err)
+	}
+	// Introspection doesn't know applied directives other than @deprecated and @specifiedBy
+	applied := regexp.MustCompile(` @(tag|cached)(\([^)]*\))?`)
+	lines := strings.Split(expected, "\n")
+	for i, line := range lines {
+		if !strings.HasPrefix(line, "directive ") {
+			lines[i] = applied.ReplaceAllString(line, "")
+		}
+	}
+	withoutApplied := strings.Join(lines, "\n")
+	if reprinted.String() != withoutApplied {
+		t.Errorf("expected introspection to round-trip, got:\n%s", reprinted.String())
+	}
+}
@@ -3,11 +3,14 @@ package schema
 import (
 	"bytes"
 	"fmt"
+	"gqlc/config"
 	"gqlc/parser"
 	"io"
 	"sort"
 	"strconv"
 	"strings"
+	"unicode"
+	"unicode/utf8"
 )
 
 // GenerateTypeScriptWithOperations generates TypeScript code with operation-specific schemas
@@ -18,25 +21,141 @@ func (s *Schema) GenerateTypeScriptWithOperations(filter []string, operations []
 
 // TypeScriptGenerator generates TypeScript code with Zod schemas
 type TypeScriptGenerator struct {
-	operations []parser.AST
+	// Scalars maps custom scalars to TypeScript types and Zod schemas, unmapped custom scalars accept any value
+	Scalars config.Scalars
+
+	operations  []parser.AST
+	fragments   map[string]parser.FragmentDefinition
+	usedScalars map[string]bool
 }
 
 // GenerateWithOperations generates TypeScript code with operation-specific Zod schemas
 func (g *TypeScriptGenerator) GenerateWithOperations(schema *Schema, filter []string, operations []parser.AST, w io.Writer) error {
 	g.operations = operations
+	g.fragments = make(map[string]parser.FragmentDefinition)
+	for _, op := range operations {
+		if fragDef, ok := op.(parser.FragmentDefinition); ok {
+			g.fragments[fragDef.Name] = fragDef
+		}
+	}
 	return g.Generate(schema, filter, w)
 }
 
 // Generate generates TypeScript code with Zod schemas and inferred types
 func (g *TypeScriptGenerator) Generate(schema *Schema, filter []string, w io.Writer) error {
+	g.usedScalars = make(map[string]bool)
+
+	// The imports of custom scalars depend on the generated code, so generate the body first
+	var body bytes.Buffer
+	if err := g.generateBody(schema, &body); err != nil {
+		return err
+	}
+
 	// Import statements
 	if _, err := fmt.Fprintln(w, "import { z } from \"zod\";"); err != nil {
 		return err
 	}
+	if err := g.generateScalarImports(w); err != nil {
+		return err
+	}
 	if _, err := fmt.Fprintln(w); err != nil {
 		return err
 	}
 
+	_, err := body.WriteTo(w)
+	return err
+}
+
+// generateScalarImports imports the identifiers used by the mappings of the used custom scalars
+func (g *TypeScriptGenerator) generateScalarImports(w io.Writer) error {
+	imports := make(map[string]map[string]bool)
+	for name := range g.usedScalars {
+		scalar := g.Scalars[name]
+		if scalar.Import == "" {
+			continue
+		}
+		if imports[scalar.Import] == nil {
+			imports[scalar.Import] = make(map[string]bool)
+		}
+		if ident := leadingIdentifier(scalar.Type); ident != "" && !isGlobalTypeScriptType(ident) {
+			imports[scalar.Import]["type "+ident] = true
+		}
+		if ident := leadingIdentifier(scalar.Zod); ident != "" && ident != "z" {
+			imports[scalar.Import][ident] = true
+		}
+	}
+
+	for _, path := range sortedKeys(importPaths(imports)) {
+		identifiers := imports[path]
+		if len(identifiers) == 0 {
+			if _, err := fmt.Fprintf(w, "import %s;\n", strconv.Quote(path)); err != nil {
+				return err
+			}
+			continue
+		}
+		names := sortedKeys(identifiers)
+		sort.SliceStable(names, func(i, j int) bool {
+			return strings.TrimPrefix(names[i], "type ") < strings.TrimPrefix(names[j], "type ")
+		})
+		if _, err := fmt.Fprintf(w, "import { %s } from %s;\n", strings.Join(names, ", "), strconv.Quote(path)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func importPaths(imports map[string]map[string]bool) map[string]bool {
+	paths := make(map[string]bool, len(imports))
+	for path := range imports {
+		paths[path] = true
+	}
+	return paths
+}
+
+// leadingIdentifier returns the identifier an expression starts with, e.g. "Decimal" for "Decimal.schema()"
+func leadingIdentifier(expr string) string {
+	expr = strings.TrimSpace(expr)
+	end := 0
+	for i, r := range expr {
+		if r == '_' || r == '$' || unicode.IsLetter(r) || (i > 0 && unicode.IsDigit(r)) {
+			end = i + utf8.RuneLen(r)
+			continue
+		}
+		break
+	}
+	return expr[:end]
+}
+
+// isGlobalTypeScriptType checks if a type name is available without an import
+func isGlobalTypeScriptType(name string) bool {
+	switch name {
+	case "string", "number", "boolean", "bigint", "symbol", "object", "unknown", "any", "never", "null", "undefined",
+		"Date", "Record", "Array", "ReadonlyArray", "Map", "Set", "File", "Blob", "Uint8Array", "Partial", "Readonly":
+		return true
+	default:
+		return false
+	}
+}
+
+// scalarSchemaExpr returns the Zod schema expression of a custom scalar
+func (g *TypeScriptGenerator) scalarSchemaExpr(name string) string {
+	scalar, ok := g.Scalars[name]
+	if !ok {
+		return "z.any()"
+	}
+	g.usedScalars[name] = true
+	switch {
+	case scalar.Zod != "":
+		return scalar.Zod
+	case scalar.Type != "":
+		return fmt.Sprintf("z.custom<%s>()", scalar.Type)
+	default:
+		return "z.any()"
+	}
+}
+
+// generateBody generates the schemas and types of the input types, fragments and operations
+func (g *TypeScriptGenerator) generateBody(schema *Schema, w io.Writer) error {
 	requiredTypes := g.collectVariableSchemas(schema)
 
 	// Generate Zod schemas for variable/input types
@@ -59,8 +178,16 @@ func (g *TypeScriptGenerator) Generate(schema *Schema, filter []string, w io.Wri
 		}
 	}
 
-	// Generate operation-specific schemas
+	// Generate fragment and operation-specific schemas
 	if len(g.operations) > 0 {
+		for _, op := range g.operations {
+			switch opDef := op.(type) {
+			case parser.FragmentDefinition:
+				if err := g.generateFragmentSchema(w, opDef, schema); err != nil {
+					return err
+				}
+			}
+		}
 		for _, op := range g.operations {
 			switch opDef := op.(type) {
 			case parser.OperationDefinition:
@@ -130,25 +257,26 @@ func (g *TypeScriptGenerator) collectVariableSchemas(schema *Schema) map[string]
 			continue
 		}
 		for _, variable := range opDef.Variables {
-			g.collectVariableType(schema, variable.Type, used)
+			collectVariableType(schema, variable.Type, used)
 		}
 	}
 
 	return used
 }
 
-func (g *TypeScriptGenerator) collectVariableType(schema *Schema, t parser.Type, used map[string]bool) {
+// collectVariableType collects the input, enum and scalar types used by a variable type
+func collectVariableType(schema *Schema, t parser.Type, used map[string]bool) {
 	switch typ := t.(type) {
 	case parser.NamedType:
-		g.addTypeWithDependencies(schema, typ.Name, used)
+		addTypeWithDependencies(schema, typ.Name, used)
 	case parser.ListType:
-		g.collectVariableType(schema, typ.Type, used)
+		collectVariableType(schema, typ.Type, used)
 	case parser.NonNullType:
-		g.collectVariableType(schema, typ.Type, used)
+		collectVariableType(schema, typ.Type, used)
 	}
 }
 
-func (g *TypeScriptGenerator) addTypeWithDependencies(schema *Schema, typeName string, used map[string]bool) {
+func addTypeWithDependencies(schema *Schema, typeName string, used map[string]bool) {
 	if isBuiltInScalar(typeName) {
 		return
 	}
@@ -166,7 +294,7 @@ func (g *TypeScriptGenerator) addTypeWithDependencies(schema *Schema, typeName s
 	case "INPUT_OBJECT":
 		used[typeName] = true
 		for _, field := range typeDef.InputFields {
-			g.collectTypeRefDependencies(schema, field.Type, used)
+			collectTypeRefDependencies(schema, field.Type, used)
 		}
 	case "ENUM", "SCALAR":
 		used[typeName] = true
@@ -177,15 +305,15 @@ func (g *TypeScriptGenerator) addTypeWithDependencies(schema *Schema, typeName s
 	}
 }
 
-func (g *TypeScriptGenerator) collectTypeRefDependencies(schema *Schema, typeRef TypeRef, used map[string]bool) {
+func collectTypeRefDependencies(schema *Schema, typeRef TypeRef, used map[string]bool) {
 	switch typeRef.Kind {
 	case "NON_NULL", "LIST":
 		if typeRef.OfType != nil {
-			g.collectTypeRefDependencies(schema, *typeRef.OfType, used)
+			collectTypeRefDependencies(schema, *typeRef.OfType, used)
 		}
 	default:
 		if typeRef.Name != nil {
-			g.addTypeWithDependencies(schema, *typeRef.Name, used)
+			addTypeWithDependencies(schema, *typeRef.Name, used)
 		}
 	}
 }
@@ -195,6 +323,10 @@ func (g *TypeScriptGenerator) generateTypeSchema(w io.Writer, typeDef TypeDefini
 	schemaName := typeDef.Name + "_Schema"
 	typeName := typeDef.Name
 
+	if _, err := fmt.Fprint(w, parser.JSDoc(typeDef.Description, "")); err != nil {
+		return err
+	}
+
 	switch typeDef.Kind {
 	case "ENUM":
 		// Generate enum schema
@@ -207,7 +339,7 @@ func (g *TypeScriptGenerator) generateTypeSchema(w io.Writer, typeDef TypeDefini
 					return err
 				}
 			}
-			if _, err := fmt.Fprintf(w, "  \"%s\"", enumVal.Name); err != nil {
+			if _, err := fmt.Fprintf(w, "%s  \"%s\"", parser.JSDoc(documentation(enumVal.Description, enumVal.IsDeprecated, enumVal.DeprecationReason), "  "), enumVal.Name); err != nil {
 				return err
 			}
 		}
@@ -226,12 +358,18 @@ func (g *TypeScriptGenerator) generateTypeSchema(w io.Writer, typeDef TypeDefini
 					return err
 				}
 			}
-			if _, err := fmt.Fprintf(w, "  %s: ", field.Name); err != nil {
+			if _, err := fmt.Fprintf(w, "%s  %s: ", parser.JSDoc(field.Description, "  "), field.Name); err != nil {
 				return err
 			}
 			if err := g.generateTypeRefSchema(w, field.Type, schema); err != nil {
 				return err
 			}
+			// Nullable input fields and fields with a default value may be omitted
+			if field.Type.Kind != "NON_NULL" || field.DefaultValue != nil {
+				if _, err := fmt.Fprint(w, ".optional()"); err != nil {
+					return err
+				}
+			}
 		}
 		if _, err := fmt.Fprintln(w, "\n}));"); err != nil {
 			return err
@@ -248,7 +386,7 @@ func (g *TypeScriptGenerator) generateTypeSchema(w io.Writer, typeDef TypeDefini
 					return err
 				}
 			}
-			if _, err := fmt.Fprintf(w, "  %s: ", field.Name); err != nil {
+			if _, err := fmt.Fprintf(w, "%s  %s: ", parser.JSDoc(documentation(field.Description, field.IsDeprecated, field.DeprecationReason), "  "), field.Name); err != nil {
 				return err
 			}
 			if err := g.generateTypeRefSchema(w, field.Type, schema); err != nil {
@@ -261,7 +399,17 @@ func (g *TypeScriptGenerator) generateTypeSchema(w io.Writer, typeDef TypeDefini
 
 	case "SCALAR":
 		// Generate scalar schema
-		if _, err := fmt.Fprintf(w, "export const %s = z.any(); // Custom scalar\n", schemaName); err != nil {
+		if _, ok := g.Scalars[typeDef.Name]; !ok {
+			if _, err := fmt.Fprintf(w, "export const %s = z.any(); // Custom scalar\n", schemaName); err != nil {
+				return err
+			}
+			break
+		}
+		if _, err := fmt.Fprintf(w, "export const %s = %s;\n", schemaName, g.scalarSchemaExpr(typeDef.Name)); err != nil {
+			return err
+		}
+		if scalarType := g.Scalars[typeDef.Name].Type; scalarType != "" {
+			_, err := fmt.Fprintf(w, "%sexport type %s = %s;\n", parser.JSDoc(typeDef.Description, ""), typeName, scalarType)
 			return err
 		}
 
@@ -286,7 +434,7 @@ func (g *TypeScriptGenerator) generateTypeSchema(w io.Writer, typeDef TypeDefini
 	}
 
 	// Generate TypeScript type
-	if _, err := fmt.Fprintf(w, "export type %s = z.infer<typeof %s>;\n", typeName, schemaName); err != nil {
+	if _, err := fmt.Fprintf(w, "%sexport type %s = z.infer<typeof %s>;\n", parser.JSDoc(typeDef.Description, ""), typeName, schemaName); err != nil {
 		return err
 	}
 
@@ -320,8 +468,20 @@ func (g *TypeScriptGenerator) generateOperationSchema(w io.Writer, op parser.Ope
 		return fmt.Errorf("root type not found for operation type %s", op.Type)
 	}
 
-	// Generate the Zod schema for this operation's selection
-	if _, err := fmt.Fprintf(w, "// Schema for %s operation\n", funcNameStr); err != nil {
+	if err := g.checkConditionVariables(op); err != nil {
+		return fmt.Errorf("operation %s: %w", funcNameStr, err)
+	}
+
+	if len(op.Variables) > 0 {
+		if err := g.generateVariablesSchema(w, funcNameStr, op.Variables, schema); err != nil {
+			return fmt.Errorf("operation %s: %w", funcNameStr, err)
+		}
+	}
+
+	// Generate the Zod schema for this operation's selection. Only the operation's own description is used,
+	// the description of its root field is already on the field.
+	description := parser.JSDoc(op.Description, "")
+	if _, err := fmt.Fprintf(w, "// Schema for %s operation\n%s", funcNameStr, description); err != nil {
 		return err
 	}
 	if _, err := fmt.Fprintf(w, "export const %s = ", schemaName); err != nil {
@@ -330,7 +490,7 @@ func (g *TypeScriptGenerator) generateOperationSchema(w io.Writer, op parser.Ope
 
 	// Generate the schema based on the selection set
 	if err := g.generateSelectionSetSchema(w, op.SelectionSet, rootType, schema, 0); err != nil {
-		return err
+		return fmt.Errorf("operation %s: %w", funcNameStr, err)
 	}
 
 	if _, err := fmt.Fprintln(w, ";"); err != nil {
@@ -338,98 +498,561 @@ func (g *TypeScriptGenerator) generateOperationSchema(w io.Writer, op parser.Ope
 	}
 
 	// Generate the TypeScript type
-	if _, err := fmt.Fprintf(w, "export type %s = z.infer<typeof %s>;\n\n", typeName, schemaName); err != nil {
+	if _, err := fmt.Fprintf(w, "%sexport type %s = z.infer<typeof %s>;\n\n", description, typeName, schemaName); err != nil {
 		return err
 	}
 
 	return nil
 }
 
-func (g *TypeScriptGenerator) generateSelectionSetSchema(w io.Writer, ss parser.SelectionSet, parentType *TypeDefinition, schema *Schema, depth int) error {
-	// Start the object schema
-	if _, err := fmt.Fprint(w, "z.object({\n"); err != nil {
+// generateVariablesSchema generates a Zod schema for the variables of an operation.
+// Nullable variables are optional and default values are applied when a variable is missing.
+func (g *TypeScriptGenerator) generateVariablesSchema(w io.Writer, funcNameStr string, variables []parser.VariableDefinition, schema *Schema) error {
+	schemaName := funcNameStr + "_Variables_Schema"
+	typeName := funcNameStr + "_Variables"
+
+	if _, err := fmt.Fprintf(w, "// Variables of %s operation\n", funcNameStr); err != nil {
 		return err
 	}
-
-	indent := strings.Repeat("  ", depth+1)
-
-	// Process each selection
-	for i, sel := range ss.Selections {
+	if _, err := fmt.Fprintf(w, "export const %s = z.object({\n", schemaName); err != nil {
+		return err
+	}
+	for i, variable := range variables {
 		if i > 0 {
 			if _, err := fmt.Fprint(w, ",\n"); err != nil {
 				return err
 			}
 		}
+		expr := g.variableTypeSchema(variable.Type, schema)
+		_, nonNull := variable.Type.(parser.NonNullType)
+		if !nonNull {
+			expr += ".nullable()"
+		}
+		if variable.DefaultValue != nil {
+			defaultExpr, err := tsValue(*variable.DefaultValue)
+			if err != nil {
+				return fmt.Errorf("default value of $%s: %w", variable.Name, err)
+			}
+			expr += fmt.Sprintf(".default(%s)", defaultExpr)
+		} else if !nonNull {
+			expr += ".optional()"
+		}
+		if _, err := fmt.Fprintf(w, "  %s: %s", variable.Name, expr); err != nil {
+			return err
+		}
+	}
+	if _, err := fmt.Fprintln(w, "\n});"); err != nil {
+		return err
+	}
+
+	// The input type keeps variables with default values optional
+	if _, err := fmt.Fprintf(w, "export type %s = z.input<typeof %s>;\n\n", typeName, schemaName); err != nil {
+		return err
+	}
+
+	return nil
+}
+
+// variableTypeSchema returns the Zod schema expression for a variable type without its own nullability
+func (g *TypeScriptGenerator) variableTypeSchema(t parser.Type, schema *Schema) string {
+	switch typ := t.(type) {
+	case parser.NonNullType:
+		return g.variableTypeSchema(typ.Type, schema)
+	case parser.ListType:
+		inner := g.variableTypeSchema(typ.Type, schema)
+		if _, nonNull := typ.Type.(parser.NonNullType); !nonNull {
+			inner += ".nullable()"
+		}
+		return fmt.Sprintf("z.array(%s)", inner)
+	case parser.NamedType:
+		expr, _ := g.defaultNamedTypeExpr(typ.Name, schema)
+		return expr
+	default:
+		return "z.any()"
+	}
+}
+
+// tsValue converts a constant GraphQL value to a TypeScript expression
+func tsValue(value parser.Value) (string, error) {
+	switch v := value.(type) {
+	case parser.StringValue:
+		if strings.HasPrefix(v.Value, `"""`) {
+			return strconv.Quote(v.Text()), nil
+		}
+		return v.Value, nil
+	case parser.IntValue:
+		return v.Value, nil
+	case parser.FloatValue:
+		return v.Value, nil
+	case parser.BooleanValue, parser.NullValue:
+		return v.String(), nil
+	case parser.EnumValue:
+		return strconv.Quote(v.Value), nil
+	case parser.ListValue:
+		items := make([]string, len(v.Values))
+		for i, item := range v.Values {
+			expr, err := tsValue(item)
+			if err != nil {
+				return "", err
+			}
+			items[i] = expr
+		}
+		return "[" + strings.Join(items, ", ") + "]", nil
+	case parser.ObjectValue:
+		fields := make([]string, len(v.Fields))
+		for i, field := range v.Fields {
+			expr, err := tsValue(field.Value)
+			if err != nil {
+				return "", err
+			}
+			fields[i] = field.Name + ": " + expr
+		}
+		return "{ " + strings.Join(fields, ", ") + " }", nil
+	default:
+		return "", fmt.Errorf("%s is not a constant value", value)
+	}
+}
 
+func (g *TypeScriptGenerator) generateFragmentSchema(w io.Writer, frag parser.FragmentDefinition, schema *Schema) error {
+	schemaName := frag.Name + "_Schema"
+	typeName := frag.Name
+
+	typeCondition, ok := schema.Types[frag.TypeName]
+	if !ok {
+		return fmt.Errorf("fragment %s references unknown type %s", frag.Name, frag.TypeName)
+	}
+
+	if _, err := fmt.Fprintf(w, "// Schema for %s fragment\n", frag.Name); err != nil {
+		return err
+	}
+	if _, err := fmt.Fprintf(w, "export const %s = ", schemaName); err != nil {
+		return err
+	}
+
+	if err := g.generateSelectionSetSchema(w, frag.SelectionSet, &typeCondition, schema, 0); err != nil {
+		return fmt.Errorf("fragment %s: %w", frag.Name, err)
+	}
+
+	if _, err := fmt.Fprintln(w, ";"); err != nil {
+		return err
+	}
+
+	if _, err := fmt.Fprintf(w, "export type %s = z.infer<typeof %s>;\n\n", typeName, schemaName); err != nil {
+		return err
+	}
+
+	return nil
+}
+
+// documentation returns the description with a @deprecated tag if the element is deprecated
+func documentation(description *string, isDeprecated bool, deprecationReason *string) *string {
+	if !isDeprecated {
+		return description
+	}
+	var text string
+	if description != nil && strings.TrimSpace(*description) != "" {
+		text = strings.TrimSpace(*description) + "\n"
+	}
+	text += "@deprecated"
+	if deprecationReason != nil && *deprecationReason != "" {
+		text += " " + *deprecationReason
+	}
+	return &text
+}
+
+// selectedField is a field of a selection set after fragment spreads have been merged into it
+type selectedField struct {
+	key        string
+	parentType *TypeDefinition
+	field      parser.Field
+	selections []parser.Selection
+	// conditional is set if every selection of the field depends on @include or @skip or the field is client-only
+	conditional bool
+}
+
+// collectFields flattens the selection set into the fields it selects for the given runtime type.
+// Fields of fragments are resolved against the fragment's type condition, fragments whose type
+// condition does not apply to the runtime type are skipped. An empty runtime type includes all
+// fragments. Fields selected more than once are merged into a single entry.
+// Selections inside a conditional fragment are conditional as well.
+func collectFields(fragments map[string]parser.FragmentDefinition, ss parser.SelectionSet, parentType *TypeDefinition, runtimeType string, conditional bool, schema *Schema, fields []*selectedField, visited map[string]bool) ([]*selectedField, error) {
+	for _, sel := range ss.Selections {
 		switch s := sel.(type) {
 		case parser.Field:
-			if _, err := fmt.Fprintf(w, "%s%s: ", indent, s.Name); err != nil {
-				return err
-			}
+			key := s.ResponseKey()
 
-			// Find the field definition in the parent type
-			fieldDef := findFieldDefinition(parentType, s.Name)
-			if fieldDef == nil {
-				// Field not found, use any
-				if _, err := fmt.Fprint(w, "z.any()"); err != nil {
-					return err
+			var existing *selectedField
+			for _, f := range fields {
+				if f.key == key {
+					existing = f
+					break
+				}
+			}
+			fieldConditional := conditional || isConditional(s.Directives)
+			// Client-only fields are never part of the response
+			if fieldDef := findFieldDefinition(parentType, s.Name); fieldDef != nil && fieldDef.IsClientOnly {
+				fieldConditional = true
+			}
+			if existing != nil {
+				if err := checkFieldsCanMerge(existing.field, s); err != nil {
+					return nil, err
 				}
+				existing.conditional = existing.conditional && fieldConditional
+			} else {
+				existing = &selectedField{key: key, parentType: parentType, field: s, conditional: fieldConditional}
+				fields = append(fields, existing)
+			}
+			if s.SelectionSet != nil {
+				existing.selections = append(existing.selections, s.SelectionSet.Selections...)
+			}
+
+		case parser.FragmentSpread:
+			if visited[s.Name] {
+				continue
+			}
+			frag, ok := fragments[s.Name]
+			if !ok {
+				return nil, fmt.Errorf("unknown fragment %s", s.Name)
+			}
+			typeCondition, ok := schema.Types[frag.TypeName]
+			if !ok {
+				return nil, fmt.Errorf("fragment %s references unknown type %s", frag.Name, frag.TypeName)
+			}
+			if runtimeType != "" && !schema.DoesTypeApply(frag.TypeName, runtimeType) {
 				continue
 			}
+			visited[s.Name] = true
+			var err error
+			fields, err = collectFields(fragments, frag.SelectionSet, &typeCondition, runtimeType, conditional || isConditional(s.Directives), schema, fields, visited)
+			if err != nil {
+				return nil, err
+			}
+			delete(visited, s.Name)
 
-			// Generate the field's type
-			if s.SelectionSet != nil {
-				fieldTypeName := g.getBaseTypeName(fieldDef.Type)
-				if fieldTypeName == "" {
-					if _, err := fmt.Fprint(w, "z.any()"); err != nil {
-						return err
-					}
-					continue
-				}
-				fieldType, ok := schema.Types[fieldTypeName]
+		case parser.InlineFragment:
+			fragmentType := parentType
+			if s.TypeName != nil {
+				typeCondition, ok := schema.Types[*s.TypeName]
 				if !ok {
-					if _, err := fmt.Fprint(w, "z.any()"); err != nil {
-						return err
-					}
+					return nil, fmt.Errorf("inline fragment references unknown type %s", *s.TypeName)
+				}
+				if runtimeType != "" && !schema.DoesTypeApply(*s.TypeName, runtimeType) {
 					continue
 				}
+				fragmentType = &typeCondition
+			}
+			var err error
+			fields, err = collectFields(fragments, s.SelectionSet, fragmentType, runtimeType, conditional || isConditional(s.Directives), schema, fields, visited)
+			if err != nil {
+				return nil, err
+			}
+		}
+	}
+	return fields, nil
+}
 
-				custom := func(tr TypeRef) (string, error) {
-					if tr.Name == nil || *tr.Name != fieldTypeName {
-						return "", nil
-					}
-					var buf bytes.Buffer
-					if err := g.generateSelectionSetSchema(&buf, *s.SelectionSet, &fieldType, schema, depth+1); err != nil {
-						return "", err
-					}
-					return buf.String(), nil
-				}
+// isConditional checks if the directives may exclude the selection from the response with @include or @skip
+func isConditional(directives []parser.Directive) bool {
+	for _, d := range directives {
+		if d.Name != "include" && d.Name != "skip" {
+			continue
+		}
+		for _, arg := range d.Arguments {
+			if arg.Name != "if" {
+				continue
+			}
+			// @include(if: true) and @skip(if: false) always keep the selection
+			if b, ok := arg.Value.(parser.BooleanValue); ok && b.Value == (d.Name == "include") {
+				continue
+			}
+			return true
+		}
+	}
+	return false
+}
 
-				expr, err := g.outputTypeRefSchema(fieldDef.Type, schema, custom)
-				if err != nil {
+// checkConditionVariables checks that the variables used as @include or @skip conditions
+// in the operation and the fragments it uses can be passed as Boolean!
+func (g *TypeScriptGenerator) checkConditionVariables(op parser.OperationDefinition) error {
+	variables := make(map[string]parser.VariableDefinition)
+	for _, v := range op.Variables {
+		variables[v.Name] = v
+	}
+	return g.checkSelectionConditionVariables(op.SelectionSet, variables, make(map[string]bool))
+}
+
+func (g *TypeScriptGenerator) checkSelectionConditionVariables(ss parser.SelectionSet, variables map[string]parser.VariableDefinition, visited map[string]bool) error {
+	for _, sel := range ss.Selections {
+		var directives []parser.Directive
+		switch s := sel.(type) {
+		case parser.Field:
+			directives = s.Directives
+			if s.SelectionSet != nil {
+				if err := g.checkSelectionConditionVariables(*s.SelectionSet, variables, visited); err != nil {
 					return err
 				}
-				if _, err := fmt.Fprint(w, expr); err != nil {
+			}
+		case parser.InlineFragment:
+			directives = s.Directives
+			if err := g.checkSelectionConditionVariables(s.SelectionSet, variables, visited); err != nil {
+				return err
+			}
+		case parser.FragmentSpread:
+			directives = s.Directives
+			if frag, ok := g.fragments[s.Name]; ok && !visited[s.Name] {
+				visited[s.Name] = true
+				if err := g.checkSelectionConditionVariables(frag.SelectionSet, variables, visited); err != nil {
 					return err
 				}
-			} else {
-				// Leaf field, generate its type
-				expr, err := g.outputTypeRefSchema(fieldDef.Type, schema, nil)
-				if err != nil {
-					return err
+			}
+		}
+
+		for _, d := range directives {
+			if d.Name != "include" && d.Name != "skip" {
+				continue
+			}
+			for _, arg := range d.Arguments {
+				v, ok := arg.Value.(parser.Variable)
+				if arg.Name != "if" || !ok {
+					continue
 				}
-				if _, err := fmt.Fprint(w, expr); err != nil {
-					return err
+				def, ok := variables[v.Name]
+				if !ok {
+					return fmt.Errorf("variable $%s used as @%s condition is not defined", v.Name, d.Name)
+				}
+				if !isBooleanCondition(def) {
+					return fmt.Errorf("variable $%s of type %s cannot be used as @%s condition, expected Boolean!", v.Name, def.Type, d.Name)
 				}
 			}
+		}
+	}
+	return nil
+}
 
-		case parser.FragmentSpread:
-			// TODO: Handle fragment spreads
-			continue
+// isBooleanCondition checks if the variable can be used where a Boolean! is expected,
+// a nullable Boolean is allowed if it has a non-null default value
+func isBooleanCondition(def parser.VariableDefinition) bool {
+	switch def.Type.String() {
+	case "Boolean!":
+		return true
+	case "Boolean":
+		if def.DefaultValue == nil {
+			return false
+		}
+		_, isNull := (*def.DefaultValue).(parser.NullValue)
+		return !isNull
+	default:
+		return false
+	}
+}
 
+// checkFieldsCanMerge checks that two fields with the same response key select the same field with the same arguments
+func checkFieldsCanMerge(a, b parser.Field) error {
+	if a.Name != b.Name {
+		return fmt.Errorf("fields %q conflict because %s and %s are different fields, use different aliases on the fields to fetch both", a.ResponseKey(), a.Name, b.Name)
+	}
+	if len(a.Arguments) != len(b.Arguments) {
+		return fmt.Errorf("fields %q conflict because they have differing arguments, use different aliases on the fields to fetch both", a.ResponseKey())
+	}
+	for _, argA := range a.Arguments {
+		found := false
+		for _, argB := range b.Arguments {
+			if argA.Name == argB.Name {
+				found = argA.Value.String() == argB.Value.String()
+				break
+			}
+		}
+		if !found {
+			return fmt.Errorf("fields %q conflict because they have differing arguments, use different aliases on the fields to fetch both", a.ResponseKey())
+		}
+	}
+	return nil
+}
+
+// narrowsType checks if the selection set on an interface or union contains fragments
+// with a more specific type condition, in which case the result depends on the runtime type
+func (g *TypeScriptGenerator) narrowsType(ss parser.SelectionSet, parentType *TypeDefinition, schema *Schema) bool {
+	return selectionNarrowsType(ss, parentType, schema, g.fragments, make(map[string]bool))
+}
+
+func selectionNarrowsType(ss parser.SelectionSet, parentType *TypeDefinition, schema *Schema, fragments map[string]parser.FragmentDefinition, visited map[string]bool) bool {
+	if parentType == nil || !schema.IsAbstract(parentType.Name) {
+		return false
+	}
+	for _, sel := range ss.Selections {
+		switch s := sel.(type) {
 		case parser.InlineFragment:
-			// TODO: Handle inline fragments
-			continue
+			if s.TypeName != nil && *s.TypeName != parentType.Name {
+				return true
+			}
+			if selectionNarrowsType(s.SelectionSet, parentType, schema, fragments, visited) {
+				return true
+			}
+		case parser.FragmentSpread:
+			frag, ok := fragments[s.Name]
+			if !ok || visited[s.Name] {
+				continue
+			}
+			if frag.TypeName != parentType.Name {
+				return true
+			}
+			visited[s.Name] = true
+			if selectionNarrowsType(frag.SelectionSet, parentType, schema, fragments, visited) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+func (g *TypeScriptGenerator) generateSelectionSetSchema(w io.Writer, ss parser.SelectionSet, parentType *TypeDefinition, schema *Schema, depth int) error {
+	if g.narrowsType(ss, parentType, schema) {
+		return g.generatePolymorphicSchema(w, ss, parentType, schema, depth)
+	}
+
+	runtimeType := ""
+	if parentType != nil && !schema.IsAbstract(parentType.Name) {
+		runtimeType = parentType.Name
+	}
+	fields, err := collectFields(g.fragments, ss, parentType, runtimeType, false, schema, nil, make(map[string]bool))
+	if err != nil {
+		return err
+	}
+	return g.generateObjectSchema(w, fields, runtimeType, schema, depth)
+}
+
+// generatePolymorphicSchema generates a union of object schemas, one per possible runtime type,
+// discriminated by __typename
+func (g *TypeScriptGenerator) generatePolymorphicSchema(w io.Writer, ss parser.SelectionSet, parentType *TypeDefinition, schema *Schema, depth int) error {
+	possibleTypes := schema.PossibleTypes(parentType.Name)
+	if len(possibleTypes) == 0 {
+		fields, err := collectFields(g.fragments, ss, parentType, "", false, schema, nil, make(map[string]bool))
+		if err != nil {
+			return err
+		}
+		return g.generateObjectSchema(w, fields, "", schema, depth)
+	}
+
+	if _, err := fmt.Fprint(w, "z.discriminatedUnion(\"__typename\", [\n"); err != nil {
+		return err
+	}
+
+	indent := strings.Repeat("  ", depth+1)
+	for i, possibleType := range possibleTypes {
+		if i > 0 {
+			if _, err := fmt.Fprint(w, ",\n"); err != nil {
+				return err
+			}
+		}
+
+		fields, err := collectFields(g.fragments, ss, parentType, possibleType, false, schema, nil, make(map[string]bool))
+		if err != nil {
+			return err
+		}
+		hasTypename := false
+		for _, sf := range fields {
+			if sf.key == "__typename" {
+				hasTypename = true
+				break
+			}
+		}
+		if !hasTypename {
+			typename := &selectedField{key: "__typename", parentType: parentType, field: parser.Field{Name: "__typename"}}
+			fields = append([]*selectedField{typename}, fields...)
+		}
+
+		if _, err := fmt.Fprint(w, indent); err != nil {
+			return err
+		}
+		if err := g.generateObjectSchema(w, fields, possibleType, schema, depth+1); err != nil {
+			return err
+		}
+	}
+
+	if _, err := fmt.Fprintf(w, "\n%s])", strings.Repeat("  ", depth)); err != nil {
+		return err
+	}
+
+	return nil
+}
+
+// selectedFieldSchema returns the Zod schema expression for the value of a selected field
+func (g *TypeScriptGenerator) selectedFieldSchema(sf *selectedField, runtimeType string, schema *Schema, depth int) (string, error) {
+	if sf.field.Name == "__typename" {
+		if runtimeType != "" {
+			return fmt.Sprintf("z.literal(%s)", strconv.Quote(runtimeType)), nil
+		}
+		return "z.string()", nil
+	}
+
+	// Find the field definition in the type it was selected on
+	fieldDef := findFieldDefinition(sf.parentType, sf.field.Name)
+	if fieldDef == nil {
+		// Field not found, use any
+		return "z.any()", nil
+	}
+
+	// Leaf field, generate its type
+	if sf.field.SelectionSet == nil {
+		return g.outputTypeRefSchema(fieldDef.Type, schema, nil)
+	}
+
+	fieldTypeName := g.getBaseTypeName(fieldDef.Type)
+	if fieldTypeName == "" {
+		return "z.any()", nil
+	}
+	fieldType, ok := schema.Types[fieldTypeName]
+	if !ok {
+		return "z.any()", nil
+	}
+
+	subSelection := parser.SelectionSet{Selections: sf.selections}
+	custom := func(tr TypeRef) (string, error) {
+		if tr.Name == nil || *tr.Name != fieldTypeName {
+			return "", nil
+		}
+		var buf bytes.Buffer
+		if err := g.generateSelectionSetSchema(&buf, subSelection, &fieldType, schema, depth+1); err != nil {
+			return "", err
+		}
+		return buf.String(), nil
+	}
+
+	return g.outputTypeRefSchema(fieldDef.Type, schema, custom)
+}
+
+// generateObjectSchema generates a Zod object schema for the collected fields.
+// The runtime type is used for __typename and is empty if it is not known.
+func (g *TypeScriptGenerator) generateObjectSchema(w io.Writer, fields []*selectedField, runtimeType string, schema *Schema, depth int) error {
+	// Start the object schema
+	if _, err := fmt.Fprint(w, "z.object({\n"); err != nil {
+		return err
+	}
+
+	indent := strings.Repeat("  ", depth+1)
+
+	// Process each selected field
+	for i, sf := range fields {
+		if i > 0 {
+			if _, err := fmt.Fprint(w, ",\n"); err != nil {
+				return err
+			}
+		}
+
+		expr, err := g.selectedFieldSchema(sf, runtimeType, schema, depth)
+		if err != nil {
+			return err
+		}
+		// Fields excluded by @include or @skip are missing from the response
+		if sf.conditional {
+			expr += ".optional()"
+		}
+		var description *string
+		if fieldDef := findFieldDefinition(sf.parentType, sf.field.Name); fieldDef != nil {
+			description = documentation(fieldDef.Description, fieldDef.IsDeprecated, fieldDef.DeprecationReason)
+		}
+		if _, err := fmt.Fprintf(w, "%s%s%s: %s", parser.JSDoc(description, indent), indent, sf.key, expr); err != nil {
+			return err
 		}
 	}
 
@@ -582,7 +1205,7 @@ func (g *TypeScriptGenerator) inlineNamedOutputExpr(name string, schema *Schema)
 			builder.WriteString("])")
 			return builder.String(), nil
 		case "SCALAR":
-			return "z.any()", nil
+			return g.scalarSchemaExpr(name), nil
 		}
 	}
 
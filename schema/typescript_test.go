@@ -5,6 +5,7 @@ import (
 	"strings"
 	"testing"
 
+	"gqlc/config"
 	"gqlc/parser"
 )
 
@@ -258,3 +259,518 @@ func list(of TypeRef) TypeRef {
 		OfType: &of,
 	}
 }
+
+func TestTypeScriptGenerator_MergesFragmentSpreads(t *testing.T) {
+	s := mustBuildSchema(t, `type Query {
+  user(id: ID!): User
+}
+
+type User {
+  id: ID!
+  name: String
+  email: String!
+  friends: [User!]!
+}`)
+
+	operations := mustParse(t, `query GetUser($id: ID!) {
+  user(id: $id) {
+    id
+    ...UserFields
+  }
+}
+
+fragment UserFields on User {
+  name
+  friends {
+    ...FriendFields
+  }
+}
+
+fragment FriendFields on User {
+  id
+  email
+}`)
+
+	var buf bytes.Buffer
+	if err := s.GenerateTypeScriptWithOperations(nil, operations, &buf); err != nil {
+		t.Fatalf("GenerateTypeScriptWithOperations returned error: %v", err)
+	}
+	output := buf.String()
+
+	for _, expected := range []string{
+		"export const UserFields_Schema = z.object({",
+		"export type UserFields = z.infer<typeof UserFields_Schema>;",
+		"export const FriendFields_Schema = z.object({",
+		"name: z.string().nullable()",
+		"email: z.string()",
+	} {
+		if !strings.Contains(output, expected) {
+			t.Fatalf("expected %q in output:\n%s", expected, output)
+		}
+	}
+
+	opSchema := output[strings.Index(output, "export const GetUser_Schema"):]
+	if !strings.Contains(opSchema, "name: z.string().nullable()") || !strings.Contains(opSchema, "email: z.string()") {
+		t.Fatalf("expected fragment fields to be merged into operation schema, got:\n%s", opSchema)
+	}
+	if strings.Count(opSchema, "id: z.string()") != 2 {
+		t.Fatalf("expected id once per selection set, got:\n%s", opSchema)
+	}
+}
+
+func TestTypeScriptGenerator_DiscriminatesInlineFragments(t *testing.T) {
+	s := mustBuildSchema(t, `type Query {
+  search(term: String!): [SearchResult!]!
+  node: Node
+}
+
+interface Node {
+  id: ID!
+}
+
+type User implements Node {
+  id: ID!
+  name: String
+}
+
+type Post implements Node {
+  id: ID!
+  title: String!
+}
+
+union SearchResult = User | Post`)
+
+	operations := mustParse(t, `query Search {
+  search(term: "gqlc") {
+    ... on User {
+      name
+    }
+    ... on Post {
+      title
+    }
+  }
+  node {
+    id
+  }
+}`)
+
+	var buf bytes.Buffer
+	if err := s.GenerateTypeScriptWithOperations(nil, operations, &buf); err != nil {
+		t.Fatalf("GenerateTypeScriptWithOperations returned error: %v", err)
+	}
+	output := buf.String()
+
+	for _, expected := range []string{
+		`search: z.array(z.discriminatedUnion("__typename", [`,
+		`__typename: z.literal("User"),
+      name: z.string().nullable()`,
+		`__typename: z.literal("Post"),
+      title: z.string()`,
+		`node: z.object({
+    id: z.string()
+  }).nullable()`,
+	} {
+		if !strings.Contains(output, expected) {
+			t.Fatalf("expected %q in output:\n%s", expected, output)
+		}
+	}
+
+	doc := s.AddTypenames(parser.NewDocument(operations))
+	search := doc.Operations[0].SelectionSet.Selections[0].(parser.Field)
+	if first, ok := search.SelectionSet.Selections[0].(parser.Field); !ok || first.Name != "__typename" {
+		t.Fatalf("expected __typename to be added to search selection, got %+v", search.SelectionSet.Selections)
+	}
+	node := doc.Operations[0].SelectionSet.Selections[1].(parser.Field)
+	if len(node.SelectionSet.Selections) != 1 {
+		t.Fatalf("expected node selection to be unchanged, got %+v", node.SelectionSet.Selections)
+	}
+}
+
+func TestTypeScriptGenerator_KeysFieldsByAlias(t *testing.T) {
+	s := mustBuildSchema(t, `type Query {
+  media: Media
+}
+
+enum ImageSize {
+  SMALL
+  LARGE
+}
+
+type Media {
+  coverImage(size: ImageSize): String
+  title: String!
+}`)
+
+	operations := mustParse(t, `query Cover {
+  media {
+    small: coverImage(size: SMALL)
+    large: coverImage(size: LARGE)
+    name: title
+  }
+}`)
+
+	var buf bytes.Buffer
+	if err := s.GenerateTypeScriptWithOperations(nil, operations, &buf); err != nil {
+		t.Fatalf("GenerateTypeScriptWithOperations returned error: %v", err)
+	}
+	output := buf.String()
+
+	for _, expected := range []string{
+		"small: z.string().nullable()",
+		"large: z.string().nullable()",
+		"name: z.string()",
+	} {
+		if !strings.Contains(output, expected) {
+			t.Fatalf("expected %q in output:\n%s", expected, output)
+		}
+	}
+
+	if got := parser.NewDocument(operations).FormattedOperationString(operations[0].(parser.OperationDefinition)); !strings.Contains(got, "small: coverImage(size: SMALL)") {
+		t.Fatalf("expected enum argument in document, got:\n%s", got)
+	}
+}
+
+func TestTypeScriptGenerator_RejectsConflictingResponseKeys(t *testing.T) {
+	s := mustBuildSchema(t, `type Query {
+  media: Media
+}
+
+type Media {
+  coverImage(size: String): String
+  title: String!
+}`)
+
+	tests := map[string]string{
+		"different fields": `query Conflict {
+  media {
+    title: coverImage
+    title
+  }
+}`,
+		"different arguments": `query Conflict {
+  media {
+    coverImage(size: "small")
+    ...Cover
+  }
+}
+
+fragment Cover on Media {
+  coverImage(size: "large")
+}`,
+	}
+
+	for name, src := range tests {
+		t.Run(name, func(t *testing.T) {
+			var buf bytes.Buffer
+			err := s.GenerateTypeScriptWithOperations(nil, mustParse(t, src), &buf)
+			if err == nil || !strings.Contains(err.Error(), "conflict") {
+				t.Fatalf("expected conflict error, got %v", err)
+			}
+		})
+	}
+}
+
+func TestTypeScriptGenerator_ConditionalSelectionsAreOptional(t *testing.T) {
+	s := mustBuildSchema(t, `type Query {
+  user: User
+}
+
+type User {
+  id: ID!
+  name: String!
+  email: String!
+  bio: String!
+}`)
+
+	operations := mustParse(t, `query Profile($withDetails: Boolean!, $hideBio: Boolean = false) {
+  user {
+    id @include(if: true)
+    name @include(if: $withDetails)
+    ... @include(if: $withDetails) {
+      email
+    }
+    ...Bio @skip(if: $hideBio)
+  }
+}
+
+fragment Bio on User {
+  bio
+}`)
+
+	var buf bytes.Buffer
+	if err := s.GenerateTypeScriptWithOperations(nil, operations, &buf); err != nil {
+		t.Fatalf("GenerateTypeScriptWithOperations returned error: %v", err)
+	}
+	output := buf.String()
+	opSchema := output[strings.Index(output, "export const Profile_Schema"):]
+
+	for _, expected := range []string{
+		"id: z.string(),",
+		"name: z.string().optional()",
+		"email: z.string().optional()",
+		"bio: z.string().optional()",
+	} {
+		if !strings.Contains(opSchema, expected) {
+			t.Fatalf("expected %q in output:\n%s", expected, opSchema)
+		}
+	}
+
+	if got := parser.NewDocument(operations).FormattedOperationString(operations[0].(parser.OperationDefinition)); !strings.Contains(got, "name @include(if: $withDetails)") || !strings.Contains(got, "...Bio @skip(if: $hideBio)") || !strings.Contains(got, "$hideBio: Boolean = false") {
+		t.Fatalf("expected directives in document, got:\n%s", got)
+	}
+
+	invalid := mustParse(t, `query Profile($withDetails: Boolean) {
+  user {
+    name @include(if: $withDetails)
+  }
+}`)
+	if err := s.GenerateTypeScriptWithOperations(nil, invalid, &buf); err == nil || !strings.Contains(err.Error(), "expected Boolean!") {
+		t.Fatalf("expected nullable condition variable to be rejected, got %v", err)
+	}
+}
+
+func mustBuildSchema(t *testing.T, sdl string) *Schema {
+	t.Helper()
+	s, err := buildSchemaFromAST(mustParse(t, sdl))
+	if err != nil {
+		t.Fatalf("failed to build schema: %v", err)
+	}
+	return s
+}
+
+func mustParse(t *testing.T, src string) []parser.AST {
+	t.Helper()
+	var nodes []parser.AST
+	for node := range parser.Parse(strings.NewReader(src)) {
+		nodes = append(nodes, node)
+	}
+	return nodes
+}
+
+func TestTypeScriptGenerator_GeneratesVariablesSchemas(t *testing.T) {
+	s := mustBuildSchema(t, `type Query {
+  search(term: String, limit: Int, filter: Filter, sort: [Sort!], note: String): [String!]!
+}
+
+enum Sort {
+  NEWEST
+  OLDEST
+}
+
+input Filter {
+  sort: Sort!
+  tags: [String!]
+  exact: Boolean! = false
+}`)
+
+	operations := mustParse(t, `query Search(
+  $term: String!
+  $limit: Int = 10
+  $filter: Filter
+  $sort: [Sort!] = [NEWEST]
+  $note: String = """
+    say \"""hi\"""
+      indented
+  """
+) {
+  search(term: $term, limit: $limit, filter: $filter, sort: $sort, note: $note)
+}`)
+
+	var buf bytes.Buffer
+	if err := s.GenerateTypeScriptWithOperations(nil, operations, &buf); err != nil {
+		t.Fatalf("GenerateTypeScriptWithOperations returned error: %v", err)
+	}
+	output := buf.String()
+
+	for _, expected := range []string{
+		`export const Search_Variables_Schema = z.object({
+  term: z.string(),
+  limit: z.number().int().nullable().default(10),
+  filter: Filter_Schema.nullable().optional(),
+  sort: z.array(Sort_Schema).nullable().default(["NEWEST"]),
+  note: z.string().nullable().default("say \"\"\"hi\"\"\"\n  indented")
+});
+export type Search_Variables = z.input<typeof Search_Variables_Schema>;`,
+		`  sort: Sort_Schema,
+  tags: z.array(z.string()).nullable().optional(),
+  exact: z.boolean().optional()`,
+	} {
+		if !strings.Contains(output, expected) {
+			t.Fatalf("expected %q in output:\n%s", expected, output)
+		}
+	}
+}
+
+func TestTypeScriptGenerator_MapsCustomScalars(t *testing.T) {
+	s := mustBuildSchema(t, `type Query {
+  events(since: DateTime): [Event!]!
+}
+
+scalar DateTime
+scalar Decimal
+scalar JSON
+
+type Event {
+  at: DateTime!
+  price: Decimal
+  payload: JSON
+}`)
+
+	operations := mustParse(t, `query Events($since: DateTime) {
+  events(since: $since) {
+    at
+    price
+    payload
+  }
+}`)
+
+	gen := &TypeScriptGenerator{Scalars: config.Scalars{
+		"DateTime": {Type: "Date", Zod: "z.coerce.date()"},
+		"Decimal":  {Type: "Big", Zod: "BigSchema", Import: "./big"},
+		"Upload":   {Type: "File", Import: "./unused"},
+	}}
+	var buf bytes.Buffer
+	if err := gen.GenerateWithOperations(s, nil, operations, &buf); err != nil {
+		t.Fatalf("GenerateWithOperations returned error: %v", err)
+	}
+	output := buf.String()
+
+	for _, expected := range []string{
+		`import { z } from "zod";
+import { type Big, BigSchema } from "./big";
+`,
+		`export const DateTime_Schema = z.coerce.date();
+export type DateTime = Date;`,
+		`since: DateTime_Schema.nullable().optional()`,
+		`at: z.coerce.date(),
+    price: BigSchema.nullable(),
+    payload: z.any().nullable()`,
+	} {
+		if !strings.Contains(output, expected) {
+			t.Fatalf("expected %q in output:\n%s", expected, output)
+		}
+	}
+	if strings.Contains(output, "./unused") {
+		t.Fatalf("expected no import for unused scalar in output:\n%s", output)
+	}
+}
+
+func TestTypeScriptGenerator_EmitsDescriptionsAsJSDoc(t *testing.T) {
+	s := mustBuildSchema(t, `type Query {
+  "Find users by their role"
+  users(role: Role): [User!]!
+}
+
+"""
+Access level of a user.
+  Checked on every request.
+"""
+enum Role {
+  "Full access"
+  ADMIN
+  USER
+}
+
+type User {
+  """The display name, never contains */"""
+  name: String
+}`)
+
+	operations := mustParse(t, `query Users($role: Role) {
+  users(role: $role) {
+    name
+  }
+}
+
+"All admins"
+query Admins {
+  users(role: ADMIN) {
+    name
+  }
+}`)
+
+	var buf bytes.Buffer
+	if err := s.GenerateTypeScriptWithOperations(nil, operations, &buf); err != nil {
+		t.Fatalf("GenerateTypeScriptWithOperations returned error: %v", err)
+	}
+	output := buf.String()
+
+	for _, expected := range []string{
+		`/**
+ * Access level of a user.
+ *   Checked on every request.
+ */
+export const Role_Schema = z.enum([
+  /** Full access */
+  "ADMIN",
+  "USER"
+]);`,
+		`// Schema for Users operation
+export const Users_Schema = z.object({
+  /** Find users by their role */
+  users: z.array(z.object({
+    /** The display name, never contains *\/ */
+    name: z.string().nullable()`,
+		`// Schema for Admins operation
+/** All admins */
+export const Admins_Schema = z.object({`,
+		`/** All admins */
+export type Admins_Type = z.infer<typeof Admins_Schema>;`,
+	} {
+		if !strings.Contains(output, expected) {
+			t.Fatalf("expected %q in output:\n%s", expected, output)
+		}
+	}
+	// The description of the root field belongs to the field, not to the operation
+	if count := strings.Count(output, "Find users by their role"); count != 2 {
+		t.Errorf("expected the root field description once per operation, got %d:\n%s", count, output)
+	}
+}
+
+func TestTypeScriptGenerator_TagsDeprecationsInJSDoc(t *testing.T) {
+	s := mustBuildSchema(t, `type Query {
+  users(role: Role): [User!]!
+}
+
+enum Role {
+  ADMIN
+  USER @deprecated
+}
+
+type User {
+  name: String
+  "The login name"
+  login: String @deprecated(reason: "Use name")
+}`)
+
+	operations := mustParse(t, `query Users($role: Role) {
+  users(role: $role) {
+    name
+    login
+  }
+}`)
+
+	var buf bytes.Buffer
+	if err := s.GenerateTypeScriptWithOperations(nil, operations, &buf); err != nil {
+		t.Fatalf("GenerateTypeScriptWithOperations returned error: %v", err)
+	}
+	output := buf.String()
+
+	for _, expected := range []string{
+		`export const Role_Schema = z.enum([
+  "ADMIN",
+  /** @deprecated No longer supported */
+  "USER"
+]);`,
+		`    name: z.string().nullable(),
+    /**
+     * The login name
+     * @deprecated Use name
+     */
+    login: z.string().nullable()`,
+	} {
+		if !strings.Contains(output, expected) {
+			t.Fatalf("expected %q in output:\n%s", expected, output)
+		}
+	}
+}
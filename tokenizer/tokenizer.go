@@ -406,6 +406,12 @@ func readTripleQuotedString(input []rune, pos *int, line *int, col *int, startLi
 			literal := string(input[start:*pos])
 			return Token{STRING, literal, startLine, startCol}
 		}
+		// An escaped triple quote doesn't end the string
+		if input[*pos] == '\\' && *pos+3 < len(input) && input[*pos+1] == '"' && input[*pos+2] == '"' && input[*pos+3] == '"' {
+			*pos += 4
+			*col += 4
+			continue
+		}
 
 		if input[*pos] == '\n' {
 			*line++
@@ -97,6 +97,15 @@ func TestTokenizer(t *testing.T) {
 				{tokenizer.EOF, "", 5, 2},
 			},
 		},
+		{
+			name:  "block string with escaped triple quotes",
+			input: `"""say \"""hi\""" """ x`,
+			expected: []tokenizer.Token{
+				{tokenizer.STRING, `"""say \"""hi\""" """`, 1, 1},
+				{tokenizer.IDENT, "x", 1, 23},
+				{tokenizer.EOF, "", 1, 24},
+			},
+		},
 	}
 
 	for _, test := range tests {
@@ -0,0 +1,107 @@
+package validate
+
+import (
+	"gqlc/parser"
+	"gqlc/schema"
+)
+
+// Deprecations returns a warning for every deprecated field selected and every deprecated enum value
+// passed by the operations and fragments of the document. The document is expected to be valid.
+func Deprecations(sch *schema.Schema, doc parser.Document) Errors {
+	v := &validator{
+		schema: sch,
+		doc:    doc,
+	}
+
+	for _, od := range doc.Operations {
+		for _, vd := range od.Variables {
+			if vd.DefaultValue != nil {
+				v.checkValueDeprecations(*vd.DefaultValue, namedType(vd.Type), vd.Loc)
+			}
+		}
+		if rootType := v.rootType(od.Type); rootType != nil {
+			v.checkDeprecations(od.SelectionSet, rootType)
+		}
+	}
+	for _, fd := range doc.Fragments {
+		if typeCondition, ok := sch.Types[fd.TypeName]; ok {
+			v.checkDeprecations(fd.SelectionSet, &typeCondition)
+		}
+	}
+
+	v.errors.Sort()
+	return v.errors
+}
+
+// checkDeprecations checks the fields and arguments of the selection set, fragment spreads are checked with their fragment
+func (v *validator) checkDeprecations(ss parser.SelectionSet, parentType *schema.TypeDefinition) {
+	for _, sel := range ss.Selections {
+		switch s := sel.(type) {
+		case parser.Field:
+			fieldDef := findField(parentType, s.Name)
+			if fieldDef == nil {
+				continue
+			}
+			if fieldDef.IsDeprecated {
+				v.errorf(s.Loc, "field \"%s.%s\" is deprecated%s", parentType.Name, s.Name, reasonSuffix(fieldDef.DeprecationReason))
+			}
+			for _, arg := range s.Arguments {
+				if argDef := findArgument(fieldDef.Args, arg.Name); argDef != nil {
+					v.checkValueDeprecations(arg.Value, baseTypeName(argDef.Type), arg.Loc)
+				}
+			}
+			if s.SelectionSet != nil {
+				if fieldType, ok := v.schema.Types[baseTypeName(fieldDef.Type)]; ok {
+					v.checkDeprecations(*s.SelectionSet, &fieldType)
+				}
+			}
+		case parser.InlineFragment:
+			fragmentType := parentType
+			if s.TypeName != nil {
+				typeCondition, ok := v.schema.Types[*s.TypeName]
+				if !ok {
+					continue
+				}
+				fragmentType = &typeCondition
+			}
+			v.checkDeprecations(s.SelectionSet, fragmentType)
+		}
+	}
+}
+
+// checkValueDeprecations checks the enum values used in a value of the named type.
+// Values have no location of their own, so the location of the argument or variable is used.
+func (v *validator) checkValueDeprecations(value parser.Value, typeName string, loc parser.Location) {
+	typeDef, ok := v.schema.Types[typeName]
+	if !ok {
+		return
+	}
+	switch val := value.(type) {
+	case parser.EnumValue:
+		if typeDef.Kind != "ENUM" {
+			return
+		}
+		for _, enumValue := range typeDef.EnumValues {
+			if enumValue.Name == val.Value && enumValue.IsDeprecated {
+				v.errorf(loc, "enum value \"%s.%s\" is deprecated%s", typeName, val.Value, reasonSuffix(enumValue.DeprecationReason))
+			}
+		}
+	case parser.ListValue:
+		for _, item := range val.Values {
+			v.checkValueDeprecations(item, typeName, loc)
+		}
+	case parser.ObjectValue:
+		for _, field := range val.Fields {
+			if fieldDef := findArgument(typeDef.InputFields, field.Name); fieldDef != nil {
+				v.checkValueDeprecations(field.Value, baseTypeName(fieldDef.Type), loc)
+			}
+		}
+	}
+}
+
+func reasonSuffix(reason *string) string {
+	if reason == nil || *reason == "" {
+		return ""
+	}
+	return ": " + *reason
+}
@@ -0,0 +1,592 @@
+package validate
+
+import (
+	"fmt"
+	"gqlc/parser"
+	"gqlc/schema"
+	"sort"
+	"strings"
+)
+
+// Error is a validation error at a location in an operation document
+type Error struct {
+	Loc     parser.Location
+	Message string
+}
+
+func (e Error) Error() string {
+	return fmt.Sprintf("%s: %s", e.Loc, e.Message)
+}
+
+// Errors is a list of validation errors, sorted by location
+type Errors []Error
+
+func (e Errors) Error() string {
+	messages := make([]string, len(e))
+	for i, err := range e {
+		messages[i] = err.Error()
+	}
+	return strings.Join(messages, "\n")
+}
+
+// Sort sorts the errors by file, line and column
+func (e Errors) Sort() {
+	sort.SliceStable(e, func(i, j int) bool {
+		a, b := e[i].Loc, e[j].Loc
+		if a.File != b.File {
+			return a.File < b.File
+		}
+		if a.Line != b.Line {
+			return a.Line < b.Line
+		}
+		return a.Column < b.Column
+	})
+}
+
+// Validate runs the executable validation rules of the GraphQL specification
+// on the operations and fragments of the document against the schema
+func Validate(sch *schema.Schema, doc parser.Document) Errors {
+	v := &validator{
+		schema:    sch,
+		doc:       doc,
+		fragments: make(map[string]parser.FragmentDefinition),
+	}
+
+	v.validateOperationNames()
+	v.validateFragmentDefinitions()
+	v.validateFragmentCycles()
+
+	for _, od := range doc.Operations {
+		v.validateOperation(od)
+	}
+	for _, fd := range doc.Fragments {
+		if typeCondition, ok := sch.Types[fd.TypeName]; ok && isComposite(typeCondition.Kind) {
+			v.validateSelectionSet(fd.SelectionSet, &typeCondition)
+		}
+	}
+
+	v.errors.Sort()
+	return v.errors
+}
+
+type validator struct {
+	schema    *schema.Schema
+	doc       parser.Document
+	fragments map[string]parser.FragmentDefinition
+	errors    Errors
+}
+
+func (v *validator) errorf(loc parser.Location, format string, args ...any) {
+	v.errors = append(v.errors, Error{Loc: loc, Message: fmt.Sprintf(format, args...)})
+}
+
+// validateOperationNames checks that every named operation is unique
+func (v *validator) validateOperationNames() {
+	seen := make(map[string]parser.Location)
+	for _, od := range v.doc.Operations {
+		if od.Name == nil {
+			continue
+		}
+		if first, ok := seen[*od.Name]; ok {
+			v.errorf(od.Loc, "there can be only one operation named %q, first defined at %s", *od.Name, first)
+			continue
+		}
+		seen[*od.Name] = od.Loc
+	}
+}
+
+// validateFragmentDefinitions checks that fragment names are unique and their type conditions are composite types
+func (v *validator) validateFragmentDefinitions() {
+	for _, fd := range v.doc.Fragments {
+		if first, ok := v.fragments[fd.Name]; ok {
+			v.errorf(fd.Loc, "there can be only one fragment named %q, first defined at %s", fd.Name, first.Loc)
+			continue
+		}
+		v.fragments[fd.Name] = fd
+		v.validateTypeCondition(fd.TypeName, fd.Loc)
+	}
+}
+
+func (v *validator) validateTypeCondition(typeName string, loc parser.Location) bool {
+	typeDef, ok := v.schema.Types[typeName]
+	if !ok {
+		v.errorf(loc, "unknown type %q", typeName)
+		return false
+	}
+	if !isComposite(typeDef.Kind) {
+		v.errorf(loc, "fragment cannot condition on non composite type %q", typeName)
+		return false
+	}
+	return true
+}
+
+// validateFragmentCycles checks that no fragment spreads itself, directly or through other fragments
+func (v *validator) validateFragmentCycles() {
+	done := make(map[string]bool)
+	for _, fd := range v.doc.Fragments {
+		if !done[fd.Name] {
+			v.detectCycle(fd, done, nil, make(map[string]int))
+		}
+	}
+}
+
+func (v *validator) detectCycle(fd parser.FragmentDefinition, done map[string]bool, path []parser.FragmentSpread, pathIndex map[string]int) {
+	done[fd.Name] = true
+	pathIndex[fd.Name] = len(path)
+
+	for _, spread := range fragmentSpreads(fd.SelectionSet) {
+		next, ok := v.fragments[spread.Name]
+		if !ok {
+			continue
+		}
+		if index, inPath := pathIndex[spread.Name]; inPath {
+			cyclePath := append(append([]parser.FragmentSpread(nil), path[index:]...), spread)
+			via := make([]string, 0, len(cyclePath)-1)
+			for _, s := range cyclePath[:len(cyclePath)-1] {
+				via = append(via, s.Name)
+			}
+			message := fmt.Sprintf("cannot spread fragment %q within itself", spread.Name)
+			if len(via) > 0 {
+				message += " via " + strings.Join(via, ", ")
+			}
+			v.errorf(cyclePath[0].Loc, "%s", message)
+			continue
+		}
+		if !done[spread.Name] {
+			v.detectCycle(next, done, append(path, spread), pathIndex)
+		}
+	}
+
+	delete(pathIndex, fd.Name)
+}
+
+// fragmentSpreads returns the fragment spreads of the selection set, including nested selections
+func fragmentSpreads(ss parser.SelectionSet) []parser.FragmentSpread {
+	var spreads []parser.FragmentSpread
+	for _, sel := range ss.Selections {
+		switch s := sel.(type) {
+		case parser.Field:
+			if s.SelectionSet != nil {
+				spreads = append(spreads, fragmentSpreads(*s.SelectionSet)...)
+			}
+		case parser.InlineFragment:
+			spreads = append(spreads, fragmentSpreads(s.SelectionSet)...)
+		case parser.FragmentSpread:
+			spreads = append(spreads, s)
+		}
+	}
+	return spreads
+}
+
+func (v *validator) rootType(opType parser.OperationType) *schema.TypeDefinition {
+	switch opType {
+	case parser.Query:
+		return v.schema.Query
+	case parser.Mutation:
+		return v.schema.Mutation
+	case parser.Subscription:
+		return v.schema.Subscription
+	default:
+		return nil
+	}
+}
+
+// validateOperation validates the selection set and variables of an operation
+func (v *validator) validateOperation(od parser.OperationDefinition) {
+	rootType := v.rootType(od.Type)
+	if rootType == nil {
+		v.errorf(od.Loc, "schema does not define a %s root type", od.Type)
+		return
+	}
+
+	variables := make(map[string]parser.VariableDefinition)
+	for _, vd := range od.Variables {
+		if _, ok := variables[vd.Name]; ok {
+			v.errorf(vd.Loc, "there can be only one variable named \"$%s\"", vd.Name)
+			continue
+		}
+		variables[vd.Name] = vd
+
+		typeName := namedType(vd.Type)
+		typeDef, ok := v.schema.Types[typeName]
+		if !ok {
+			v.errorf(vd.Loc, "unknown type %q", typeName)
+		} else if !isInput(typeDef.Kind) {
+			v.errorf(vd.Loc, "variable \"$%s\" cannot be non-input type %q", vd.Name, vd.Type)
+		}
+	}
+
+	v.validateSelectionSet(od.SelectionSet, rootType)
+
+	// Check variable usages in the operation and all fragments it uses
+	var usages []variableUsage
+	v.collectVariableUsages(od.SelectionSet, rootType, make(map[string]bool), &usages)
+	used := make(map[string]bool)
+	for _, usage := range usages {
+		used[usage.variable.Name] = true
+		vd, ok := variables[usage.variable.Name]
+		if !ok {
+			if od.Name != nil {
+				v.errorf(usage.variable.Loc, "variable \"$%s\" is not defined by operation %q", usage.variable.Name, *od.Name)
+			} else {
+				v.errorf(usage.variable.Loc, "variable \"$%s\" is not defined", usage.variable.Name)
+			}
+			continue
+		}
+		if usage.locationType == nil {
+			continue
+		}
+		if !v.isVariableAllowed(vd, *usage.locationType, usage.hasLocationDefault) {
+			v.errorf(usage.variable.Loc, "variable \"$%s\" of type %q used in position expecting type %q", vd.Name, vd.Type, usage.locationType)
+		}
+	}
+	for _, vd := range od.Variables {
+		if !used[vd.Name] {
+			if od.Name != nil {
+				v.errorf(vd.Loc, "variable \"$%s\" is never used in operation %q", vd.Name, *od.Name)
+			} else {
+				v.errorf(vd.Loc, "variable \"$%s\" is never used", vd.Name)
+			}
+		}
+	}
+}
+
+// validateSelectionSet checks fields, arguments, leaf selections and fragment spreads of the selection set
+func (v *validator) validateSelectionSet(ss parser.SelectionSet, parentType *schema.TypeDefinition) {
+	for _, sel := range ss.Selections {
+		switch s := sel.(type) {
+		case parser.Field:
+			v.validateDirectives(s.Directives)
+			v.validateField(s, parentType)
+		case parser.InlineFragment:
+			v.validateDirectives(s.Directives)
+			fragmentType := parentType
+			if s.TypeName != nil {
+				if !v.validateTypeCondition(*s.TypeName, s.Loc) {
+					continue
+				}
+				typeCondition := v.schema.Types[*s.TypeName]
+				if !v.typesOverlap(typeCondition.Name, parentType.Name) {
+					v.errorf(s.Loc, "fragment cannot be spread here as objects of type %q can never be of type %q", parentType.Name, typeCondition.Name)
+					continue
+				}
+				fragmentType = &typeCondition
+			}
+			v.validateSelectionSet(s.SelectionSet, fragmentType)
+		case parser.FragmentSpread:
+			v.validateDirectives(s.Directives)
+			fd, ok := v.fragments[s.Name]
+			if !ok {
+				v.errorf(s.Loc, "unknown fragment %q", s.Name)
+				continue
+			}
+			if _, ok := v.schema.Types[fd.TypeName]; !ok {
+				continue
+			}
+			if !v.typesOverlap(fd.TypeName, parentType.Name) {
+				v.errorf(s.Loc, "fragment %q cannot be spread here as objects of type %q can never be of type %q", s.Name, parentType.Name, fd.TypeName)
+			}
+		}
+	}
+}
+
+func (v *validator) validateField(f parser.Field, parentType *schema.TypeDefinition) {
+	if isIntrospectionField(f.Name, parentType, v.schema) {
+		return
+	}
+
+	fieldDef := findField(parentType, f.Name)
+	if fieldDef == nil {
+		v.errorf(f.Loc, "cannot query field %q on type %q", f.Name, parentType.Name)
+		return
+	}
+
+	v.validateArguments(f.Arguments, fieldDef.Args, f.Loc, fmt.Sprintf("field %q", f.Name))
+
+	typeName := baseTypeName(fieldDef.Type)
+	fieldType, ok := v.schema.Types[typeName]
+	if !ok {
+		return
+	}
+	if isComposite(fieldType.Kind) {
+		if f.SelectionSet == nil {
+			v.errorf(f.Loc, "field %q of type %q must have a selection of subfields", f.Name, fieldDef.Type)
+			return
+		}
+		v.validateSelectionSet(*f.SelectionSet, &fieldType)
+	} else if f.SelectionSet != nil {
+		v.errorf(f.Loc, "field %q must not have a selection since type %q has no subfields", f.Name, fieldDef.Type)
+	}
+}
+
+// validateArguments checks that all given arguments are known and all required arguments are given
+func (v *validator) validateArguments(args []parser.Argument, defs []schema.InputValueDefinition, loc parser.Location, owner string) {
+	given := make(map[string]bool)
+	for _, arg := range args {
+		if given[arg.Name] {
+			v.errorf(arg.Loc, "there can be only one argument named %q", arg.Name)
+			continue
+		}
+		given[arg.Name] = true
+		if findArgument(defs, arg.Name) == nil {
+			v.errorf(arg.Loc, "unknown argument %q on %s", arg.Name, owner)
+		}
+	}
+	for _, def := range defs {
+		if def.Type.Kind == "NON_NULL" && def.DefaultValue == nil && !given[def.Name] {
+			v.errorf(loc, "%s argument %q of type %q is required, but it was not provided", owner, def.Name, def.Type)
+		}
+	}
+}
+
+// validateDirectives checks the arguments of the built-in @include and @skip directives
+func (v *validator) validateDirectives(directives []parser.Directive) {
+	for _, d := range directives {
+		if defs, ok := builtInDirectiveArgs[d.Name]; ok {
+			v.validateArguments(d.Arguments, defs, d.Loc, fmt.Sprintf("directive \"@%s\"", d.Name))
+		}
+	}
+}
+
+var builtInDirectiveArgs = map[string][]schema.InputValueDefinition{
+	"include": {{Name: "if", Type: nonNullBoolean()}},
+	"skip":    {{Name: "if", Type: nonNullBoolean()}},
+}
+
+func nonNullBoolean() schema.TypeRef {
+	name := "Boolean"
+	return schema.TypeRef{Kind: "NON_NULL", OfType: &schema.TypeRef{Kind: "SCALAR", Name: &name}}
+}
+
+// variableUsage is a variable used in an argument value together with the type expected at its position
+type variableUsage struct {
+	variable           parser.Variable
+	locationType       *schema.TypeRef
+	hasLocationDefault bool
+}
+
+// collectVariableUsages collects all variables used in the selection set and the fragments it spreads
+func (v *validator) collectVariableUsages(ss parser.SelectionSet, parentType *schema.TypeDefinition, visited map[string]bool, usages *[]variableUsage) {
+	for _, sel := range ss.Selections {
+		switch s := sel.(type) {
+		case parser.Field:
+			v.collectDirectiveVariableUsages(s.Directives, usages)
+			var fieldDef *schema.FieldDefinition
+			if parentType != nil {
+				fieldDef = findField(parentType, s.Name)
+			}
+			for _, arg := range s.Arguments {
+				var argDef *schema.InputValueDefinition
+				if fieldDef != nil {
+					argDef = findArgument(fieldDef.Args, arg.Name)
+				}
+				v.collectValueVariableUsages(arg.Value, argDef, usages)
+			}
+			if s.SelectionSet != nil {
+				var fieldType *schema.TypeDefinition
+				if fieldDef != nil {
+					if td, ok := v.schema.Types[baseTypeName(fieldDef.Type)]; ok {
+						fieldType = &td
+					}
+				}
+				v.collectVariableUsages(*s.SelectionSet, fieldType, visited, usages)
+			}
+		case parser.InlineFragment:
+			v.collectDirectiveVariableUsages(s.Directives, usages)
+			fragmentType := parentType
+			if s.TypeName != nil {
+				fragmentType = nil
+				if td, ok := v.schema.Types[*s.TypeName]; ok {
+					fragmentType = &td
+				}
+			}
+			v.collectVariableUsages(s.SelectionSet, fragmentType, visited, usages)
+		case parser.FragmentSpread:
+			v.collectDirectiveVariableUsages(s.Directives, usages)
+			fd, ok := v.fragments[s.Name]
+			if !ok || visited[s.Name] {
+				continue
+			}
+			visited[s.Name] = true
+			var fragmentType *schema.TypeDefinition
+			if td, ok := v.schema.Types[fd.TypeName]; ok {
+				fragmentType = &td
+			}
+			v.collectVariableUsages(fd.SelectionSet, fragmentType, visited, usages)
+		}
+	}
+}
+
+func (v *validator) collectDirectiveVariableUsages(directives []parser.Directive, usages *[]variableUsage) {
+	for _, d := range directives {
+		defs := builtInDirectiveArgs[d.Name]
+		for _, arg := range d.Arguments {
+			v.collectValueVariableUsages(arg.Value, findArgument(defs, arg.Name), usages)
+		}
+	}
+}
+
+// collectValueVariableUsages collects the variables used in a value, def describes the position of the value if known
+func (v *validator) collectValueVariableUsages(value parser.Value, def *schema.InputValueDefinition, usages *[]variableUsage) {
+	switch val := value.(type) {
+	case parser.Variable:
+		usage := variableUsage{variable: val}
+		if def != nil {
+			locationType := def.Type
+			usage.locationType = &locationType
+			usage.hasLocationDefault = def.DefaultValue != nil
+		}
+		*usages = append(*usages, usage)
+	case parser.ListValue:
+		var itemDef *schema.InputValueDefinition
+		if def != nil {
+			if itemType := unwrapNonNull(def.Type); itemType.Kind == "LIST" && itemType.OfType != nil {
+				itemDef = &schema.InputValueDefinition{Name: def.Name, Type: *itemType.OfType}
+			}
+		}
+		for _, item := range val.Values {
+			v.collectValueVariableUsages(item, itemDef, usages)
+		}
+	case parser.ObjectValue:
+		var inputType *schema.TypeDefinition
+		if def != nil {
+			if td, ok := v.schema.Types[baseTypeName(def.Type)]; ok && td.Kind == "INPUT_OBJECT" {
+				inputType = &td
+			}
+		}
+		for _, field := range val.Fields {
+			var fieldDef *schema.InputValueDefinition
+			if inputType != nil {
+				fieldDef = findArgument(inputType.InputFields, field.Name)
+			}
+			v.collectValueVariableUsages(field.Value, fieldDef, usages)
+		}
+	}
+}
+
+// isVariableAllowed checks if a variable of the defined type can be used at a position of the location type
+func (v *validator) isVariableAllowed(vd parser.VariableDefinition, locationType schema.TypeRef, hasLocationDefault bool) bool {
+	if locationType.Kind == "NON_NULL" {
+		if _, isNonNull := vd.Type.(parser.NonNullType); !isNonNull {
+			hasNonNullDefault := false
+			if vd.DefaultValue != nil {
+				_, isNull := (*vd.DefaultValue).(parser.NullValue)
+				hasNonNullDefault = !isNull
+			}
+			if !hasNonNullDefault && !hasLocationDefault {
+				return false
+			}
+			if locationType.OfType == nil {
+				return false
+			}
+			return isTypeSubTypeOf(vd.Type, *locationType.OfType)
+		}
+	}
+	return isTypeSubTypeOf(vd.Type, locationType)
+}
+
+func isTypeSubTypeOf(varType parser.Type, locationType schema.TypeRef) bool {
+	if locationType.Kind == "NON_NULL" {
+		nonNull, ok := varType.(parser.NonNullType)
+		if !ok || locationType.OfType == nil {
+			return false
+		}
+		return isTypeSubTypeOf(nonNull.Type, *locationType.OfType)
+	}
+	if nonNull, ok := varType.(parser.NonNullType); ok {
+		return isTypeSubTypeOf(nonNull.Type, locationType)
+	}
+	if locationType.Kind == "LIST" {
+		list, ok := varType.(parser.ListType)
+		if !ok || locationType.OfType == nil {
+			return false
+		}
+		return isTypeSubTypeOf(list.Type, *locationType.OfType)
+	}
+	if _, ok := varType.(parser.ListType); ok {
+		return false
+	}
+	named, ok := varType.(parser.NamedType)
+	return ok && locationType.Name != nil && named.Name == *locationType.Name
+}
+
+// typesOverlap checks if an object can be of both types
+func (v *validator) typesOverlap(a, b string) bool {
+	possible := make(map[string]bool)
+	for _, name := range v.schema.PossibleTypes(a) {
+		possible[name] = true
+	}
+	for _, name := range v.schema.PossibleTypes(b) {
+		if possible[name] {
+			return true
+		}
+	}
+	return false
+}
+
+func isIntrospectionField(name string, parentType *schema.TypeDefinition, sch *schema.Schema) bool {
+	switch name {
+	case "__typename":
+		return true
+	case "__schema", "__type":
+		return sch.Query != nil && parentType.Name == sch.Query.Name
+	default:
+		return false
+	}
+}
+
+func findField(typeDef *schema.TypeDefinition, name string) *schema.FieldDefinition {
+	for i := range typeDef.Fields {
+		if typeDef.Fields[i].Name == name {
+			return &typeDef.Fields[i]
+		}
+	}
+	return nil
+}
+
+func findArgument(defs []schema.InputValueDefinition, name string) *schema.InputValueDefinition {
+	for i := range defs {
+		if defs[i].Name == name {
+			return &defs[i]
+		}
+	}
+	return nil
+}
+
+func isComposite(kind string) bool {
+	return kind == "OBJECT" || kind == "INTERFACE" || kind == "UNION"
+}
+
+func isInput(kind string) bool {
+	return kind == "SCALAR" || kind == "ENUM" || kind == "INPUT_OBJECT"
+}
+
+func namedType(t parser.Type) string {
+	switch typ := t.(type) {
+	case parser.NamedType:
+		return typ.Name
+	case parser.ListType:
+		return namedType(typ.Type)
+	case parser.NonNullType:
+		return namedType(typ.Type)
+	default:
+		return ""
+	}
+}
+
+func baseTypeName(t schema.TypeRef) string {
+	if t.Name != nil {
+		return *t.Name
+	}
+	if t.OfType != nil {
+		return baseTypeName(*t.OfType)
+	}
+	return ""
+}
+
+func unwrapNonNull(t schema.TypeRef) schema.TypeRef {
+	if t.Kind == "NON_NULL" && t.OfType != nil {
+		return *t.OfType
+	}
+	return t
+}
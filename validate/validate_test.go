@@ -0,0 +1,273 @@
+package validate_test
+
+import (
+	"gqlc/config"
+	"gqlc/parser"
+	"gqlc/schema"
+	"gqlc/validate"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+const testSchema = `type Query {
+  user(id: ID!): User
+  search(term: String!, limit: Int = 10): [SearchResult!]!
+  node(id: ID!): Node
+}
+
+interface Node {
+  id: ID!
+}
+
+type User implements Node {
+  id: ID!
+  name: String
+  avatar(size: Int!): String
+  friends(filter: FriendFilter): [User!]!
+}
+
+type Post implements Node {
+  id: ID!
+  title: String!
+}
+
+type Comment {
+  text: String!
+}
+
+union SearchResult = User | Post
+
+input FriendFilter {
+  ids: [ID!]
+  online: Boolean!
+}`
+
+func TestValidate(t *testing.T) {
+	sch := loadSchema(t, testSchema)
+
+	tests := []struct {
+		name     string
+		input    string
+		expected []string
+	}{
+		{
+			name: "valid operation",
+			input: `query GetUser($id: ID!, $withName: Boolean!, $ids: [ID!]) {
+  user(id: $id) {
+    __typename
+    ...UserFields
+    name @include(if: $withName)
+    friends(filter: {ids: $ids, online: true}) {
+      id
+    }
+  }
+  search(term: "gqlc") {
+    ... on Post {
+      title
+    }
+  }
+}
+
+fragment UserFields on User {
+  id
+  avatar(size: 64)
+}`,
+		},
+		{
+			name: "unknown field",
+			input: `query {
+  user(id: "1") {
+    email
+  }
+}`,
+			expected: []string{`test.graphql:3:5: cannot query field "email" on type "User"`},
+		},
+		{
+			name: "unknown and missing arguments",
+			input: `query {
+  user(userId: "1") {
+    avatar
+  }
+}`,
+			expected: []string{
+				`test.graphql:2:3: field "user" argument "id" of type "ID!" is required, but it was not provided`,
+				`test.graphql:2:8: unknown argument "userId" on field "user"`,
+				`test.graphql:3:5: field "avatar" argument "size" of type "Int!" is required, but it was not provided`,
+			},
+		},
+		{
+			name: "leaf field selections",
+			input: `query {
+  user(id: "1") {
+    name {
+      length
+    }
+  }
+  node(id: "1")
+}`,
+			expected: []string{
+				`test.graphql:3:5: field "name" must not have a selection since type "String" has no subfields`,
+				`test.graphql:7:3: field "node" of type "Node" must have a selection of subfields`,
+			},
+		},
+		{
+			name: "undefined and unused variables",
+			input: `query GetUser($unused: Int) {
+  user(id: $id) {
+    id
+  }
+}`,
+			expected: []string{
+				`test.graphql:1:15: variable "$unused" is never used in operation "GetUser"`,
+				`test.graphql:2:12: variable "$id" is not defined by operation "GetUser"`,
+			},
+		},
+		{
+			name: "variable type compatibility",
+			input: `query GetUser($id: ID, $limit: Int, $online: Boolean, $size: String!) {
+  user(id: $id) {
+    avatar(size: $size)
+    friends(filter: {online: $online}) {
+      id
+    }
+  }
+  search(term: "a", limit: $limit) {
+    __typename
+  }
+}`,
+			expected: []string{
+				`test.graphql:2:12: variable "$id" of type "ID" used in position expecting type "ID!"`,
+				`test.graphql:3:18: variable "$size" of type "String!" used in position expecting type "Int!"`,
+				`test.graphql:4:30: variable "$online" of type "Boolean" used in position expecting type "Boolean!"`,
+			},
+		},
+		{
+			name: "unique operation names",
+			input: `query A { node(id: "1") { id } }
+query A { node(id: "2") { id } }`,
+			expected: []string{`test.graphql:2:1: there can be only one operation named "A", first defined at test.graphql:1:1`},
+		},
+		{
+			name: "unknown fragments and fragment cycles",
+			input: `query {
+  user(id: "1") {
+    ...Missing
+    ...A
+  }
+}
+
+fragment A on User {
+  ...B
+}
+
+fragment B on User {
+  ...A
+}`,
+			expected: []string{
+				`test.graphql:3:5: unknown fragment "Missing"`,
+				`test.graphql:9:3: cannot spread fragment "A" within itself via B`,
+			},
+		},
+		{
+			name: "possible fragment spreads",
+			input: `query {
+  user(id: "1") {
+    ... on Post {
+      title
+    }
+    ...CommentFields
+  }
+}
+
+fragment CommentFields on Comment {
+  text
+}`,
+			expected: []string{
+				`test.graphql:3:5: fragment cannot be spread here as objects of type "User" can never be of type "Post"`,
+				`test.graphql:6:5: fragment "CommentFields" cannot be spread here as objects of type "User" can never be of type "Comment"`,
+			},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			var nodes []parser.AST
+			for ast := range parser.ParseFile("test.graphql", strings.NewReader(test.input)) {
+				nodes = append(nodes, ast)
+			}
+
+			errs := validate.Validate(sch, parser.NewDocument(nodes))
+			if len(errs) != len(test.expected) {
+				t.Fatalf("expected %d errors, got %d:\n%s", len(test.expected), len(errs), errs.Error())
+			}
+			for i, err := range errs {
+				if err.Error() != test.expected[i] {
+					t.Errorf("expected error %q, got %q", test.expected[i], err.Error())
+				}
+			}
+		})
+	}
+}
+
+func TestDeprecations(t *testing.T) {
+	sch := loadSchema(t, `type Query {
+  users(roles: [Role!], filter: UserFilter): [User!]!
+}
+
+type User {
+  name: String
+  login: String @deprecated(reason: "Use name")
+  nick: String @deprecated
+}
+
+enum Role {
+  ADMIN
+  GUEST @deprecated(reason: "Guests are users now")
+}
+
+input UserFilter {
+  role: Role
+}`)
+
+	var nodes []parser.AST
+	for ast := range parser.ParseFile("test.graphql", strings.NewReader(`query Users($role: Role = GUEST) {
+  users(roles: [ADMIN, GUEST], filter: { role: GUEST }) {
+    name
+    ...UserFields
+  }
+}
+
+fragment UserFields on User {
+  login
+  nick
+}`)) {
+		nodes = append(nodes, ast)
+	}
+
+	expected := []string{
+		`test.graphql:1:13: enum value "Role.GUEST" is deprecated: Guests are users now`,
+		`test.graphql:2:9: enum value "Role.GUEST" is deprecated: Guests are users now`,
+		`test.graphql:2:32: enum value "Role.GUEST" is deprecated: Guests are users now`,
+		`test.graphql:9:3: field "User.login" is deprecated: Use name`,
+		`test.graphql:10:3: field "User.nick" is deprecated: No longer supported`,
+	}
+	warnings := validate.Deprecations(sch, parser.NewDocument(nodes))
+	if warnings.Error() != strings.Join(expected, "\n") {
+		t.Errorf("expected warnings\n%s\ngot\n%s", strings.Join(expected, "\n"), warnings.Error())
+	}
+}
+
+func loadSchema(t *testing.T, sdl string) *schema.Schema {
+	t.Helper()
+	dir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(dir, "schema.graphql"), []byte(sdl), 0644); err != nil {
+		t.Fatalf("failed to write schema: %v", err)
+	}
+	sch, err := schema.Load(config.Config{Input: config.Input{Schemas: config.SchemaSources{{Path: dir}}}})
+	if err != nil {
+		t.Fatalf("failed to load schema: %v", err)
+	}
+	return sch
+}
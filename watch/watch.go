@@ -0,0 +1,83 @@
+package watch
+
+import (
+	"gqlc/fs"
+	"os"
+	"sort"
+	"time"
+)
+
+// fileState is what a Watcher remembers about a file to detect changes
+type fileState struct {
+	modTime time.Time
+	size    int64
+}
+
+// Watcher detects changes to the GraphQL files below a set of paths by polling the file system.
+// Polling works on every platform and for every kind of file system, including network mounts and containers.
+type Watcher struct {
+	paths []string
+	files map[string]fileState
+}
+
+// New creates a Watcher for the GraphQL files below the given files or directories
+func New(paths ...string) *Watcher {
+	w := &Watcher{paths: paths}
+	w.files = w.scan()
+	return w
+}
+
+// Files returns the paths of all GraphQL files found by the last scan, sorted
+func (w *Watcher) Files() []string {
+	files := make([]string, 0, len(w.files))
+	for path := range w.files {
+		files = append(files, path)
+	}
+	sort.Strings(files)
+	return files
+}
+
+// Poll scans the watched paths and returns the files that were created, modified or removed since the last scan, sorted
+func (w *Watcher) Poll() []string {
+	files := w.scan()
+
+	var changed []string
+	for path, state := range files {
+		if previous, ok := w.files[path]; !ok || previous != state {
+			changed = append(changed, path)
+		}
+	}
+	for path := range w.files {
+		if _, ok := files[path]; !ok {
+			changed = append(changed, path)
+		}
+	}
+	sort.Strings(changed)
+
+	w.files = files
+	return changed
+}
+
+// scan collects the state of all GraphQL files below the watched paths.
+// Watched files are scanned regardless of their extension, e.g. an introspection result in a .json file.
+// Paths that don't exist (yet) or contain no GraphQL files are treated as empty.
+func (w *Watcher) scan() map[string]fileState {
+	files := make(map[string]fileState)
+	for _, root := range w.paths {
+		paths, err := fs.CollectGraphQLPaths(root)
+		if err != nil {
+			if stat, err := os.Stat(root); err == nil && !stat.IsDir() {
+				files[root] = fileState{modTime: stat.ModTime(), size: stat.Size()}
+			}
+			continue
+		}
+		for _, path := range paths {
+			stat, err := os.Stat(path)
+			if err != nil {
+				continue
+			}
+			files[path] = fileState{modTime: stat.ModTime(), size: stat.Size()}
+		}
+	}
+	return files
+}
@@ -0,0 +1,70 @@
+package watch_test
+
+import (
+	"gqlc/watch"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestPoll(t *testing.T) {
+	dir := t.TempDir()
+	a := filepath.Join(dir, "a.graphql")
+	b := filepath.Join(dir, "b.graphql")
+	writeFile(t, a, "query A { a }")
+	writeFile(t, b, "query B { b }")
+	writeFile(t, filepath.Join(dir, "notes.txt"), "not GraphQL")
+
+	w := watch.New(dir)
+	if files := w.Files(); !reflect.DeepEqual(files, []string{a, b}) {
+		t.Fatalf("expected files %v, got %v", []string{a, b}, files)
+	}
+	if changed := w.Poll(); len(changed) != 0 {
+		t.Fatalf("expected no changes, got %v", changed)
+	}
+
+	c := filepath.Join(dir, "nested", "c.gql")
+	if err := os.MkdirAll(filepath.Dir(c), 0755); err != nil {
+		t.Fatal(err)
+	}
+	writeFile(t, c, "query C { c }")
+	writeFile(t, a, "query A { a aa }")
+	if err := os.Chtimes(a, time.Now(), time.Now().Add(time.Second)); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Remove(b); err != nil {
+		t.Fatal(err)
+	}
+
+	expected := []string{a, b, c}
+	if changed := w.Poll(); !reflect.DeepEqual(changed, expected) {
+		t.Errorf("expected changes %v, got %v", expected, changed)
+	}
+	if changed := w.Poll(); len(changed) != 0 {
+		t.Errorf("expected no changes after second poll, got %v", changed)
+	}
+}
+
+func TestPoll_WatchedFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "schema.json")
+	writeFile(t, path, `{"__schema": {}}`)
+
+	w := watch.New(path)
+	if files := w.Files(); !reflect.DeepEqual(files, []string{path}) {
+		t.Fatalf("expected the watched file regardless of its extension, got %v", files)
+	}
+
+	writeFile(t, path, `{"__schema": {"types": []}}`)
+	if changed := w.Poll(); !reflect.DeepEqual(changed, []string{path}) {
+		t.Errorf("expected changes %v, got %v", []string{path}, changed)
+	}
+}
+
+func writeFile(t *testing.T, path string, content string) {
+	t.Helper()
+	if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+		t.Fatalf("failed to write %s: %v", path, err)
+	}
+}